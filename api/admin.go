@@ -2,16 +2,21 @@ package api
 
 import (
 	"github.com/gofiber/fiber/v2"
-	"github.com/raphaelmb/go-hotel-reservation/types"
+	"github.com/raphaelmb/go-hotel-reservation/policy"
 )
 
-func AdminAuth(c *fiber.Ctx) error {
-	user, ok := c.Context().UserValue("user").(*types.User)
-	if !ok {
-		return ErrUnauthorized()
+// Handlers acting on a given hotel still check the user holds the permissions for it.
+func Require(perms ...policy.Permission) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user, err := getAuthUser(c)
+		if err != nil {
+			return ErrUnauthorized()
+		}
+		for _, perm := range perms {
+			if !policy.Allows(user, perm) {
+				return ErrForbidden()
+			}
+		}
+		return c.Next()
 	}
-	if !user.IsAdmin {
-		return ErrUnauthorized()
-	}
-	return c.Next()
 }
@@ -1,26 +1,36 @@
 package api
 
 import (
+	"context"
 	"errors"
-	"fmt"
 	"net/http"
-	"os"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/raphaelmb/go-hotel-reservation/db"
 	"github.com/raphaelmb/go-hotel-reservation/types"
-	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// access tokens are only checked against the revocation of their session
+	accessTokenTTL  = 15 * time.Minute
+	refreshTokenTTL = 30 * 24 * time.Hour
 )
 
 type AuthHandler struct {
-	userStore db.UserStore
+	userStore  db.UserStore
+	tokenStore db.TokenStore
+	tokens     *TokenIssuer
+	cookies    *SessionCookies
 }
 
-func NewAuthHandler(userStore db.UserStore) *AuthHandler {
+func NewAuthHandler(userStore db.UserStore, tokenStore db.TokenStore, tokens *TokenIssuer, cookies *SessionCookies) *AuthHandler {
 	return &AuthHandler{
-		userStore: userStore,
+		userStore:  userStore,
+		tokenStore: tokenStore,
+		tokens:     tokens,
+		cookies:    cookies,
 	}
 }
 
@@ -30,8 +40,15 @@ type AuthParams struct {
 }
 
 type AuthResponse struct {
-	User  *types.User `json:"user"`
-	Token string      `json:"token"`
+	User         *types.User `json:"user"`
+	Token        string      `json:"token,omitempty"`
+	RefreshToken string      `json:"refreshToken,omitempty"`
+	// CSRFToken replaces the tokens of cookie sessions.
+	CSRFToken string `json:"csrfToken,omitempty"`
+}
+
+type RefreshParams struct {
+	RefreshToken string `json:"refreshToken"`
 }
 
 type genericResp struct {
@@ -51,10 +68,14 @@ func (h *AuthHandler) HandleAuthenticate(c *fiber.Ctx) error {
 	if err := c.BodyParser(&params); err != nil {
 		return err
 	}
+	inCookies, err := h.cookies.requested(c)
+	if err != nil {
+		return err
+	}
 
 	user, err := h.userStore.GetUserByEmail(c.Context(), params.Email)
 	if err != nil {
-		if errors.Is(err, mongo.ErrNoDocuments) {
+		if errors.Is(err, db.ErrNotFound) {
 			return invalidCredentials(c)
 		}
 		return err
@@ -64,26 +85,128 @@ func (h *AuthHandler) HandleAuthenticate(c *fiber.Ctx) error {
 		return invalidCredentials(c)
 	}
 
-	return c.JSON(AuthResponse{
-		User:  user,
-		Token: CreateTokenFromUser(user),
-	})
+	resp, err := StartSession(c.Context(), h.tokenStore, h.tokens, user)
+	if err != nil {
+		return err
+	}
+	return sendSession(c, h.cookies, resp, inCookies)
 }
 
-func CreateTokenFromUser(user *types.User) string {
+// HandleRefresh revokes the whole session of a refresh token traded twice.
+func (h *AuthHandler) HandleRefresh(c *fiber.Ctx) error {
+	refreshToken, inCookies, err := h.refreshToken(c)
+	if err != nil {
+		return err
+	}
+
+	token, err := h.tokenStore.GetRefreshTokenByHash(c.Context(), types.HashRefreshToken(refreshToken))
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return ErrUnauthorized()
+		}
+		return err
+	}
 	now := time.Now()
-	expires := now.Add(time.Hour * 4).Unix()
-	claims := jwt.MapClaims{
-		"id":      user.ID,
-		"email":   user.Email,
-		"expires": expires,
+	if !token.RevokedAt.IsZero() || now.After(token.ExpiresAt) {
+		return ErrUnauthorized()
+	}
+	if err := h.tokenStore.UseRefreshToken(c.Context(), token.ID, now); err != nil {
+		if errors.Is(err, db.ErrRefreshTokenUsed) {
+			if err := h.tokenStore.RevokeTokenFamily(c.Context(), token.Family, now); err != nil {
+				return err
+			}
+			return NewError(http.StatusUnauthorized, "refresh token reused, session revoked")
+		}
+		return err
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenStr, err := token.SignedString([]byte(os.Getenv("JWT_SECRET")))
+	user, err := h.userStore.GetUserByID(c.Context(), token.UserID.Hex())
 	if err != nil {
-		fmt.Println("failed to sign token with secret")
+		return ErrUnauthorized()
 	}
+	resp, err := issueTokens(c.Context(), h.tokenStore, h.tokens, user, token.Family, now)
+	if err != nil {
+		return err
+	}
+	return sendSession(c, h.cookies, resp, inCookies)
+}
 
-	return tokenStr
+func (h *AuthHandler) HandleLogout(c *fiber.Ctx) error {
+	refreshToken, inCookies, err := h.refreshToken(c)
+	if err != nil {
+		return err
+	}
+	if inCookies {
+		h.cookies.clear(c)
+	}
+
+	token, err := h.tokenStore.GetRefreshTokenByHash(c.Context(), types.HashRefreshToken(refreshToken))
+	if err != nil {
+		// logging out of an unknown session leaves nothing to do
+		if errors.Is(err, db.ErrNotFound) {
+			return c.SendStatus(http.StatusNoContent)
+		}
+		return err
+	}
+	if err := h.tokenStore.RevokeTokenFamily(c.Context(), token.Family, time.Now()); err != nil {
+		return err
+	}
+	return c.SendStatus(http.StatusNoContent)
+}
+
+func (h *AuthHandler) refreshToken(c *fiber.Ctx) (token string, inCookies bool, err error) {
+	var params RefreshParams
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&params); err != nil {
+			return "", false, ErrBadRequest()
+		}
+	}
+	if len(params.RefreshToken) > 0 {
+		return params.RefreshToken, false, nil
+	}
+	if token := h.cookies.refreshToken(c); len(token) > 0 {
+		if err := h.cookies.checkCSRF(c); err != nil {
+			return "", false, err
+		}
+		return token, true, nil
+	}
+	return "", false, NewError(http.StatusBadRequest, "refreshToken is required")
+}
+
+func sendSession(c *fiber.Ctx, cookies *SessionCookies, resp *AuthResponse, inCookies bool) error {
+	if inCookies {
+		if err := cookies.set(c, resp); err != nil {
+			return err
+		}
+	}
+	return c.JSON(resp)
+}
+
+func StartSession(ctx context.Context, tokenStore db.TokenStore, tokens *TokenIssuer, user *types.User) (*AuthResponse, error) {
+	return issueTokens(ctx, tokenStore, tokens, user, primitive.NewObjectID(), time.Now())
+}
+
+func issueTokens(ctx context.Context, tokenStore db.TokenStore, tokens *TokenIssuer, user *types.User, session primitive.ObjectID, now time.Time) (*AuthResponse, error) {
+	accessToken, err := tokens.createAccessToken(user, session, now)
+	if err != nil {
+		return nil, err
+	}
+	refreshToken, hash, err := types.NewRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+	_, err = tokenStore.InsertRefreshToken(ctx, &types.RefreshToken{
+		UserID:    user.ID,
+		Family:    session,
+		Hash:      hash,
+		ExpiresAt: now.Add(refreshTokenTTL),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &AuthResponse{
+		User:         user,
+		Token:        accessToken,
+		RefreshToken: refreshToken,
+	}, nil
 }
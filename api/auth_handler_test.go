@@ -2,14 +2,19 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/raphaelmb/go-hotel-reservation/db/fixtures"
+	"github.com/raphaelmb/go-hotel-reservation/events"
+	"github.com/raphaelmb/go-hotel-reservation/types"
 )
 
 func TestAuthenticateWithWrongPassword(t *testing.T) {
@@ -18,7 +23,7 @@ func TestAuthenticateWithWrongPassword(t *testing.T) {
 	_ = fixtures.AddUser(tdb.Store, "james", "foo", false)
 
 	app := fiber.New()
-	authHandler := NewAuthHandler(tdb.User)
+	authHandler := NewAuthHandler(tdb.User, tdb.Token, testTokens, nil)
 	app.Post("/auth", authHandler.HandleAuthenticate)
 
 	params := AuthParams{
@@ -57,7 +62,7 @@ func TestAuthenticateSuccess(t *testing.T) {
 	insertedUser := fixtures.AddUser(tdb.Store, "james", "foo", false)
 
 	app := fiber.New()
-	authHandler := NewAuthHandler(tdb.User)
+	authHandler := NewAuthHandler(tdb.User, tdb.Token, testTokens, nil)
 	app.Post("/auth", authHandler.HandleAuthenticate)
 
 	params := AuthParams{
@@ -91,3 +96,283 @@ func TestAuthenticateSuccess(t *testing.T) {
 		t.Fatalf("expected user to be %v, got %v", insertedUser, authResp.User)
 	}
 }
+
+func TestRefreshTokens(t *testing.T) {
+	tdb := setup(t)
+	defer tdb.tearDown(t)
+
+	var (
+		user = fixtures.AddUser(tdb.Store, "james", "foo", false)
+
+		authHandler = NewAuthHandler(tdb.User, tdb.Token, testTokens, nil)
+		userHandler = NewUserHandler(tdb.User, tdb.Token, testTokens, nil)
+		app         = fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+		route       = app.Group("/api", JWTAuthentication(tdb.User, tdb.Token, testTokens, nil))
+	)
+	app.Post("/auth", authHandler.HandleAuthenticate)
+	app.Post("/auth/refresh", authHandler.HandleRefresh)
+	app.Post("/auth/logout", authHandler.HandleLogout)
+	route.Get("/me", userHandler.HandleGetMe)
+	route.Put("/me/password", userHandler.HandleChangePassword)
+
+	send := func(t *testing.T, method, path, token string, body any, status int) AuthResponse {
+		b, _ := json.Marshal(body)
+		req := httptest.NewRequest(method, path, bytes.NewReader(b))
+		req.Header.Add("Content-Type", "application/json")
+		if len(token) > 0 {
+			req.Header.Add("X-Api-Token", token)
+		}
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != status {
+			t.Fatalf("expected %d response for %s but got %d", status, path, resp.StatusCode)
+		}
+		var authResp AuthResponse
+		if status == http.StatusOK && method != http.MethodGet {
+			if err := json.NewDecoder(resp.Body).Decode(&authResp); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return authResp
+	}
+	login := func(t *testing.T, password string) AuthResponse {
+		session := send(t, http.MethodPost, "/auth", "", AuthParams{Email: user.Email, Password: password}, http.StatusOK)
+		if session.Token == "" || session.RefreshToken == "" {
+			t.Fatalf("expected both tokens to be set but got %+v", session)
+		}
+		return session
+	}
+
+	t.Run("should rotate the refresh token", func(t *testing.T) {
+		session := login(t, "james_foo")
+		next := send(t, http.MethodPost, "/auth/refresh", "", RefreshParams{session.RefreshToken}, http.StatusOK)
+		if next.RefreshToken == session.RefreshToken {
+			t.Fatalf("expected a new refresh token")
+		}
+		send(t, http.MethodGet, "/api/me", next.Token, nil, http.StatusOK)
+		send(t, http.MethodPost, "/auth/refresh", "", RefreshParams{next.RefreshToken}, http.StatusOK)
+	})
+
+	t.Run("should revoke the session when a refresh token is reused", func(t *testing.T) {
+		session := login(t, "james_foo")
+		next := send(t, http.MethodPost, "/auth/refresh", "", RefreshParams{session.RefreshToken}, http.StatusOK)
+		send(t, http.MethodPost, "/auth/refresh", "", RefreshParams{session.RefreshToken}, http.StatusUnauthorized)
+		send(t, http.MethodPost, "/auth/refresh", "", RefreshParams{next.RefreshToken}, http.StatusUnauthorized)
+		send(t, http.MethodGet, "/api/me", next.Token, nil, http.StatusUnauthorized)
+	})
+
+	t.Run("should revoke the session on logout", func(t *testing.T) {
+		session := login(t, "james_foo")
+		other := login(t, "james_foo")
+		send(t, http.MethodPost, "/auth/logout", "", RefreshParams{session.RefreshToken}, http.StatusNoContent)
+		send(t, http.MethodGet, "/api/me", session.Token, nil, http.StatusUnauthorized)
+		send(t, http.MethodPost, "/auth/refresh", "", RefreshParams{session.RefreshToken}, http.StatusUnauthorized)
+		send(t, http.MethodGet, "/api/me", other.Token, nil, http.StatusOK)
+		send(t, http.MethodPost, "/auth/logout", "", RefreshParams{"unknown"}, http.StatusNoContent)
+	})
+
+	t.Run("should revoke every session on password change", func(t *testing.T) {
+		session := login(t, "james_foo")
+		other := login(t, "james_foo")
+		params := types.ChangePasswordParams{CurrentPassword: "wrong", NewPassword: "new_password"}
+		send(t, http.MethodPut, "/api/me/password", session.Token, params, http.StatusBadRequest)
+
+		params.CurrentPassword = "james_foo"
+		changed := send(t, http.MethodPut, "/api/me/password", session.Token, params, http.StatusOK)
+		send(t, http.MethodGet, "/api/me", session.Token, nil, http.StatusUnauthorized)
+		send(t, http.MethodPost, "/auth/refresh", "", RefreshParams{other.RefreshToken}, http.StatusUnauthorized)
+		send(t, http.MethodGet, "/api/me", changed.Token, nil, http.StatusOK)
+		login(t, "new_password")
+	})
+}
+
+func TestRequestTokens(t *testing.T) {
+	tdb := setup(t)
+	defer tdb.tearDown(t)
+
+	var (
+		user  = fixtures.AddUser(tdb.Store, "james", "foo", false)
+		token = createToken(user)
+
+		app   = fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+		route = app.Group("/", JWTAuthentication(tdb.User, tdb.Token, testTokens, nil))
+	)
+	route.Get("/me", NewUserHandler(tdb.User, tdb.Token, testTokens, nil).HandleGetMe)
+
+	send := func(t *testing.T, header, value string, status int) *http.Response {
+		req := httptest.NewRequest(http.MethodGet, "/me", nil)
+		if len(header) > 0 {
+			req.Header.Add(header, value)
+		}
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != status {
+			t.Fatalf("expected %d response but got %d", status, resp.StatusCode)
+		}
+		return resp
+	}
+
+	t.Run("should accept bearer tokens", func(t *testing.T) {
+		send(t, "Authorization", "Bearer "+token, http.StatusOK)
+		send(t, "Authorization", "bearer "+token, http.StatusOK)
+	})
+
+	t.Run("should keep accepting the X-Api-Token header", func(t *testing.T) {
+		send(t, "X-Api-Token", token, http.StatusOK)
+	})
+
+	t.Run("should challenge clients without a valid token", func(t *testing.T) {
+		resp := send(t, "", "", http.StatusUnauthorized)
+		if got := resp.Header.Get("WWW-Authenticate"); got != `Bearer realm="go-hotel-reservation"` {
+			t.Fatalf("unexpected challenge %q", got)
+		}
+		send(t, "Authorization", "Basic "+token, http.StatusUnauthorized)
+
+		resp = send(t, "Authorization", "Bearer "+token+"x", http.StatusUnauthorized)
+		if got := resp.Header.Get("WWW-Authenticate"); !strings.Contains(got, `error="invalid_token"`) {
+			t.Fatalf("expected an invalid_token challenge but got %q", got)
+		}
+	})
+}
+
+func TestSessionCookies(t *testing.T) {
+	tdb := setup(t)
+	defer tdb.tearDown(t)
+
+	var (
+		user    = fixtures.AddUser(tdb.Store, "james", "foo", false)
+		cookies = &SessionCookies{}
+
+		authHandler = NewAuthHandler(tdb.User, tdb.Token, testTokens, cookies)
+		userHandler = NewUserHandler(tdb.User, tdb.Token, testTokens, cookies)
+		app         = fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+		route       = app.Group("/api/v1", JWTAuthentication(tdb.User, tdb.Token, testTokens, cookies))
+	)
+	app.Post("/api/auth", authHandler.HandleAuthenticate)
+	app.Post("/api/auth/refresh", authHandler.HandleRefresh)
+	app.Post("/api/auth/logout", authHandler.HandleLogout)
+	route.Get("/me", userHandler.HandleGetMe)
+	route.Put("/me", userHandler.HandleUpdateMe)
+	route.Get("/booking/:id/cancel", NewBookingHandler(tdb.Store, &events.Recorder{}).HandleDeprecatedCancelBooking)
+
+	// jar keeps the cookies of the responses, as a browser would
+	jar := make(map[string]string)
+	send := func(t *testing.T, method, path, csrfToken string, body any, status int) *http.Response {
+		var b []byte
+		if body != nil {
+			b, _ = json.Marshal(body)
+		}
+		req := httptest.NewRequest(method, path, bytes.NewReader(b))
+		req.Header.Add("Content-Type", "application/json")
+		if len(csrfToken) > 0 {
+			req.Header.Add("X-CSRF-Token", csrfToken)
+		}
+		for name, value := range jar {
+			req.AddCookie(&http.Cookie{Name: name, Value: value})
+		}
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != status {
+			t.Fatalf("expected %d response for %s but got %d", status, path, resp.StatusCode)
+		}
+		for _, cookie := range resp.Cookies() {
+			if cookie.MaxAge < 0 || (!cookie.Expires.IsZero() && cookie.Expires.Before(time.Now())) {
+				delete(jar, cookie.Name)
+				continue
+			}
+			if !cookie.Secure || cookie.SameSite != http.SameSiteStrictMode {
+				t.Fatalf("expected a secure strict cookie but got %+v", cookie)
+			}
+			if cookie.HttpOnly != (cookie.Name != "csrf_token") {
+				t.Fatalf("expected only the csrf cookie to be readable by scripts but got %+v", cookie)
+			}
+			jar[cookie.Name] = cookie.Value
+		}
+		return resp
+	}
+	login := func(t *testing.T) string {
+		resp := send(t, http.MethodPost, "/api/auth?session=cookie", "", AuthParams{Email: user.Email, Password: "james_foo"}, http.StatusOK)
+		var authResp AuthResponse
+		if err := json.NewDecoder(resp.Body).Decode(&authResp); err != nil {
+			t.Fatal(err)
+		}
+		if len(authResp.Token) > 0 || len(authResp.RefreshToken) > 0 {
+			t.Fatalf("expected the tokens to be kept in cookies only but got %+v", authResp)
+		}
+		if authResp.CSRFToken != jar["csrf_token"] || len(jar["access_token"]) == 0 || len(jar["refresh_token"]) == 0 {
+			t.Fatalf("expected the session cookies to be set but got %v", jar)
+		}
+		return authResp.CSRFToken
+	}
+
+	t.Run("should authenticate with the session cookies", func(t *testing.T) {
+		csrfToken := login(t)
+		send(t, http.MethodGet, "/api/v1/me", "", nil, http.StatusOK)
+		send(t, http.MethodPut, "/api/v1/me", csrfToken, types.UpdateUserParams{FirstName: "Jim"}, http.StatusOK)
+	})
+
+	t.Run("should require the csrf token for unsafe requests", func(t *testing.T) {
+		csrfToken := login(t)
+		send(t, http.MethodPut, "/api/v1/me", "", types.UpdateUserParams{FirstName: "Jim"}, http.StatusForbidden)
+		send(t, http.MethodPut, "/api/v1/me", csrfToken+"x", types.UpdateUserParams{FirstName: "Jim"}, http.StatusForbidden)
+		send(t, http.MethodPost, "/api/auth/refresh", "", nil, http.StatusForbidden)
+	})
+
+	t.Run("should not cancel bookings with GET", func(t *testing.T) {
+		login(t)
+		hotel := fixtures.AddHotel(tdb.Store, "hotel", "anywhere", 4, nil)
+		room := fixtures.AddRoom(tdb.Store, "small", true, 100, hotel.ID)
+		booking := fixtures.AddBooking(tdb.Store, user.ID, room.ID, time.Now().AddDate(0, 0, 10), time.Now().AddDate(0, 0, 12))
+		send(t, http.MethodGet, "/api/v1/booking/"+booking.ID.Hex()+"/cancel", "", nil, http.StatusForbidden)
+
+		got, err := tdb.Booking.GetBookingByID(context.Background(), booking.ID.Hex())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Status != types.BookingConfirmed {
+			t.Fatalf("expected the booking to stay confirmed but got %s", got.Status)
+		}
+	})
+
+	t.Run("should refresh and log out with the session cookies", func(t *testing.T) {
+		csrfToken := login(t)
+		refreshToken := jar["refresh_token"]
+		send(t, http.MethodPost, "/api/auth/refresh", csrfToken, nil, http.StatusOK)
+		if jar["refresh_token"] == refreshToken {
+			t.Fatalf("expected the refresh token cookie to be rotated")
+		}
+		accessToken := jar["access_token"]
+		send(t, http.MethodPost, "/api/auth/logout", jar["csrf_token"], nil, http.StatusNoContent)
+		if len(jar) != 0 {
+			t.Fatalf("expected the session cookies to be cleared but got %v", jar)
+		}
+		jar["access_token"] = accessToken
+		send(t, http.MethodGet, "/api/v1/me", "", nil, http.StatusUnauthorized)
+	})
+
+	t.Run("should not read cookies when cookie sessions are disabled", func(t *testing.T) {
+		delete(jar, "access_token")
+		login(t)
+		app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+		app.Post("/auth", NewAuthHandler(tdb.User, tdb.Token, testTokens, nil).HandleAuthenticate)
+		app.Get("/me", JWTAuthentication(tdb.User, tdb.Token, testTokens, nil), userHandler.HandleGetMe)
+
+		b, _ := json.Marshal(AuthParams{Email: user.Email, Password: "james_foo"})
+		req := httptest.NewRequest(http.MethodPost, "/auth?session=cookie", bytes.NewReader(b))
+		req.Header.Add("Content-Type", "application/json")
+		if resp, err := app.Test(req); err != nil || resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("expected cookie sessions to be refused but got %v, %v", resp, err)
+		}
+		req = httptest.NewRequest(http.MethodGet, "/me", nil)
+		req.AddCookie(&http.Cookie{Name: "access_token", Value: jar["access_token"]})
+		if resp, err := app.Test(req); err != nil || resp.StatusCode != http.StatusUnauthorized {
+			t.Fatalf("expected the cookie to be ignored but got %v, %v", resp, err)
+		}
+	})
+}
@@ -0,0 +1,235 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/raphaelmb/go-hotel-reservation/db"
+	"github.com/raphaelmb/go-hotel-reservation/types"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	dateLayout          = "2006-01-02"
+	defaultPageLimit    = 10
+	defaultPartySize    = 1
+	maxAvailabilityDays = 90
+)
+
+type AvailabilityQueryParams struct {
+	db.Pagination
+	From      string
+	Till      string
+	Persons   int
+	Location  string
+	MinRating int
+	Seaside   *bool
+	// MaxPrice is in the requested currency or else in the currency of each hotel.
+	MaxPrice float64
+}
+
+func (p *AvailabilityQueryParams) stay() (from, till time.Time, err error) {
+	from, err = time.Parse(dateLayout, p.From)
+	if err != nil {
+		return from, till, fmt.Errorf("from should be a date formatted as %s", dateLayout)
+	}
+	till, err = time.Parse(dateLayout, p.Till)
+	if err != nil {
+		return from, till, fmt.Errorf("till should be a date formatted as %s", dateLayout)
+	}
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	if from.Before(today) {
+		return from, till, fmt.Errorf("cannot search availability in the past")
+	}
+	if !till.After(from) {
+		return from, till, fmt.Errorf("till should be after from")
+	}
+	if till.Sub(from) > maxAvailabilityDays*24*time.Hour {
+		return from, till, fmt.Errorf("cannot search stays longer than %d days", maxAvailabilityDays)
+	}
+	return from, till, nil
+}
+
+type AvailableRoom struct {
+	*types.Room
+	Nights     int         `json:"nights"`
+	TotalPrice types.Money `json:"totalPrice"`
+}
+
+type HotelAvailability struct {
+	Hotel *types.Hotel     `json:"hotel"`
+	Rooms []*AvailableRoom `json:"rooms"`
+}
+
+type AvailabilityHandler struct {
+	store *db.Store
+}
+
+func NewAvailabilityHandler(store *db.Store) *AvailabilityHandler {
+	return &AvailabilityHandler{
+		store: store,
+	}
+}
+
+// HandleGetAvailability pages over the hotels with at least one free room.
+func (h *AvailabilityHandler) HandleGetAvailability(c *fiber.Ctx) error {
+	var params AvailabilityQueryParams
+	if err := c.QueryParser(&params); err != nil {
+		return ErrBadRequest()
+	}
+	from, till, err := params.stay()
+	if err != nil {
+		return NewError(http.StatusBadRequest, err.Error())
+	}
+	if params.Persons == 0 {
+		params.Persons = defaultPartySize
+	}
+	if params.Persons < 0 || params.MaxPrice < 0 || params.MinRating < 0 {
+		return NewError(http.StatusBadRequest, "persons, maxPrice and minRating should not be negative")
+	}
+	if params.Page < 1 {
+		params.Page = 1
+	}
+	if params.Limit < 1 {
+		params.Limit = defaultPageLimit
+	}
+
+	// scan the hotels a page of the store at a time until the page is filled
+	var (
+		skip    = int((params.Page - 1) * params.Limit)
+		results = []*HotelAvailability{}
+		filter  = db.HotelFilter{MinRating: params.MinRating, Location: params.Location}
+	)
+	for batch := int64(1); len(results) < int(params.Limit); batch++ {
+		hotels, err := h.store.Hotel.GetHotels(c.Context(), filter, &db.Pagination{Limit: params.Limit, Page: batch})
+		if err != nil {
+			return err
+		}
+		available, err := h.availableHotels(c, hotels, &params, from, till)
+		if err != nil {
+			return err
+		}
+		for _, hotel := range available {
+			if skip > 0 {
+				skip--
+			} else if len(results) < int(params.Limit) {
+				results = append(results, hotel)
+			}
+		}
+		if int64(len(hotels)) < params.Limit {
+			break
+		}
+	}
+
+	return c.JSON(ResourceResp{
+		Data:    results,
+		Results: len(results),
+		Page:    int(params.Page),
+	})
+}
+
+func (h *AvailabilityHandler) availableHotels(c *fiber.Ctx, hotels []*types.Hotel, params *AvailabilityQueryParams, from, till time.Time) ([]*HotelAvailability, error) {
+	hotelIDs := make([]primitive.ObjectID, len(hotels))
+	hotelsByID := map[primitive.ObjectID]*types.Hotel{}
+	for i, hotel := range hotels {
+		hotelIDs[i] = hotel.ID
+		hotelsByID[hotel.ID] = hotel
+	}
+	rooms, err := h.store.Room.GetRooms(c.Context(), db.RoomFilter{
+		HotelIDs: hotelIDs,
+		Seaside:  params.Seaside,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		conv       = getPriceConverter(c)
+		candidates []*types.Room
+		roomIDs    = []primitive.ObjectID{}
+	)
+	for _, room := range rooms {
+		if room.Capacity() < params.Persons {
+			continue
+		}
+		converted, err := conv.room(room)
+		if err != nil {
+			return nil, err
+		}
+		if params.MaxPrice > 0 && converted.Price.Amount > types.NewMoney(params.MaxPrice, converted.Price.Currency).Amount {
+			continue
+		}
+		candidates = append(candidates, room)
+		roomIDs = append(roomIDs, room.ID)
+	}
+
+	booked, err := bookedRooms(c.Context(), h.store.Booking, roomIDs, from, till)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		nights    = len(types.Nights(from, till))
+		available = map[primitive.ObjectID][]*AvailableRoom{}
+	)
+	for _, room := range candidates {
+		if booked[room.ID] {
+			continue
+		}
+		quote, err := conv.quote(hotelsByID[room.HotelID].Quote(room, from, till, params.Persons, nil))
+		if err != nil {
+			return nil, err
+		}
+		room, err := conv.room(room)
+		if err != nil {
+			return nil, err
+		}
+		available[room.HotelID] = append(available[room.HotelID], &AvailableRoom{
+			Room:       room,
+			Nights:     nights,
+			TotalPrice: quote.Total,
+		})
+	}
+
+	var results []*HotelAvailability
+	for _, hotel := range hotels {
+		if rooms := available[hotel.ID]; len(rooms) > 0 {
+			results = append(results, &HotelAvailability{Hotel: hotel, Rooms: rooms})
+		}
+	}
+	return results, nil
+}
+
+// bookedRooms ignores expired holds, booking the room releases them.
+func bookedRooms(ctx context.Context, store db.BookingStore, roomIDs []primitive.ObjectID, from, till time.Time) (map[primitive.ObjectID]bool, error) {
+	bookings, err := store.GetBookings(ctx, db.BookingFilter{
+		RoomIDs:  roomIDs,
+		Overlaps: &db.DateRange{From: from, Till: till},
+		Statuses: types.ActiveBookingStatuses(),
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	nights := map[time.Time]bool{}
+	for _, night := range types.Nights(from, till) {
+		nights[night] = true
+	}
+	booked := map[primitive.ObjectID]bool{}
+	now := time.Now()
+	for _, booking := range bookings {
+		if booking.IsExpired(now) {
+			continue
+		}
+		for _, night := range booking.Nights() {
+			if nights[night] {
+				booked[booking.RoomID] = true
+				break
+			}
+		}
+	}
+	return booked, nil
+}
@@ -0,0 +1,122 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/raphaelmb/go-hotel-reservation/db/fixtures"
+	"github.com/raphaelmb/go-hotel-reservation/types"
+)
+
+func TestGetAvailability(t *testing.T) {
+	db := setup(t)
+	defer db.tearDown(t)
+
+	var (
+		user         = fixtures.AddUser(db.Store, "james", "foo", false)
+		beach        = fixtures.AddHotel(db.Store, "beach", "Rio", 4, nil)
+		city         = fixtures.AddHotel(db.Store, "city", "Sao Paulo", 3, nil)
+		beachSea     = fixtures.AddRoom(db.Store, "large", true, 200, beach.ID)
+		beachBack    = fixtures.AddRoom(db.Store, "small", false, 100, beach.ID)
+		cityRoom     = fixtures.AddRoom(db.Store, "small", false, 80, city.ID)
+		from         = time.Now().UTC().AddDate(0, 0, 10).Truncate(24 * time.Hour)
+		till         = from.AddDate(0, 0, 3)
+		_            = fixtures.AddBooking(db.Store, user.ID, beachSea.ID, from.AddDate(0, 0, 2), from.AddDate(0, 0, 4))
+		_            = fixtures.AddBooking(db.Store, user.ID, cityRoom.ID, till, till.AddDate(0, 0, 2))
+		availHandler = NewAvailabilityHandler(db.Store)
+
+		app   = fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+		route = app.Group("/", JWTAuthentication(db.User, db.Token, testTokens, nil))
+	)
+	route.Get("/", availHandler.HandleGetAvailability)
+
+	search := func(t *testing.T, query string) (int, []*HotelAvailability) {
+		req := httptest.NewRequest(http.MethodGet, "/?from="+from.Format(dateLayout)+"&till="+till.Format(dateLayout)+query, nil)
+		req.Header.Add("X-Api-Token", createToken(user))
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var results []*HotelAvailability
+		if resp.StatusCode == http.StatusOK {
+			body := ResourceResp{Data: &results}
+			if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return resp.StatusCode, results
+	}
+
+	t.Run("should group free rooms by hotel with the stay price", func(t *testing.T) {
+		code, results := search(t, "")
+		if code != http.StatusOK {
+			t.Fatalf("expected 200 response but got %d", code)
+		}
+		if len(results) != 2 {
+			t.Fatalf("expected 2 hotels but got %d", len(results))
+		}
+		if results[0].Hotel.ID != beach.ID || len(results[0].Rooms) != 1 || results[0].Rooms[0].ID != beachBack.ID {
+			t.Fatalf("expected only room %s to be free in hotel %s", beachBack.ID, beach.ID)
+		}
+		if results[0].Rooms[0].Nights != 3 || results[0].Rooms[0].TotalPrice != types.NewMoney(300, types.DefaultCurrency) {
+			t.Fatalf("expected 3 nights for 300 but got %d nights for %s", results[0].Rooms[0].Nights, results[0].Rooms[0].TotalPrice)
+		}
+		if results[1].Hotel.ID != city.ID || len(results[1].Rooms) != 1 {
+			t.Fatalf("expected the city room to be free when checking in on check out day")
+		}
+	})
+
+	t.Run("should filter by hotel and room", func(t *testing.T) {
+		_, results := search(t, "&location=rio&seaside=false&maxPrice=150&minRating=4")
+		if len(results) != 1 || results[0].Hotel.ID != beach.ID || results[0].Rooms[0].ID != beachBack.ID {
+			t.Fatalf("expected only room %s to match", beachBack.ID)
+		}
+		_, results = search(t, "&minRating=5")
+		if len(results) != 0 {
+			t.Fatalf("expected no hotel to match but got %d", len(results))
+		}
+	})
+
+	t.Run("should skip rooms too small for the party", func(t *testing.T) {
+		_, results := search(t, "&persons=3")
+		if len(results) != 0 {
+			t.Fatalf("expected no room to fit 3 persons but got %d hotels", len(results))
+		}
+	})
+
+	t.Run("should paginate hotels", func(t *testing.T) {
+		_, results := search(t, "&limit=1&page=2")
+		if len(results) != 1 || results[0].Hotel.ID != city.ID {
+			t.Fatalf("expected the second page to hold hotel %s", city.ID)
+		}
+		// the beach hotel has no room cheap enough and takes no place
+		_, results = search(t, "&limit=1&page=1&maxPrice=90")
+		if len(results) != 1 || results[0].Hotel.ID != city.ID {
+			t.Fatalf("expected the first page to hold hotel %s", city.ID)
+		}
+		_, results = search(t, "&limit=1&page=2&maxPrice=90")
+		if len(results) != 0 {
+			t.Fatalf("expected no hotel past the last page but got %d", len(results))
+		}
+		_, results = search(t, "&limit=1&page=3")
+		if len(results) != 0 {
+			t.Fatalf("expected no hotel past the last page but got %d", len(results))
+		}
+	})
+
+	t.Run("should reject invalid stays", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/?from="+till.Format(dateLayout)+"&till="+from.Format(dateLayout), nil)
+		req.Header.Add("X-Api-Token", createToken(user))
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("expected 400 response but got %d", resp.StatusCode)
+		}
+	})
+}
@@ -1,63 +1,682 @@
 package api
 
 import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
 	"github.com/gofiber/fiber/v2"
 	"github.com/raphaelmb/go-hotel-reservation/db"
-	"go.mongodb.org/mongo-driver/bson"
+	"github.com/raphaelmb/go-hotel-reservation/events"
+	"github.com/raphaelmb/go-hotel-reservation/policy"
+	"github.com/raphaelmb/go-hotel-reservation/types"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 type BookingHandler struct {
-	store *db.Store
+	store     *db.Store
+	publisher events.Publisher
 }
 
-func NewBookingHandler(store *db.Store) *BookingHandler {
+func NewBookingHandler(store *db.Store, publisher events.Publisher) *BookingHandler {
 	return &BookingHandler{
-		store: store,
+		store:     store,
+		publisher: publisher,
 	}
 }
 
+const maxCancelReasonLen = 500
+
+type CancelBookingParams struct {
+	Reason string `json:"reason"`
+}
+
+func (p CancelBookingParams) Validate() map[string]string {
+	errors := make(map[string]string)
+	if len(p.Reason) > maxCancelReasonLen {
+		errors["reason"] = fmt.Sprintf("reason should be at most %d characters long", maxCancelReasonLen)
+	}
+	return errors
+}
+
 func (h *BookingHandler) HandleCancelBooking(c *fiber.Ctx) error {
-	id := c.Params("id")
-	booking, err := h.store.Booking.GetBookingByID(c.Context(), id)
+	booking, err := h.getUserBooking(c)
 	if err != nil {
-		return ErrResourceNotFound()
+		return err
+	}
+	if err := standalone(booking); err != nil {
+		return err
+	}
+	return h.cancelBooking(c, booking)
+}
+
+// standalone rejects bookings of group reservations, which change together.
+func standalone(booking *types.Booking) error {
+	if !booking.ReservationID.IsZero() {
+		return NewError(http.StatusConflict, fmt.Sprintf("booking %s is part of reservation %s", booking.ID.Hex(), booking.ReservationID.Hex()))
+	}
+	return nil
+}
+
+// HandleDeprecatedCancelBooking serves the former GET route. Cookie sessions must use POST,
+// which carries the csrf token.
+func (h *BookingHandler) HandleDeprecatedCancelBooking(c *fiber.Ctx) error {
+	c.Set("Deprecation", "true")
+	c.Set("Link", fmt.Sprintf(`<%s>; rel="successor-version"`, c.Path()))
+	if inCookieSession(c) {
+		return NewError(http.StatusForbidden, "cookie sessions cancel bookings with POST")
+	}
+	return h.HandleCancelBooking(c)
+}
+
+func (h *BookingHandler) HandleAdminCancelBooking(c *fiber.Ctx) error {
+	booking, err := h.getManagedBooking(c)
+	if err != nil {
+		return err
+	}
+	return h.cancelBooking(c, booking)
+}
+
+func (h *BookingHandler) cancelBooking(c *fiber.Ctx, booking *types.Booking) error {
+	params, err := parseCancelBookingParams(c)
+	if err != nil {
+		return err
+	}
+	if errors := params.Validate(); len(errors) > 0 {
+		return c.Status(http.StatusBadRequest).JSON(errors)
 	}
 	user, err := getAuthUser(c)
 	if err != nil {
 		return ErrUnauthorized()
 	}
-	if booking.UserID != user.ID {
-		return ErrUnauthorized()
+
+	now := time.Now()
+	booking, err = cancelBooking(c, h.store, booking, user.ID, params.Reason, now)
+	if err != nil {
+		return err
+	}
+	// the cancellation stands even if the room cannot be offered
+	if err := offerFreedRoom(c.Context(), h.store, h.publisher, booking, now); err != nil {
+		log.Printf("failed to offer room %s to the waitlist: %v", booking.RoomID.Hex(), err)
 	}
-	if err := h.store.Booking.UpdateBooking(c.Context(), c.Params("id"), bson.M{"cancelled": true}); err != nil {
+	booking, err = getPriceConverter(c).booking(booking)
+	if err != nil {
 		return err
 	}
+	return c.JSON(booking)
+}
 
-	return c.JSON(genericResp{Type: "msg", Msg: "updated"})
+func parseCancelBookingParams(c *fiber.Ctx) (*CancelBookingParams, error) {
+	var params CancelBookingParams
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&params); err != nil {
+			return nil, ErrBadRequest()
+		}
+	}
+	return &params, nil
 }
 
-func (h *BookingHandler) HandleGetBookings(c *fiber.Ctx) error {
-	bookings, err := h.store.Booking.GetBookings(c.Context(), bson.M{})
+func cancelBooking(c *fiber.Ctx, store *db.Store, booking *types.Booking, by primitive.ObjectID, reason string, now time.Time) (*types.Booking, error) {
+	if arrival := types.Nights(booking.FromDate, booking.TillDate)[0]; now.After(arrival.AddDate(0, 0, 1)) {
+		return nil, NewError(http.StatusConflict, fmt.Sprintf("booking %s started on %s", booking.ID.Hex(), arrival.Format(dateLayout)))
+	}
+	cancellation := booking.CancellationAt(now)
+	cancellation.By = by
+	cancellation.Reason = reason
+
+	cancelled, err := store.Booking.CancelBooking(c.Context(), booking, cancellation)
 	if err != nil {
-		return ErrResourceNotFound()
+		return nil, statusError(err)
+	}
+	if err := db.ReleaseHoldPromo(c.Context(), store.Promo, booking); err != nil {
+		return nil, err
 	}
-	return c.JSON(bookings)
+	return cancelled, nil
 }
 
-func (h *BookingHandler) HandleGetBooking(c *fiber.Ctx) error {
+type CancellationPreview struct {
+	Policy types.CancellationPolicy `json:"policy"`
+	// FreeUntil is left out for non refundable bookings.
+	FreeUntil *time.Time `json:"freeUntil,omitempty"`
+	*types.Cancellation
+}
+
+func (h *BookingHandler) HandleCancelPreview(c *fiber.Ctx) error {
+	booking, err := h.getUserBooking(c)
+	if err != nil {
+		return err
+	}
+	if err := standalone(booking); err != nil {
+		return err
+	}
+	if !booking.Status.CanTransitionTo(types.BookingCancelled) {
+		return statusError(&db.TransitionError{From: booking.Status, To: types.BookingCancelled})
+	}
+
+	cancellation, err := getPriceConverter(c).cancellation(booking.CancellationAt(time.Now()))
+	if err != nil {
+		return err
+	}
+	preview := CancellationPreview{
+		Policy:       booking.CancellationPolicy,
+		Cancellation: cancellation,
+	}
+	if freeUntil, ok := booking.CancellationPolicy.FreeUntil(booking.FromDate); ok {
+		preview.FreeUntil = &freeUntil
+	}
+	return c.JSON(preview)
+}
+
+// Fields left out of ModifyBookingParams keep their current value.
+type ModifyBookingParams struct {
+	RoomID     string     `json:"roomID,omitempty"`
+	FromDate   *time.Time `json:"fromDate,omitempty"`
+	TillDate   *time.Time `json:"tillDate,omitempty"`
+	NumPersons int        `json:"numPersons,omitempty"`
+}
+
+func (p ModifyBookingParams) isEmpty() bool {
+	return len(p.RoomID) == 0 && p.FromDate == nil && p.TillDate == nil && p.NumPersons == 0
+}
+
+func (h *BookingHandler) HandleModifyBooking(c *fiber.Ctx) error {
+	booking, err := h.getUserBooking(c)
+	if err != nil {
+		return err
+	}
+	if err := standalone(booking); err != nil {
+		return err
+	}
+	var params ModifyBookingParams
+	if err := c.BodyParser(&params); err != nil {
+		return ErrBadRequest()
+	}
+	if params.isEmpty() {
+		return NewError(http.StatusBadRequest, "no changes given")
+	}
+	if params.NumPersons < 0 {
+		return NewError(http.StatusBadRequest, "numPersons should be at least 1")
+	}
+
+	now := time.Now()
+	if booking.Status != types.BookingPending && booking.Status != types.BookingConfirmed {
+		return NewError(http.StatusConflict, fmt.Sprintf("%s bookings cannot be changed", booking.Status))
+	}
+	if booking.IsExpired(now) {
+		return NewError(http.StatusConflict, fmt.Sprintf("hold %s has expired", booking.ID.Hex()))
+	}
+	if arrival := types.Nights(booking.FromDate, booking.TillDate)[0]; now.After(arrival.AddDate(0, 0, 1)) {
+		return NewError(http.StatusConflict, fmt.Sprintf("booking %s started on %s", booking.ID.Hex(), arrival.Format(dateLayout)))
+	}
+
+	stay := &BookRoomParams{
+		FromDate:   booking.FromDate,
+		TillDate:   booking.TillDate,
+		NumPersons: booking.NumPersons,
+	}
+	if params.FromDate != nil {
+		stay.FromDate = *params.FromDate
+	}
+	if params.TillDate != nil {
+		stay.TillDate = *params.TillDate
+	}
+	if params.NumPersons > 0 {
+		stay.NumPersons = params.NumPersons
+	}
+	if params.FromDate != nil || params.TillDate != nil {
+		if err := stay.validate(); err != nil {
+			return err
+		}
+	}
+	if booking.Price != nil && booking.Price.Promo != nil {
+		stay.PromoCode = booking.Price.Promo.Code
+	}
+
+	room, err := h.getModifiedRoom(c, booking, params.RoomID)
+	if err != nil {
+		return err
+	}
+	if stay.NumPersons > room.Capacity() {
+		return NewError(http.StatusUnprocessableEntity, fmt.Sprintf("room %s fits at most %d persons", room.ID.Hex(), room.Capacity()))
+	}
+	hotel, err := h.store.Hotel.GetHotelByID(c.Context(), booking.HotelID.Hex())
+	if err != nil {
+		return err
+	}
+	// the promo code of the booking is checked as of when it was booked
+	price, err := quoteStay(c, h.store, hotel, room, stay, booking.ID.Timestamp())
+	if err != nil {
+		return err
+	}
+
+	modified := *booking
+	modified.RoomID = room.ID
+	modified.FromDate = stay.FromDate
+	modified.TillDate = stay.TillDate
+	modified.NumPersons = stay.NumPersons
+	modified.Price = price
+	if room.ID != booking.RoomID {
+		modified.CancellationPolicy = hotel.CancellationPolicyFor(room)
+	}
+	modification := types.BookingModification{
+		At:         now.UTC(),
+		RoomID:     booking.RoomID,
+		FromDate:   booking.FromDate,
+		TillDate:   booking.TillDate,
+		NumPersons: booking.NumPersons,
+		Fee:        booking.ChangeFeeAt(now),
+	}
+	if booking.Price != nil {
+		modification.Total = booking.Price.Total
+	}
+	modified.Modifications = append(append([]types.BookingModification{}, booking.Modifications...), modification)
+
+	// expired holds might be the ones holding the new nights
+	updated, err := h.store.Booking.ModifyBooking(c.Context(), &modified)
+	if errors.Is(err, db.ErrRoomNotAvailable) {
+		expired, expireErr := db.ExpireHolds(c.Context(), h.store, now)
+		if expireErr != nil {
+			err = expireErr
+		} else if len(expired) > 0 {
+			updated, err = h.store.Booking.ModifyBooking(c.Context(), &modified)
+		}
+	}
+	if err != nil {
+		if errors.Is(err, db.ErrRoomNotAvailable) {
+			return NewError(http.StatusConflict, fmt.Sprintf("room %s is not available for the new stay", room.ID.Hex()))
+		}
+		return statusError(err)
+	}
+	moved := room.ID != booking.RoomID || !modified.FromDate.Equal(booking.FromDate) || !modified.TillDate.Equal(booking.TillDate)
+	if moved {
+		if err := offerFreedRoom(c.Context(), h.store, h.publisher, booking, now); err != nil {
+			log.Printf("failed to offer room %s to the waitlist: %v", booking.RoomID.Hex(), err)
+		}
+	}
+
+	updated, err = getPriceConverter(c).booking(updated)
+	if err != nil {
+		return err
+	}
+	return c.JSON(updated)
+}
+
+func (h *BookingHandler) getModifiedRoom(c *fiber.Ctx, booking *types.Booking, id string) (*types.Room, error) {
+	if len(id) == 0 {
+		id = booking.RoomID.Hex()
+	} else if _, err := primitive.ObjectIDFromHex(id); err != nil {
+		return nil, ErrInvalidID()
+	}
+	room, err := h.store.Room.GetRoomByID(c.Context(), id)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return nil, NewError(http.StatusNotFound, fmt.Sprintf("room %s not found", id))
+		}
+		return nil, err
+	}
+	if room.HotelID != booking.HotelID {
+		return nil, NewError(http.StatusUnprocessableEntity, fmt.Sprintf("room %s is not in the hotel of the booking", id))
+	}
+	return room, nil
+}
+
+func (h *BookingHandler) getManagedBooking(c *fiber.Ctx) (*types.Booking, error) {
 	booking, err := h.store.Booking.GetBookingByID(c.Context(), c.Params("id"))
 	if err != nil {
+		return nil, ErrResourceNotFound()
+	}
+	user, err := getAuthUser(c)
+	if err != nil {
+		return nil, ErrUnauthorized()
+	}
+	if !policy.AllowsHotel(user, policy.ManageBookings, booking.HotelID) {
+		return nil, ErrForbidden()
+	}
+	return booking, nil
+}
+
+func (h *BookingHandler) getUserBooking(c *fiber.Ctx) (*types.Booking, error) {
+	booking, err := h.store.Booking.GetBookingByID(c.Context(), c.Params("id"))
+	if err != nil {
+		return nil, ErrResourceNotFound()
+	}
+	user, err := getAuthUser(c)
+	if err != nil {
+		return nil, ErrUnauthorized()
+	}
+	if booking.UserID != user.ID {
+		return nil, ErrForbidden()
+	}
+	return booking, nil
+}
+
+func (h *BookingHandler) HandleConfirmBooking(c *fiber.Ctx) error {
+	booking, err := h.getUserBooking(c)
+	if err != nil {
+		return err
+	}
+	if booking.IsExpired(time.Now()) {
+		if _, err := h.updateStatus(c, types.BookingExpired); err != nil {
+			return err
+		}
+		return NewError(http.StatusConflict, fmt.Sprintf("hold %s has expired", booking.ID.Hex()))
+	}
+
+	booking, err = h.updateStatus(c, types.BookingConfirmed)
+	if err != nil {
+		return err
+	}
+	booking, err = getPriceConverter(c).booking(booking)
+	if err != nil {
+		return err
+	}
+	return c.JSON(booking)
+}
+
+func (h *BookingHandler) HandleCheckIn(c *fiber.Ctx) error {
+	return h.handleArrival(c, types.BookingCheckedIn)
+}
+
+func (h *BookingHandler) HandleCheckOut(c *fiber.Ctx) error {
+	if _, err := h.getManagedBooking(c); err != nil {
+		return err
+	}
+	booking, err := h.updateStatus(c, types.BookingCheckedOut)
+	if err != nil {
+		return err
+	}
+	return c.JSON(booking)
+}
+
+func (h *BookingHandler) HandleNoShow(c *fiber.Ctx) error {
+	return h.handleArrival(c, types.BookingNoShow)
+}
+
+func (h *BookingHandler) handleArrival(c *fiber.Ctx, status types.BookingStatus) error {
+	booking, err := h.getManagedBooking(c)
+	if err != nil {
+		return err
+	}
+	arrival := types.Nights(booking.FromDate, booking.TillDate)[0]
+	if time.Now().Before(arrival) {
+		return NewError(http.StatusConflict, fmt.Sprintf("booking %s starts on %s", booking.ID.Hex(), arrival.Format(dateLayout)))
+	}
+
+	booking, err = h.updateStatus(c, status)
+	if err != nil {
+		return err
+	}
+	return c.JSON(booking)
+}
+
+func (h *BookingHandler) updateStatus(c *fiber.Ctx, status types.BookingStatus) (*types.Booking, error) {
+	booking, err := h.store.Booking.UpdateBookingStatus(c.Context(), c.Params("id"), status)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return booking, nil
+}
+
+func statusError(err error) error {
+	var transitionErr *db.TransitionError
+	if errors.As(err, &transitionErr) {
+		return NewError(http.StatusConflict, transitionErr.Error())
+	}
+	if errors.Is(err, db.ErrNotFound) {
 		return ErrResourceNotFound()
 	}
+	return err
+}
+
+const maxPageLimit = 100
 
+// Scope is upcoming, past or cancelled. Sort is created, fromDate or tillDate,
+// prefixed with a dash for descending order.
+type BookingQueryParams struct {
+	db.Pagination
+	Scope   string
+	HotelID string
+	From    string
+	Till    string
+	Sort    string
+}
+
+type AdminBookingQueryParams struct {
+	BookingQueryParams
+	UserID string
+}
+
+func (p *BookingQueryParams) filter(now time.Time) (db.BookingFilter, error) {
+	var filter db.BookingFilter
+	if len(p.HotelID) > 0 {
+		oid, err := primitive.ObjectIDFromHex(p.HotelID)
+		if err != nil {
+			return filter, NewError(http.StatusBadRequest, fmt.Sprintf("hotel id %s is invalid", p.HotelID))
+		}
+		filter.HotelID = oid
+	}
+
+	if len(p.From) > 0 || len(p.Till) > 0 {
+		filter.Overlaps = &db.DateRange{}
+	}
+	if len(p.From) > 0 {
+		from, err := time.Parse(dateLayout, p.From)
+		if err != nil {
+			return filter, NewError(http.StatusBadRequest, fmt.Sprintf("from should be a date formatted as %s", dateLayout))
+		}
+		filter.Overlaps.From = from
+	}
+	if len(p.Till) > 0 {
+		till, err := time.Parse(dateLayout, p.Till)
+		if err != nil {
+			return filter, NewError(http.StatusBadRequest, fmt.Sprintf("till should be a date formatted as %s", dateLayout))
+		}
+		if !till.After(filter.Overlaps.From) {
+			return filter, NewError(http.StatusBadRequest, "till should be after from")
+		}
+		filter.Overlaps.Till = till
+	}
+
+	switch p.Scope {
+	case "":
+	case "upcoming":
+		// stays in progress are still upcoming until they are over
+		filter.Statuses = types.ActiveBookingStatuses()
+		if filter.Overlaps == nil {
+			filter.Overlaps = &db.DateRange{}
+		}
+		if filter.Overlaps.From.Before(now) {
+			filter.Overlaps.From = now
+		}
+	case "past":
+		filter.Statuses = []types.BookingStatus{types.BookingConfirmed, types.BookingCheckedIn, types.BookingCheckedOut, types.BookingNoShow}
+		filter.EndedBy = now
+	case "cancelled":
+		filter.Statuses = []types.BookingStatus{types.BookingCancelled}
+	default:
+		return filter, NewError(http.StatusBadRequest, fmt.Sprintf("scope %q is invalid, it should be upcoming, past or cancelled", p.Scope))
+	}
+
+	filter.SortBy = db.SortByFromDate
+	if len(p.Sort) > 0 {
+		filter.SortBy = db.BookingSort(strings.TrimPrefix(p.Sort, "-"))
+		filter.SortDesc = strings.HasPrefix(p.Sort, "-")
+		if !filter.SortBy.IsValid() {
+			return filter, NewError(http.StatusBadRequest, fmt.Sprintf("sort %q is invalid, it should be created, fromDate or tillDate", p.Sort))
+		}
+	}
+
+	if p.Page < 1 {
+		p.Page = 1
+	}
+	if p.Limit < 1 {
+		p.Limit = defaultPageLimit
+	}
+	if p.Limit > maxPageLimit {
+		p.Limit = maxPageLimit
+	}
+	return filter, nil
+}
+
+func (h *BookingHandler) HandleGetUserBookings(c *fiber.Ctx) error {
+	var params BookingQueryParams
+	if err := c.QueryParser(&params); err != nil {
+		return ErrBadRequest()
+	}
+	filter, err := params.filter(time.Now())
+	if err != nil {
+		return err
+	}
 	user, err := getAuthUser(c)
 	if err != nil {
 		return ErrUnauthorized()
 	}
+	filter.UserID = user.ID
+	return h.listBookings(c, filter, params.Pagination)
+}
 
-	if booking.UserID != user.ID {
+func (h *BookingHandler) HandleGetBookings(c *fiber.Ctx) error {
+	var params AdminBookingQueryParams
+	if err := c.QueryParser(&params); err != nil {
+		return ErrBadRequest()
+	}
+	filter, err := params.filter(time.Now())
+	if err != nil {
+		return err
+	}
+	if len(params.UserID) > 0 {
+		oid, err := primitive.ObjectIDFromHex(params.UserID)
+		if err != nil {
+			return NewError(http.StatusBadRequest, fmt.Sprintf("user id %s is invalid", params.UserID))
+		}
+		filter.UserID = oid
+	}
+	user, err := getAuthUser(c)
+	if err != nil {
+		return ErrUnauthorized()
+	}
+	if hotelIDs, scoped := policy.Hotels(user, policy.ViewBookings); scoped {
+		if !filter.HotelID.IsZero() && !policy.AllowsHotel(user, policy.ViewBookings, filter.HotelID) {
+			return ErrForbidden()
+		}
+		filter.HotelIDs = hotelIDs
+	}
+	return h.listBookings(c, filter, params.Pagination)
+}
+
+func (h *BookingHandler) listBookings(c *fiber.Ctx, filter db.BookingFilter, pag db.Pagination) error {
+	bookings, err := h.store.Booking.GetBookings(c.Context(), filter, &pag)
+	if err != nil {
+		return err
+	}
+	bookings, err = getPriceConverter(c).bookings(bookings)
+	if err != nil {
+		return err
+	}
+	return c.JSON(ResourceResp{
+		Data:    bookings,
+		Results: len(bookings),
+		Page:    int(pag.Page),
+	})
+}
+
+type LookupBookingParams struct {
+	ConfirmationCode string `json:"confirmationCode"`
+	LastName         string `json:"lastName"`
+}
+
+func (p LookupBookingParams) Validate() map[string]string {
+	errors := make(map[string]string)
+	if len(strings.TrimSpace(p.ConfirmationCode)) == 0 {
+		errors["confirmationCode"] = "confirmationCode is required"
+	}
+	if len(strings.TrimSpace(p.LastName)) == 0 {
+		errors["lastName"] = "lastName is required"
+	}
+	return errors
+}
+
+// HandleLookupBooking answers the same whichever field is wrong, so codes cannot
+// be probed. The code of a reservation finds the reservation and its bookings.
+func (h *BookingHandler) HandleLookupBooking(c *fiber.Ctx) error {
+	var params LookupBookingParams
+	if err := c.BodyParser(&params); err != nil {
+		return ErrBadRequest()
+	}
+	if errors := params.Validate(); len(errors) > 0 {
+		return c.Status(http.StatusBadRequest).JSON(errors)
+	}
+
+	notFound := NewError(http.StatusNotFound, "no booking matches the confirmation code and last name")
+	booking, err := h.store.Booking.GetBookingByConfirmationCode(c.Context(), params.ConfirmationCode)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return notFound
+		}
+		return err
+	}
+	user, err := h.store.User.GetUserByID(c.Context(), booking.UserID.Hex())
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return notFound
+		}
+		return err
+	}
+	if !strings.EqualFold(strings.TrimSpace(params.LastName), user.LastName) {
+		return notFound
+	}
+
+	if !booking.ReservationID.IsZero() {
+		reservation, err := h.store.Reservation.GetReservationByID(c.Context(), booking.ReservationID.Hex())
+		if err != nil {
+			return err
+		}
+		if reservation.ConfirmationCode == booking.ConfirmationCode {
+			return h.respondReservation(c, reservation)
+		}
+	}
+	booking, err = getPriceConverter(c).booking(booking)
+	if err != nil {
+		return err
+	}
+	return c.JSON(booking)
+}
+
+func (h *BookingHandler) respondReservation(c *fiber.Ctx, reservation *types.Reservation) error {
+	filter := db.BookingFilter{ReservationID: reservation.ID, SortBy: db.SortByCreation}
+	bookings, err := h.store.Booking.GetBookings(c.Context(), filter, nil)
+	if err != nil {
+		return err
+	}
+	conv := getPriceConverter(c)
+	if reservation, err = conv.reservation(reservation); err != nil {
+		return err
+	}
+	if bookings, err = conv.bookings(bookings); err != nil {
+		return err
+	}
+	return c.JSON(ReservationResp{Reservation: reservation, Bookings: bookings})
+}
+
+func (h *BookingHandler) HandleGetBooking(c *fiber.Ctx) error {
+	booking, err := h.store.Booking.GetBookingByID(c.Context(), c.Params("id"))
+	if err != nil {
+		return ErrResourceNotFound()
+	}
+	user, err := getAuthUser(c)
+	if err != nil {
 		return ErrUnauthorized()
 	}
+	if !policy.CanViewBooking(user, booking) {
+		return ErrForbidden()
+	}
 
+	booking, err = getPriceConverter(c).booking(booking)
+	if err != nil {
+		return err
+	}
 	return c.JSON(booking)
 }
@@ -1,15 +1,23 @@
 package api
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/raphaelmb/go-hotel-reservation/db"
 	"github.com/raphaelmb/go-hotel-reservation/db/fixtures"
+	"github.com/raphaelmb/go-hotel-reservation/events"
+	"github.com/raphaelmb/go-hotel-reservation/policy"
 	"github.com/raphaelmb/go-hotel-reservation/types"
 )
 
@@ -26,51 +34,80 @@ func TestUserCancelBooking(t *testing.T) {
 		from           = time.Now()
 		till           = from.AddDate(0, 0, 2)
 		booking        = fixtures.AddBooking(db.Store, user.ID, room.ID, from, till)
-		bookingHandler = NewBookingHandler(db.Store)
+		bookingHandler = NewBookingHandler(db.Store, &events.Recorder{})
 
 		app   = fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
-		route = app.Group("/", JWTAuthentication(db.User))
+		route = app.Group("/", JWTAuthentication(db.User, db.Token, testTokens, nil))
 	)
+	route.Post("/:id/cancel", bookingHandler.HandleCancelBooking)
+	route.Get("/:id/cancel", bookingHandler.HandleDeprecatedCancelBooking)
 
-	t.Run("should be able to cancel a booking", func(t *testing.T) {
-		route.Get("/:id/cancel", bookingHandler.HandleCancelBooking)
-		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/%s/cancel", booking.ID.Hex()), nil)
-		req.Header.Add("X-Api-Token", CreateTokenFromUser(user))
+	cancel := func(t *testing.T, method string, booking *types.Booking, token, reason string) *http.Response {
+		b, _ := json.Marshal(CancelBookingParams{Reason: reason})
+		req := httptest.NewRequest(method, fmt.Sprintf("/%s/cancel", booking.ID.Hex()), bytes.NewReader(b))
+		req.Header.Add("Content-Type", "application/json")
+		req.Header.Add("X-Api-Token", token)
 		resp, err := app.Test(req)
 		if err != nil {
 			t.Fatal(err)
 		}
-
-		if resp.StatusCode != http.StatusOK {
-			t.Fatalf("expected 200 response but got %d", resp.StatusCode)
-		}
-	})
+		return resp
+	}
 
 	t.Run("should not be able to cancel a booking with another user", func(t *testing.T) {
-		route.Get("/:id/cancel", bookingHandler.HandleCancelBooking)
-		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/%s/cancel", booking.ID.Hex()), nil)
-		req.Header.Add("X-Api-Token", CreateTokenFromUser(otherUser))
-		resp, err := app.Test(req)
-		if err != nil {
-			t.Fatal(err)
+		resp := cancel(t, http.MethodPost, booking, createToken(otherUser), "")
+		if resp.StatusCode != http.StatusForbidden {
+			t.Fatalf("expected 403 response but got %d", resp.StatusCode)
 		}
+	})
 
+	t.Run("should not be able to cancel a booking unauthenticated", func(t *testing.T) {
+		resp := cancel(t, http.MethodPost, booking, "", "")
 		if resp.StatusCode != http.StatusUnauthorized {
 			t.Fatalf("expected 401 response but got %d", resp.StatusCode)
 		}
 	})
 
-	t.Run("should not be able to cancel a booking unauthenticated", func(t *testing.T) {
-		route.Get("/:id/cancel", bookingHandler.HandleCancelBooking)
-		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/%s/cancel", booking.ID.Hex()), nil)
-		req.Header.Add("X-Api-Token", "")
-		resp, err := app.Test(req)
+	t.Run("should be able to cancel a booking", func(t *testing.T) {
+		resp := cancel(t, http.MethodPost, booking, createToken(user), "change of plans")
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200 response but got %d", resp.StatusCode)
+		}
+		if len(resp.Header.Get("Deprecation")) > 0 {
+			t.Fatalf("expected no deprecation header")
+		}
+		stored, err := db.Booking.GetBookingByID(context.Background(), booking.ID.Hex())
 		if err != nil {
 			t.Fatal(err)
 		}
+		if stored.Cancellation == nil || stored.Cancellation.By != user.ID || stored.Cancellation.Reason != "change of plans" {
+			t.Fatalf("expected the cancellation by %s to be recorded but got %+v", user.ID.Hex(), stored.Cancellation)
+		}
+	})
 
-		if resp.StatusCode != http.StatusUnauthorized {
-			t.Fatalf("expected 401 response but got %d", resp.StatusCode)
+	t.Run("should not cancel a booking twice", func(t *testing.T) {
+		resp := cancel(t, http.MethodPost, booking, createToken(user), "")
+		if resp.StatusCode != http.StatusConflict {
+			t.Fatalf("expected 409 response but got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("should not cancel a past booking", func(t *testing.T) {
+		past := fixtures.AddBooking(db.Store, user.ID, room.ID, from.AddDate(0, 0, -5), from.AddDate(0, 0, -3))
+		resp := cancel(t, http.MethodPost, past, createToken(user), "")
+		if resp.StatusCode != http.StatusConflict {
+			t.Fatalf("expected 409 response but got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("should flag cancellations with GET as deprecated", func(t *testing.T) {
+		next := fixtures.AddBooking(db.Store, user.ID, room.ID, from.AddDate(0, 0, 10), from.AddDate(0, 0, 12))
+		resp := cancel(t, http.MethodGet, next, createToken(user), "")
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200 response but got %d", resp.StatusCode)
+		}
+		if resp.Header.Get("Deprecation") != "true" {
+			t.Fatalf("expected a deprecation header but got %q", resp.Header.Get("Deprecation"))
 		}
 	})
 }
@@ -88,16 +125,16 @@ func TestUserGetBookings(t *testing.T) {
 		from           = time.Now()
 		till           = from.AddDate(0, 0, 2)
 		booking        = fixtures.AddBooking(db.Store, user.ID, room.ID, from, till)
-		bookingHandler = NewBookingHandler(db.Store)
+		bookingHandler = NewBookingHandler(db.Store, &events.Recorder{})
 
 		app   = fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
-		route = app.Group("/", JWTAuthentication(db.User))
+		route = app.Group("/", JWTAuthentication(db.User, db.Token, testTokens, nil))
 	)
 
 	t.Run("user should be able to get booking", func(t *testing.T) {
 		route.Get("/:id", bookingHandler.HandleGetBooking)
 		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/%s", booking.ID.Hex()), nil)
-		req.Header.Add("X-Api-Token", CreateTokenFromUser(user))
+		req.Header.Add("X-Api-Token", createToken(user))
 		resp, err := app.Test(req)
 		if err != nil {
 			t.Fatal(err)
@@ -123,7 +160,7 @@ func TestUserGetBookings(t *testing.T) {
 	t.Run("different user should be able to get booking", func(t *testing.T) {
 		route.Get("/:id", bookingHandler.HandleGetBooking)
 		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/%s", booking.ID.Hex()), nil)
-		req.Header.Add("X-Api-Token", CreateTokenFromUser(otherUser))
+		req.Header.Add("X-Api-Token", createToken(otherUser))
 		resp, err := app.Test(req)
 		if err != nil {
 			t.Fatal(err)
@@ -136,6 +173,94 @@ func TestUserGetBookings(t *testing.T) {
 
 }
 
+func TestListBookings(t *testing.T) {
+	db := setup(t)
+	defer db.tearDown(t)
+
+	var (
+		adminUser  = fixtures.AddUser(db.Store, "admin", "admin", true)
+		user       = fixtures.AddUser(db.Store, "james", "foo", false)
+		otherUser  = fixtures.AddUser(db.Store, "another", "user", false)
+		hotel      = fixtures.AddHotel(db.Store, "hotel", "anywhere", 4, nil)
+		room       = fixtures.AddRoom(db.Store, "small", true, 5.5, hotel.ID)
+		otherHotel = fixtures.AddHotel(db.Store, "other", "anywhere", 4, nil)
+		otherRoom  = fixtures.AddRoom(db.Store, "small", true, 5.5, otherHotel.ID)
+
+		bookingHandler = NewBookingHandler(db.Store, &events.Recorder{})
+		app            = fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+		admin          = app.Group("/admin", JWTAuthentication(db.User, db.Token, testTokens, nil), Require(policy.ViewBookings))
+		route          = app.Group("/", JWTAuthentication(db.User, db.Token, testTokens, nil))
+	)
+	admin.Get("/", bookingHandler.HandleGetBookings)
+	route.Get("/", bookingHandler.HandleGetUserBookings)
+
+	day := func(days int) time.Time {
+		return time.Now().UTC().AddDate(0, 0, days)
+	}
+	var (
+		later     = fixtures.AddBooking(db.Store, user.ID, room.ID, day(10), day(12))
+		sooner    = fixtures.AddBooking(db.Store, user.ID, otherRoom.ID, day(5), day(7))
+		past      = fixtures.AddBooking(db.Store, user.ID, room.ID, day(-10), day(-8))
+		cancelled = fixtures.AddBooking(db.Store, user.ID, room.ID, day(20), day(22))
+		others    = fixtures.AddBooking(db.Store, otherUser.ID, room.ID, day(30), day(32))
+	)
+	if _, err := db.Booking.UpdateBookingStatus(context.Background(), cancelled.ID.Hex(), types.BookingCancelled); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		target string
+		user   *types.User
+		status int
+		want   []*types.Booking
+	}{
+		{"should list the bookings of the user by arrival", "/", user, http.StatusOK, []*types.Booking{past, sooner, later, cancelled}},
+		{"should list upcoming bookings", "/?scope=upcoming", user, http.StatusOK, []*types.Booking{sooner, later}},
+		{"should list past bookings", "/?scope=past", user, http.StatusOK, []*types.Booking{past}},
+		{"should list cancelled bookings", "/?scope=cancelled", user, http.StatusOK, []*types.Booking{cancelled}},
+		{"should filter by hotel", "/?hotelID=" + otherHotel.ID.Hex(), user, http.StatusOK, []*types.Booking{sooner}},
+		{"should filter by dates", "/?from=" + day(8).Format(dateLayout) + "&till=" + day(25).Format(dateLayout), user, http.StatusOK, []*types.Booking{later, cancelled}},
+		{"should sort in descending order", "/?sort=-fromDate", user, http.StatusOK, []*types.Booking{cancelled, later, sooner, past}},
+		{"should paginate", "/?limit=2&page=2", user, http.StatusOK, []*types.Booking{later, cancelled}},
+		{"should reject an unknown scope", "/?scope=soon", user, http.StatusBadRequest, nil},
+		{"should reject an unknown sort", "/?sort=price", user, http.StatusBadRequest, nil},
+		{"should reject an invalid hotel id", "/?hotelID=nope", user, http.StatusBadRequest, nil},
+		{"admin should list every booking", "/admin?sort=created", adminUser, http.StatusOK, []*types.Booking{later, sooner, past, cancelled, others}},
+		{"admin should filter by user", "/admin?userID=" + otherUser.ID.Hex(), adminUser, http.StatusOK, []*types.Booking{others}},
+		{"admin should combine filters", "/admin?scope=upcoming&hotelID=" + hotel.ID.Hex(), adminUser, http.StatusOK, []*types.Booking{later, others}},
+		{"non admin should not list every booking", "/admin", user, http.StatusForbidden, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			req.Header.Add("X-Api-Token", createToken(tt.user))
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.status {
+				t.Fatalf("expected %d response but got %d", tt.status, resp.StatusCode)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+			var bookings []*types.Booking
+			if err := json.NewDecoder(resp.Body).Decode(&ResourceResp{Data: &bookings}); err != nil {
+				t.Fatal(err)
+			}
+			if len(bookings) != len(tt.want) {
+				t.Fatalf("expected %d bookings but got %d", len(tt.want), len(bookings))
+			}
+			for i, booking := range bookings {
+				if booking.ID != tt.want[i].ID {
+					t.Fatalf("expected booking %s at %d but got %s", tt.want[i].ID.Hex(), i, booking.ID.Hex())
+				}
+			}
+		})
+	}
+}
+
 func TestAdminGetBookings(t *testing.T) {
 	db := setup(t)
 	defer db.tearDown(t)
@@ -149,16 +274,16 @@ func TestAdminGetBookings(t *testing.T) {
 		from           = time.Now()
 		till           = from.AddDate(0, 0, 2)
 		booking        = fixtures.AddBooking(db.Store, user.ID, room.ID, from, till)
-		bookingHandler = NewBookingHandler(db.Store)
+		bookingHandler = NewBookingHandler(db.Store, &events.Recorder{})
 
 		app   = fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
-		admin = app.Group("/", JWTAuthentication(db.User), AdminAuth)
+		admin = app.Group("/", JWTAuthentication(db.User, db.Token, testTokens, nil), Require(policy.ViewBookings))
 	)
 
 	t.Run("admin should be able to get bookings", func(t *testing.T) {
 		admin.Get("/", bookingHandler.HandleGetBookings)
 		req := httptest.NewRequest(http.MethodGet, "/", nil)
-		req.Header.Add("X-Api-Token", CreateTokenFromUser(adminUser))
+		req.Header.Add("X-Api-Token", createToken(adminUser))
 		resp, err := app.Test(req)
 		if err != nil {
 			t.Fatal(err)
@@ -167,7 +292,7 @@ func TestAdminGetBookings(t *testing.T) {
 			t.Fatalf("expected 200 response but got %d", resp.StatusCode)
 		}
 		var bookings []*types.Booking
-		if err := json.NewDecoder(resp.Body).Decode(&bookings); err != nil {
+		if err := json.NewDecoder(resp.Body).Decode(&ResourceResp{Data: &bookings}); err != nil {
 			t.Fatal(err)
 		}
 		if len(bookings) != 1 {
@@ -184,13 +309,625 @@ func TestAdminGetBookings(t *testing.T) {
 	t.Run("non admin should not be able to get bookings", func(t *testing.T) {
 		admin.Get("/", bookingHandler.HandleGetBookings)
 		req := httptest.NewRequest(http.MethodGet, "/", nil)
-		req.Header.Add("X-Api-Token", CreateTokenFromUser(user))
+		req.Header.Add("X-Api-Token", createToken(user))
 		resp, err := app.Test(req)
 		if err != nil {
 			t.Fatal(err)
 		}
-		if resp.StatusCode != http.StatusUnauthorized {
-			t.Fatalf("expected 401 response but got %d", resp.StatusCode)
+		if resp.StatusCode != http.StatusForbidden {
+			t.Fatalf("expected 403 response but got %d", resp.StatusCode)
+		}
+	})
+}
+
+func TestAdminBookingLifecycle(t *testing.T) {
+	db := setup(t)
+	defer db.tearDown(t)
+
+	var (
+		adminUser = fixtures.AddUser(db.Store, "admin", "admin", true)
+		user      = fixtures.AddUser(db.Store, "james", "foo", false)
+		hotel     = fixtures.AddHotel(db.Store, "hotel", "anywhere", 4, nil)
+		room      = fixtures.AddRoom(db.Store, "small", true, 5.5, hotel.ID)
+
+		from           = time.Now()
+		till           = from.AddDate(0, 0, 2)
+		booking        = fixtures.AddBooking(db.Store, user.ID, room.ID, from, till)
+		bookingHandler = NewBookingHandler(db.Store, &events.Recorder{})
+
+		app   = fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+		admin = app.Group("/admin", JWTAuthentication(db.User, db.Token, testTokens, nil), Require(policy.ManageBookings))
+		route = app.Group("/", JWTAuthentication(db.User, db.Token, testTokens, nil))
+	)
+	admin.Post("/:id/check-in", bookingHandler.HandleCheckIn)
+	admin.Post("/:id/check-out", bookingHandler.HandleCheckOut)
+	admin.Post("/:id/no-show", bookingHandler.HandleNoShow)
+	route.Post("/:id/cancel", bookingHandler.HandleCancelBooking)
+
+	send := func(t *testing.T, method, path string, user *types.User) (*http.Response, *types.Booking) {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Add("X-Api-Token", createToken(user))
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			return resp, nil
+		}
+		var updated *types.Booking
+		json.NewDecoder(resp.Body).Decode(&updated)
+		return resp, updated
+	}
+
+	if booking.Status != types.BookingConfirmed {
+		t.Fatalf("expected new booking to be %s but got %s", types.BookingConfirmed, booking.Status)
+	}
+
+	t.Run("should check in a confirmed booking", func(t *testing.T) {
+		resp, updated := send(t, http.MethodPost, "/admin/"+booking.ID.Hex()+"/check-in", adminUser)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200 response but got %d", resp.StatusCode)
+		}
+		if updated.Status != types.BookingCheckedIn {
+			t.Fatalf("expected status %s but got %s", types.BookingCheckedIn, updated.Status)
+		}
+		if _, ok := updated.StatusChangedAt(types.BookingCheckedIn); !ok {
+			t.Fatalf("expected check in to be recorded in %v", updated.StatusHistory)
+		}
+	})
+
+	t.Run("should not cancel a checked in booking", func(t *testing.T) {
+		resp, _ := send(t, http.MethodPost, "/"+booking.ID.Hex()+"/cancel", user)
+		if resp.StatusCode != http.StatusConflict {
+			t.Fatalf("expected 409 response but got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("should check out a checked in booking", func(t *testing.T) {
+		resp, updated := send(t, http.MethodPost, "/admin/"+booking.ID.Hex()+"/check-out", adminUser)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200 response but got %d", resp.StatusCode)
+		}
+		if updated.Status != types.BookingCheckedOut {
+			t.Fatalf("expected status %s but got %s", types.BookingCheckedOut, updated.Status)
+		}
+		if len(updated.StatusHistory) != 3 {
+			t.Fatalf("expected 3 status changes but got %v", updated.StatusHistory)
+		}
+	})
+
+	t.Run("should not mark a checked out booking as no show", func(t *testing.T) {
+		resp, _ := send(t, http.MethodPost, "/admin/"+booking.ID.Hex()+"/no-show", adminUser)
+		if resp.StatusCode != http.StatusConflict {
+			t.Fatalf("expected 409 response but got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("should not check in before the day of arrival", func(t *testing.T) {
+		future := fixtures.AddBooking(db.Store, user.ID, room.ID, from.AddDate(0, 0, 5), till.AddDate(0, 0, 5))
+		resp, _ := send(t, http.MethodPost, "/admin/"+future.ID.Hex()+"/check-in", adminUser)
+		if resp.StatusCode != http.StatusConflict {
+			t.Fatalf("expected 409 response but got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("no show should release the nights", func(t *testing.T) {
+		other := fixtures.AddRoom(db.Store, "small", false, 5.5, hotel.ID)
+		absent := fixtures.AddBooking(db.Store, user.ID, other.ID, from, till)
+		resp, updated := send(t, http.MethodPost, "/admin/"+absent.ID.Hex()+"/no-show", adminUser)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200 response but got %d", resp.StatusCode)
+		}
+		if updated.Status != types.BookingNoShow {
+			t.Fatalf("expected status %s but got %s", types.BookingNoShow, updated.Status)
+		}
+		if _, err := db.Booking.InsertBooking(context.Background(), &types.Booking{
+			UserID:   user.ID,
+			RoomID:   other.ID,
+			FromDate: from,
+			TillDate: till,
+		}); err != nil {
+			t.Fatalf("expected room to be free again but got %v", err)
+		}
+	})
+
+	t.Run("non admin should not check in", func(t *testing.T) {
+		resp, _ := send(t, http.MethodPost, "/admin/"+booking.ID.Hex()+"/check-in", user)
+		if resp.StatusCode != http.StatusForbidden {
+			t.Fatalf("expected 403 response but got %d", resp.StatusCode)
+		}
+	})
+}
+
+func TestCancellationPolicy(t *testing.T) {
+	db := setup(t)
+	defer db.tearDown(t)
+
+	var (
+		user          = fixtures.AddUser(db.Store, "james", "foo", false)
+		hotel         = fixtures.AddHotel(db.Store, "hotel", "anywhere", 4, nil)
+		room          = fixtures.AddRoom(db.Store, "small", true, 100, hotel.ID)
+		nonRefundable = fixtures.AddRoom(db.Store, "large", true, 100, hotel.ID)
+
+		roomHandler    = NewRoomHandler(db.Store)
+		bookingHandler = NewBookingHandler(db.Store, &events.Recorder{})
+		app            = fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+		route          = app.Group("/", JWTAuthentication(db.User, db.Token, testTokens, nil))
+	)
+	route.Post("/:id/book", roomHandler.HandleBookRoom)
+	route.Post("/:id/hold", roomHandler.HandleHoldRoom)
+	route.Post("/booking/:id/cancel", bookingHandler.HandleCancelBooking)
+	route.Get("/booking/:id/cancel-preview", bookingHandler.HandleCancelPreview)
+
+	ctx := context.Background()
+	policy := types.UpdateHotelParams{CancellationPolicy: &types.CancellationPolicy{FreeDays: 7, PenaltyPercent: 50}}
+	if err := db.Hotel.Update(ctx, hotel.ID.Hex(), policy); err != nil {
+		t.Fatal(err)
+	}
+	override := types.UpdateRoomParams{CancellationPolicy: &types.CancellationPolicy{NonRefundable: true}}
+	if err := db.Room.UpdateRoom(ctx, nonRefundable.ID.Hex(), override); err != nil {
+		t.Fatal(err)
+	}
+
+	send := func(t *testing.T, req *http.Request, v any) *http.Response {
+		req.Header.Set("X-Api-Token", createToken(user))
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode == http.StatusOK {
+			if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return resp
+	}
+	book := func(t *testing.T, room *types.Room, days int) *types.Booking {
+		from := time.Now().AddDate(0, 0, days)
+		req := bookRoomRequest(room, user, from, from.AddDate(0, 0, 2))
+		var booking *types.Booking
+		if resp := send(t, req, &booking); resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200 response but got %d", resp.StatusCode)
+		}
+		return booking
+	}
+
+	var (
+		early = book(t, room, 30)
+		late  = book(t, room, 3)
+		final = book(t, nonRefundable, 30)
+	)
+	// changing the policy does not affect existing bookings
+	policy.CancellationPolicy = &types.CancellationPolicy{NonRefundable: true}
+	if err := db.Hotel.Update(ctx, hotel.ID.Hex(), policy); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		booking  *types.Booking
+		penalty  float64
+		freeDays bool
+	}{
+		{"free before the deadline", early, 0, true},
+		{"penalty after the deadline", late, 100, true},
+		{"non refundable room", final, 200, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/booking/"+tt.booking.ID.Hex()+"/cancel-preview", nil)
+			var preview CancellationPreview
+			if resp := send(t, req, &preview); resp.StatusCode != http.StatusOK {
+				t.Fatalf("expected 200 response but got %d", resp.StatusCode)
+			}
+			var (
+				penalty = types.NewMoney(tt.penalty, types.DefaultCurrency)
+				refund  = types.NewMoney(200-tt.penalty, types.DefaultCurrency)
+			)
+			if preview.Penalty != penalty || preview.Refund != refund {
+				t.Fatalf("expected penalty %s and refund %s but got %s and %s", penalty, refund, preview.Penalty, preview.Refund)
+			}
+			if (preview.FreeUntil != nil) != tt.freeDays {
+				t.Fatalf("expected free until to be set: %v, but got %v", tt.freeDays, preview.FreeUntil)
+			}
+		})
+	}
+
+	t.Run("should record the penalty and refund on cancellation", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/booking/"+late.ID.Hex()+"/cancel", nil)
+		var cancelled *types.Booking
+		if resp := send(t, req, &cancelled); resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200 response but got %d", resp.StatusCode)
+		}
+		stored, err := db.Booking.GetBookingByID(ctx, late.ID.Hex())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stored.Status != types.BookingCancelled || stored.Cancellation == nil {
+			t.Fatalf("expected a cancelled booking with a cancellation but got %s and %+v", stored.Status, stored.Cancellation)
+		}
+		if want := types.NewMoney(100, types.DefaultCurrency); stored.Cancellation.Refund != want {
+			t.Fatalf("expected refund %s but got %s", want, stored.Cancellation.Refund)
+		}
+	})
+
+	t.Run("should refund nothing for a hold", func(t *testing.T) {
+		from := time.Now().AddDate(0, 0, 40)
+		var hold *types.Booking
+		if resp := send(t, holdRoomRequest(room, user, from, from.AddDate(0, 0, 2)), &hold); resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200 response but got %d", resp.StatusCode)
+		}
+		var preview CancellationPreview
+		req := httptest.NewRequest(http.MethodGet, "/booking/"+hold.ID.Hex()+"/cancel-preview", nil)
+		if resp := send(t, req, &preview); resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200 response but got %d", resp.StatusCode)
+		}
+		zero := types.Money{Currency: types.DefaultCurrency}
+		if preview.Penalty != zero || preview.Refund != zero {
+			t.Fatalf("expected no penalty nor refund but got %s and %s", preview.Penalty, preview.Refund)
+		}
+	})
+
+	t.Run("should not preview the cancellation of a cancelled booking", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/booking/"+late.ID.Hex()+"/cancel-preview", nil)
+		if resp := send(t, req, nil); resp.StatusCode != http.StatusConflict {
+			t.Fatalf("expected 409 response but got %d", resp.StatusCode)
+		}
+	})
+}
+
+func modifyBookingRequest(booking *types.Booking, user *types.User, params ModifyBookingParams) *http.Request {
+	b, _ := json.Marshal(params)
+	req := httptest.NewRequest(http.MethodPatch, "/booking/"+booking.ID.Hex(), bytes.NewReader(b))
+	req.Header.Add("Content-Type", "application/json")
+	req.Header.Add("X-Api-Token", createToken(user))
+	return req
+}
+
+func TestModifyBooking(t *testing.T) {
+	tdb := setup(t)
+	defer tdb.tearDown(t)
+
+	var (
+		user       = fixtures.AddUser(tdb.Store, "james", "foo", false)
+		otherUser  = fixtures.AddUser(tdb.Store, "another", "user", false)
+		hotel      = fixtures.AddHotel(tdb.Store, "hotel", "anywhere", 4, nil)
+		room       = fixtures.AddRoom(tdb.Store, "small", true, 100, hotel.ID)
+		otherRoom  = fixtures.AddRoom(tdb.Store, "large", true, 100, hotel.ID)
+		otherHotel = fixtures.AddHotel(tdb.Store, "other", "anywhere", 4, nil)
+		farRoom    = fixtures.AddRoom(tdb.Store, "small", true, 100, otherHotel.ID)
+
+		roomHandler    = NewRoomHandler(tdb.Store)
+		bookingHandler = NewBookingHandler(tdb.Store, &events.Recorder{})
+		app            = fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+		route          = app.Group("/", JWTAuthentication(tdb.User, tdb.Token, testTokens, nil))
+	)
+	route.Post("/:id/book", roomHandler.HandleBookRoom)
+	route.Patch("/booking/:id", bookingHandler.HandleModifyBooking)
+	route.Post("/booking/:id/cancel", bookingHandler.HandleCancelBooking)
+
+	ctx := context.Background()
+	policy := types.UpdateHotelParams{CancellationPolicy: &types.CancellationPolicy{FreeDays: 7, ChangeFeePercent: 10}}
+	if err := tdb.Hotel.Update(ctx, hotel.ID.Hex(), policy); err != nil {
+		t.Fatal(err)
+	}
+
+	send := func(t *testing.T, req *http.Request, status int) *types.Booking {
+		resp, err := app.Test(req, -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != status {
+			t.Fatalf("expected %d response but got %d", status, resp.StatusCode)
+		}
+		var booking *types.Booking
+		if status == http.StatusOK {
+			if err := json.NewDecoder(resp.Body).Decode(&booking); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return booking
+	}
+	day := func(days int) time.Time {
+		return time.Now().UTC().Truncate(time.Second).AddDate(0, 0, days)
+	}
+	book := func(t *testing.T, user *types.User, room *types.Room, days int) *types.Booking {
+		return send(t, bookRoomRequest(room, user, day(days), day(days+2)), http.StatusOK)
+	}
+	// holds reports whether another booking of the nights would be refused
+	holds := func(t *testing.T, room *types.Room, from, till time.Time) bool {
+		_, err := tdb.Booking.InsertBooking(ctx, &types.Booking{UserID: otherUser.ID, RoomID: room.ID, HotelID: room.HotelID, FromDate: from, TillDate: till})
+		if err == nil {
+			return false
+		}
+		if !errors.Is(err, db.ErrRoomNotAvailable) {
+			t.Fatal(err)
+		}
+		return true
+	}
+
+	t.Run("should shift the dates over the nights it holds", func(t *testing.T) {
+		booking := book(t, user, room, 30)
+		from, till := day(31), day(33)
+		modified := send(t, modifyBookingRequest(booking, user, ModifyBookingParams{FromDate: &from, TillDate: &till}), http.StatusOK)
+
+		if !modified.FromDate.Equal(from) || !modified.TillDate.Equal(till) {
+			t.Fatalf("expected the stay to be %s to %s but got %s to %s", from, till, modified.FromDate, modified.TillDate)
+		}
+		if len(modified.Modifications) != 1 {
+			t.Fatalf("expected 1 modification but got %d", len(modified.Modifications))
+		}
+		change := modified.Modifications[0]
+		if !change.FromDate.Equal(booking.FromDate) || change.Total != booking.Price.Total || change.Fee.Amount != 0 {
+			t.Fatalf("expected the previous stay without a fee but got %+v", change)
+		}
+		if holds(t, room, day(30), day(31)) {
+			t.Fatal("expected the first night of the previous stay to be released")
+		}
+		if !holds(t, room, day(32), day(33)) {
+			t.Fatal("expected the last night of the new stay to be held")
+		}
+	})
+
+	t.Run("should keep the booking when the new dates are taken", func(t *testing.T) {
+		booking := book(t, user, room, 40)
+		book(t, otherUser, room, 44)
+		from, till := day(43), day(45)
+		send(t, modifyBookingRequest(booking, user, ModifyBookingParams{FromDate: &from, TillDate: &till}), http.StatusConflict)
+
+		stored, err := tdb.Booking.GetBookingByID(ctx, booking.ID.Hex())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !stored.FromDate.Equal(booking.FromDate) || len(stored.Modifications) != 0 {
+			t.Fatalf("expected the booking to be unchanged but got %+v", stored)
+		}
+		if !holds(t, room, day(40), day(42)) {
+			t.Fatal("expected the booking to still hold its nights")
+		}
+		if holds(t, room, day(43), day(44)) {
+			t.Fatal("expected the nights claimed for the failed change to be released")
+		}
+	})
+
+	t.Run("should move the booking to another room of the hotel", func(t *testing.T) {
+		booking := book(t, user, room, 50)
+		modified := send(t, modifyBookingRequest(booking, user, ModifyBookingParams{RoomID: otherRoom.ID.Hex()}), http.StatusOK)
+		if modified.RoomID != otherRoom.ID {
+			t.Fatalf("expected room %s but got %s", otherRoom.ID.Hex(), modified.RoomID.Hex())
+		}
+		if holds(t, room, day(50), day(52)) {
+			t.Fatal("expected the nights of the previous room to be released")
+		}
+	})
+
+	t.Run("should charge the change fee after the free days", func(t *testing.T) {
+		booking := book(t, user, room, 3)
+		modified := send(t, modifyBookingRequest(booking, user, ModifyBookingParams{NumPersons: 1}), http.StatusOK)
+		want := booking.Price.Total.Percent(10)
+		if fee := modified.Modifications[0].Fee; fee != want {
+			t.Fatalf("expected a fee of %s but got %s", want, fee)
+		}
+		if modified.NumPersons != 1 {
+			t.Fatalf("expected 1 person but got %d", modified.NumPersons)
+		}
+	})
+
+	var (
+		booking   = book(t, user, room, 60)
+		cancelled = book(t, user, room, 70)
+		past      = day(-1)
+	)
+	req := httptest.NewRequest(http.MethodPost, "/booking/"+cancelled.ID.Hex()+"/cancel", nil)
+	req.Header.Add("X-Api-Token", createToken(user))
+	send(t, req, http.StatusOK)
+
+	tests := []struct {
+		name    string
+		booking *types.Booking
+		user    *types.User
+		params  ModifyBookingParams
+		status  int
+	}{
+		{"no changes", booking, user, ModifyBookingParams{}, http.StatusBadRequest},
+		{"dates in the past", booking, user, ModifyBookingParams{FromDate: &past}, http.StatusBadRequest},
+		{"room of another hotel", booking, user, ModifyBookingParams{RoomID: farRoom.ID.Hex()}, http.StatusUnprocessableEntity},
+		{"party larger than the room", booking, user, ModifyBookingParams{NumPersons: 50}, http.StatusUnprocessableEntity},
+		{"booking of another user", booking, otherUser, ModifyBookingParams{NumPersons: 1}, http.StatusForbidden},
+		{"cancelled booking", cancelled, user, ModifyBookingParams{NumPersons: 1}, http.StatusConflict},
+	}
+	for _, tt := range tests {
+		t.Run("should reject "+tt.name, func(t *testing.T) {
+			send(t, modifyBookingRequest(tt.booking, tt.user, tt.params), tt.status)
+		})
+	}
+
+	t.Run("concurrent changes to the same nights should not both succeed", func(t *testing.T) {
+		const attempts = 5
+		var (
+			wg    sync.WaitGroup
+			mu    sync.Mutex
+			codes = map[int]int{}
+			from  = day(100)
+			till  = day(102)
+		)
+		for i := 0; i < attempts; i++ {
+			req := modifyBookingRequest(book(t, user, room, 80+3*i), user, ModifyBookingParams{FromDate: &from, TillDate: &till})
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				resp, err := app.Test(req, -1)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				mu.Lock()
+				codes[resp.StatusCode]++
+				mu.Unlock()
+			}()
+		}
+		wg.Wait()
+
+		if codes[http.StatusOK] != 1 || codes[http.StatusConflict] != attempts-1 {
+			t.Fatalf("expected 1 change and %d conflicts but got %v", attempts-1, codes)
+		}
+	})
+}
+
+func TestLookupBooking(t *testing.T) {
+	db := setup(t)
+	defer db.tearDown(t)
+
+	var (
+		user    = fixtures.AddUser(db.Store, "james", "Foo", false)
+		hotel   = fixtures.AddHotel(db.Store, "hotel", "anywhere", 4, nil)
+		room    = fixtures.AddRoom(db.Store, "small", true, 5.5, hotel.ID)
+		from    = time.Now().AddDate(0, 0, 5)
+		booking = fixtures.AddBooking(db.Store, user.ID, room.ID, from, from.AddDate(0, 0, 2))
+		other   = fixtures.AddBooking(db.Store, user.ID, room.ID, from.AddDate(0, 0, 5), from.AddDate(0, 0, 7))
+
+		bookingHandler = NewBookingHandler(db.Store, &events.Recorder{})
+		app            = fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	)
+	app.Post("/lookup", bookingHandler.HandleLookupBooking)
+
+	if len(booking.ConfirmationCode) != 8 || booking.ConfirmationCode == other.ConfirmationCode {
+		t.Fatalf("expected distinct confirmation codes but got %q and %q", booking.ConfirmationCode, other.ConfirmationCode)
+	}
+	readOut := strings.ToLower(booking.ConfirmationCode[:4] + "-" + booking.ConfirmationCode[4:])
+
+	tests := []struct {
+		name   string
+		params LookupBookingParams
+		status int
+	}{
+		{"should find the booking", LookupBookingParams{booking.ConfirmationCode, "Foo"}, http.StatusOK},
+		{"should ignore case and dashes", LookupBookingParams{readOut, " foo "}, http.StatusOK},
+		{"should not find the booking under another last name", LookupBookingParams{booking.ConfirmationCode, "Bar"}, http.StatusNotFound},
+		{"should not find an unknown code", LookupBookingParams{"AAAAAAAA", "Foo"}, http.StatusNotFound},
+		{"should require both fields", LookupBookingParams{LastName: "Foo"}, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, _ := json.Marshal(tt.params)
+			req := httptest.NewRequest(http.MethodPost, "/lookup", bytes.NewReader(b))
+			req.Header.Add("Content-Type", "application/json")
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.status {
+				t.Fatalf("expected %d response but got %d", tt.status, resp.StatusCode)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+			var found *types.Booking
+			if err := json.NewDecoder(resp.Body).Decode(&found); err != nil {
+				t.Fatal(err)
+			}
+			if found.ID != booking.ID {
+				t.Fatalf("expected booking %s but got %s", booking.ID.Hex(), found.ID.Hex())
+			}
+		})
+	}
+}
+
+func TestStaffBookings(t *testing.T) {
+	db := setup(t)
+	defer db.tearDown(t)
+
+	var (
+		adminUser   = fixtures.AddUser(db.Store, "admin", "admin", true)
+		staffUser   = fixtures.AddUser(db.Store, "front", "desk", false)
+		financeUser = fixtures.AddUser(db.Store, "money", "counter", false)
+		user        = fixtures.AddUser(db.Store, "james", "foo", false)
+		hotel       = fixtures.AddHotel(db.Store, "hotel", "anywhere", 4, nil)
+		room        = fixtures.AddRoom(db.Store, "small", true, 5.5, hotel.ID)
+		otherHotel  = fixtures.AddHotel(db.Store, "other", "anywhere", 4, nil)
+		otherRoom   = fixtures.AddRoom(db.Store, "small", true, 5.5, otherHotel.ID)
+
+		day = func(days int) time.Time {
+			return time.Now().UTC().AddDate(0, 0, days)
+		}
+		booking      = fixtures.AddBooking(db.Store, user.ID, room.ID, day(10), day(12))
+		otherBooking = fixtures.AddBooking(db.Store, user.ID, otherRoom.ID, day(10), day(12))
+
+		bookingHandler = NewBookingHandler(db.Store, &events.Recorder{})
+		userHandler    = NewUserHandler(db.User, db.Token, testTokens, nil)
+		app            = fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+		route          = app.Group("/", JWTAuthentication(db.User, db.Token, testTokens, nil))
+		admin          = route.Group("/admin")
+	)
+	route.Get("/booking/:id", bookingHandler.HandleGetBooking)
+	admin.Get("/booking", Require(policy.ViewBookings), bookingHandler.HandleGetBookings)
+	admin.Post("/booking/:id/cancel", Require(policy.ManageBookings), bookingHandler.HandleAdminCancelBooking)
+	admin.Put("/user/:id/role", Require(policy.ManageUsers), userHandler.HandleUpdateUserRole)
+
+	send := func(t *testing.T, user *types.User, method, target string, body any, status int) *http.Response {
+		req := httptest.NewRequest(method, target, nil)
+		if body != nil {
+			b, _ := json.Marshal(body)
+			req = httptest.NewRequest(method, target, bytes.NewReader(b))
+			req.Header.Add("Content-Type", "application/json")
+		}
+		req.Header.Add("X-Api-Token", createToken(user))
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != status {
+			t.Fatalf("expected %d response but got %d", status, resp.StatusCode)
+		}
+		return resp
+	}
+	listed := func(t *testing.T, resp *http.Response) []*types.Booking {
+		var bookings []*types.Booking
+		if err := json.NewDecoder(resp.Body).Decode(&ResourceResp{Data: &bookings}); err != nil {
+			t.Fatal(err)
+		}
+		return bookings
+	}
+
+	t.Run("only admins should assign roles", func(t *testing.T) {
+		staff := types.UpdateRoleParams{Role: types.RoleStaff, HotelIDs: []string{hotel.ID.Hex()}}
+		send(t, staffUser, http.MethodPut, "/admin/user/"+staffUser.ID.Hex()+"/role", staff, http.StatusForbidden)
+		send(t, adminUser, http.MethodPut, "/admin/user/"+staffUser.ID.Hex()+"/role", types.UpdateRoleParams{Role: types.RoleStaff}, http.StatusBadRequest)
+		send(t, adminUser, http.MethodPut, "/admin/user/"+staffUser.ID.Hex()+"/role", staff, http.StatusOK)
+		send(t, adminUser, http.MethodPut, "/admin/user/"+financeUser.ID.Hex()+"/role", types.UpdateRoleParams{Role: types.RoleFinance}, http.StatusOK)
+	})
+
+	t.Run("staff should only list the bookings of their hotels", func(t *testing.T) {
+		bookings := listed(t, send(t, staffUser, http.MethodGet, "/admin/booking", nil, http.StatusOK))
+		if len(bookings) != 1 || bookings[0].ID != booking.ID {
+			t.Fatalf("expected only booking %s but got %d bookings", booking.ID.Hex(), len(bookings))
+		}
+		send(t, staffUser, http.MethodGet, "/admin/booking?hotelID="+otherHotel.ID.Hex(), nil, http.StatusForbidden)
+	})
+
+	t.Run("staff should only view the bookings of their hotels", func(t *testing.T) {
+		send(t, staffUser, http.MethodGet, "/booking/"+booking.ID.Hex(), nil, http.StatusOK)
+		send(t, staffUser, http.MethodGet, "/booking/"+otherBooking.ID.Hex(), nil, http.StatusForbidden)
+	})
+
+	t.Run("finance should list the bookings of every hotel without managing them", func(t *testing.T) {
+		bookings := listed(t, send(t, financeUser, http.MethodGet, "/admin/booking", nil, http.StatusOK))
+		if len(bookings) != 2 {
+			t.Fatalf("expected 2 bookings but got %d", len(bookings))
+		}
+		send(t, financeUser, http.MethodPost, "/admin/booking/"+booking.ID.Hex()+"/cancel", nil, http.StatusForbidden)
+	})
+
+	t.Run("staff should only cancel the bookings of their hotels", func(t *testing.T) {
+		send(t, staffUser, http.MethodPost, "/admin/booking/"+otherBooking.ID.Hex()+"/cancel", nil, http.StatusForbidden)
+		send(t, staffUser, http.MethodPost, "/admin/booking/"+booking.ID.Hex()+"/cancel", nil, http.StatusOK)
+	})
+
+	t.Run("guests should not reach staff routes", func(t *testing.T) {
+		resp := send(t, user, http.MethodGet, "/admin/booking", nil, http.StatusForbidden)
+		// a challenge would have clients log in again, to no avail
+		if got := resp.Header.Get("WWW-Authenticate"); len(got) > 0 {
+			t.Fatalf("expected no challenge but got %q", got)
 		}
 	})
 }
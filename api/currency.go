@@ -0,0 +1,153 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/raphaelmb/go-hotel-reservation/types"
+)
+
+// A nil priceConverter leaves prices in their own currency.
+type priceConverter struct {
+	rates    *types.ExchangeRates
+	currency types.Currency
+}
+
+// CurrencyConversion reads the currency query parameter or the Accept-Currency header.
+func CurrencyConversion(rates *types.ExchangeRates) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		currency := c.Query("currency", c.Get("Accept-Currency"))
+		if len(currency) == 0 {
+			return c.Next()
+		}
+		conv := &priceConverter{
+			rates:    rates,
+			currency: types.Currency(strings.ToUpper(currency)),
+		}
+		if !rates.Supports(conv.currency) {
+			return NewError(http.StatusBadRequest, fmt.Sprintf("currency %s is not supported", currency))
+		}
+		c.Context().SetUserValue("currency", conv)
+		return c.Next()
+	}
+}
+
+func getPriceConverter(c *fiber.Ctx) *priceConverter {
+	conv, _ := c.Context().UserValue("currency").(*priceConverter)
+	return conv
+}
+
+func (p *priceConverter) money(m types.Money) (types.Money, error) {
+	if p == nil {
+		return m, nil
+	}
+	converted, err := p.rates.Convert(m, p.currency)
+	if err != nil {
+		return m, NewError(http.StatusUnprocessableEntity, err.Error())
+	}
+	return converted, nil
+}
+
+func (p *priceConverter) room(room *types.Room) (*types.Room, error) {
+	price, err := p.money(room.Price)
+	if err != nil {
+		return nil, err
+	}
+	converted := *room
+	converted.Price = price
+	return &converted, nil
+}
+
+func (p *priceConverter) rooms(rooms []*types.Room) ([]*types.Room, error) {
+	converted := make([]*types.Room, len(rooms))
+	for i, room := range rooms {
+		c, err := p.room(room)
+		if err != nil {
+			return nil, err
+		}
+		converted[i] = c
+	}
+	return converted, nil
+}
+
+func (p *priceConverter) quote(quote *types.PriceQuote) (*types.PriceQuote, error) {
+	if p == nil || quote == nil {
+		return quote, nil
+	}
+	converted, err := quote.Convert(p.rates, p.currency)
+	if err != nil {
+		return nil, NewError(http.StatusUnprocessableEntity, err.Error())
+	}
+	return converted, nil
+}
+
+func (p *priceConverter) cancellation(cancellation *types.Cancellation) (*types.Cancellation, error) {
+	if p == nil || cancellation == nil {
+		return cancellation, nil
+	}
+	converted := *cancellation
+	var err error
+	if converted.Penalty, err = p.money(cancellation.Penalty); err != nil {
+		return nil, err
+	}
+	if converted.Refund, err = p.money(cancellation.Refund); err != nil {
+		return nil, err
+	}
+	return &converted, nil
+}
+
+func (p *priceConverter) booking(booking *types.Booking) (*types.Booking, error) {
+	price, err := p.quote(booking.Price)
+	if err != nil {
+		return nil, err
+	}
+	cancellation, err := p.cancellation(booking.Cancellation)
+	if err != nil {
+		return nil, err
+	}
+	converted := *booking
+	converted.Price = price
+	converted.Cancellation = cancellation
+	if len(booking.Modifications) > 0 && p != nil {
+		converted.Modifications = make([]types.BookingModification, len(booking.Modifications))
+		for i, modification := range booking.Modifications {
+			if modification.Total, err = p.money(modification.Total); err != nil {
+				return nil, err
+			}
+			if modification.Fee, err = p.money(modification.Fee); err != nil {
+				return nil, err
+			}
+			converted.Modifications[i] = modification
+		}
+	}
+	return &converted, nil
+}
+
+func (p *priceConverter) reservation(reservation *types.Reservation) (*types.Reservation, error) {
+	total, err := p.money(reservation.Total)
+	if err != nil {
+		return nil, err
+	}
+	cancellation, err := p.cancellation(reservation.Cancellation)
+	if err != nil {
+		return nil, err
+	}
+	converted := *reservation
+	converted.Total = total
+	converted.Cancellation = cancellation
+	return &converted, nil
+}
+
+func (p *priceConverter) bookings(bookings []*types.Booking) ([]*types.Booking, error) {
+	converted := make([]*types.Booking, len(bookings))
+	for i, booking := range bookings {
+		c, err := p.booking(booking)
+		if err != nil {
+			return nil, err
+		}
+		converted[i] = c
+	}
+	return converted, nil
+}
@@ -0,0 +1,140 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/raphaelmb/go-hotel-reservation/db/fixtures"
+	"github.com/raphaelmb/go-hotel-reservation/types"
+)
+
+func loadTestRates(t *testing.T) *types.ExchangeRates {
+	path := filepath.Join(t.TempDir(), "rates.json")
+	if err := os.WriteFile(path, []byte(`{"base": "USD", "rates": {"EUR": 0.5, "JPY": 150}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	rates, err := types.LoadExchangeRates(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rates
+}
+
+func TestCurrencyConversion(t *testing.T) {
+	db := setup(t)
+	defer db.tearDown(t)
+
+	var (
+		user  = fixtures.AddUser(db.Store, "james", "foo", false)
+		hotel = fixtures.AddHotel(db.Store, "hotel", "anywhere", 4, nil)
+		room  = fixtures.AddRoom(db.Store, "small", true, 99.99, hotel.ID)
+		from  = time.Now().AddDate(0, 0, 1)
+
+		roomHandler = NewRoomHandler(db.Store)
+		app         = fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+		route       = app.Group("/", JWTAuthentication(db.User, db.Token, testTokens, nil), CurrencyConversion(loadTestRates(t)))
+	)
+	route.Get("/", roomHandler.HandleGetRooms)
+	route.Post("/:id/quote", roomHandler.HandleQuoteRoom)
+
+	getRooms := func(t *testing.T, target, acceptCurrency string) (*http.Response, []*types.Room) {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.Header.Add("X-Api-Token", createToken(user))
+		if len(acceptCurrency) > 0 {
+			req.Header.Add("Accept-Currency", acceptCurrency)
+		}
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var rooms []*types.Room
+		if resp.StatusCode == http.StatusOK {
+			if err := json.NewDecoder(resp.Body).Decode(&rooms); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return resp, rooms
+	}
+
+	tests := []struct {
+		name           string
+		target         string
+		acceptCurrency string
+		price          types.Money
+	}{
+		{"hotel currency by default", "/", "", types.Money{Amount: 9999, Currency: "USD"}},
+		{"currency query", "/?currency=eur", "", types.Money{Amount: 5000, Currency: "EUR"}},
+		{"accept currency header", "/", "JPY", types.Money{Amount: 14999, Currency: "JPY"}},
+		{"query over header", "/?currency=EUR", "JPY", types.Money{Amount: 5000, Currency: "EUR"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, rooms := getRooms(t, tt.target, tt.acceptCurrency)
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("expected 200 response but got %d", resp.StatusCode)
+			}
+			if len(rooms) != 1 || rooms[0].Price != tt.price {
+				t.Fatalf("expected room price %s but got %+v", tt.price, rooms)
+			}
+		})
+	}
+
+	t.Run("should reject an unsupported currency", func(t *testing.T) {
+		resp, _ := getRooms(t, "/?currency=XYZ", "")
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("expected 400 response but got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("should convert quotes", func(t *testing.T) {
+		req := reserveRoomRequest("quote", room, user, from, from.AddDate(0, 0, 2), 1)
+		req.Header.Add("Accept-Currency", "EUR")
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200 response but got %d", resp.StatusCode)
+		}
+		var quote types.PriceQuote
+		if err := json.NewDecoder(resp.Body).Decode(&quote); err != nil {
+			t.Fatal(err)
+		}
+		if want := (types.Money{Amount: 9999, Currency: "EUR"}); quote.Total != want {
+			t.Fatalf("expected total %s but got %s", want, quote.Total)
+		}
+	})
+}
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		amount   string
+		currency types.Currency
+		want     int64
+		ok       bool
+	}{
+		{"299.99", "USD", 29999, true},
+		{"299.9", "USD", 29990, true},
+		{"-1.05", "USD", -105, true},
+		{"1500", "JPY", 1500, true},
+		{"1.5", "JPY", 0, false},
+		{"1.001", "USD", 0, false},
+		{"1.234", "KWD", 1234, true},
+		{"abc", "USD", 0, false},
+	}
+	for _, tt := range tests {
+		money, err := types.ParseMoney(tt.amount, tt.currency)
+		if (err == nil) != tt.ok {
+			t.Fatalf("parsing %s %s: expected ok %v but got %v", tt.amount, tt.currency, tt.ok, err)
+		}
+		if tt.ok && money.Amount != tt.want {
+			t.Fatalf("parsing %s %s: expected %d but got %d", tt.amount, tt.currency, tt.want, money.Amount)
+		}
+	}
+}
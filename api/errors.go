@@ -1,13 +1,19 @@
 package api
 
 import (
+	"fmt"
 	"net/http"
 
 	"github.com/gofiber/fiber/v2"
 )
 
+const authRealm = "go-hotel-reservation"
+
 func ErrorHandler(c *fiber.Ctx, err error) error {
 	if apiError, ok := err.(Error); ok {
+		if apiError.Code == http.StatusUnauthorized && len(c.GetRespHeader(fiber.HeaderWWWAuthenticate)) == 0 {
+			c.Set(fiber.HeaderWWWAuthenticate, fmt.Sprintf("Bearer realm=%q", authRealm))
+		}
 		return c.Status(apiError.Code).JSON(apiError)
 	}
 	apiError := NewError(http.StatusInternalServerError, err.Error())
@@ -37,6 +43,14 @@ func ErrUnauthorized() Error {
 	}
 }
 
+// ErrForbidden is for authenticated users lacking a permission or ownership.
+func ErrForbidden() Error {
+	return Error{
+		Code: http.StatusForbidden,
+		Err:  "forbidden",
+	}
+}
+
 func ErrInvalidID() Error {
 	return Error{
 		Code: http.StatusBadRequest,
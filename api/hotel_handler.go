@@ -1,9 +1,13 @@
 package api
 
 import (
+	"errors"
+	"fmt"
+	"net/http"
+
 	"github.com/gofiber/fiber/v2"
 	"github.com/raphaelmb/go-hotel-reservation/db"
-	"go.mongodb.org/mongo-driver/bson"
+	"github.com/raphaelmb/go-hotel-reservation/types"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
@@ -24,12 +28,16 @@ func (h *HotelHandler) HandleGetRooms(c *fiber.Ctx) error {
 		return ErrInvalidID()
 	}
 
-	filter := bson.M{"hotelID": oid}
+	filter := db.RoomFilter{HotelID: oid}
 	rooms, err := h.store.Room.GetRooms(c.Context(), filter)
 	if err != nil {
 		return ErrResourceNotFound()
 	}
 
+	rooms, err = getPriceConverter(c).rooms(rooms)
+	if err != nil {
+		return err
+	}
 	return c.JSON(rooms)
 }
 
@@ -49,8 +57,8 @@ func (h *HotelHandler) HandleGetHotels(c *fiber.Ctx) error {
 	if err := c.QueryParser(&params); err != nil {
 		return ErrBadRequest()
 	}
-	filter := db.Map{
-		"rating": params.Rating,
+	filter := db.HotelFilter{
+		Rating: params.Rating,
 	}
 	hotels, err := h.store.Hotel.GetHotels(c.Context(), filter, &params.Pagination)
 	if err != nil {
@@ -75,3 +83,155 @@ func (h *HotelHandler) HandleGetHotel(c *fiber.Ctx) error {
 	}
 	return c.JSON(hotel)
 }
+
+func (h *HotelHandler) HandlePostHotel(c *fiber.Ctx) error {
+	var params types.CreateHotelParams
+	if err := c.BodyParser(&params); err != nil {
+		return ErrBadRequest()
+	}
+
+	if errors := params.Validate(); len(errors) > 0 {
+		return c.Status(http.StatusBadRequest).JSON(errors)
+	}
+
+	hotel := types.NewHotelFromParams(params)
+	charges, err := chargesInCurrency(hotel.Charges, hotel.BaseCurrency())
+	if err != nil {
+		return err
+	}
+	hotel.Charges = charges
+
+	hotel, err = h.store.Hotel.Insert(c.Context(), hotel)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(hotel)
+}
+
+func (h *HotelHandler) HandlePutHotel(c *fiber.Ctx) error {
+	var params types.CreateHotelParams
+	if err := c.BodyParser(&params); err != nil {
+		return ErrBadRequest()
+	}
+
+	if errors := params.Validate(); len(errors) > 0 {
+		return c.Status(http.StatusBadRequest).JSON(errors)
+	}
+	// pricing, charges and the cancellation policy left out are cleared
+	if params.Pricing == nil {
+		params.Pricing = &types.PricingRules{}
+	}
+	if params.Charges == nil {
+		params.Charges = []types.ChargeRule{}
+	}
+	if params.CancellationPolicy == nil {
+		params.CancellationPolicy = &types.CancellationPolicy{}
+	}
+
+	return h.updateHotel(c, types.UpdateHotelParams(params))
+}
+
+func (h *HotelHandler) HandlePatchHotel(c *fiber.Ctx) error {
+	var params types.UpdateHotelParams
+	if err := c.BodyParser(&params); err != nil {
+		return ErrBadRequest()
+	}
+
+	if errors := params.Validate(); len(errors) > 0 {
+		return c.Status(http.StatusBadRequest).JSON(errors)
+	}
+
+	return h.updateHotel(c, params)
+}
+
+func (h *HotelHandler) updateHotel(c *fiber.Ctx, params types.UpdateHotelParams) error {
+	id := c.Params("id")
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return ErrInvalidID()
+	}
+
+	if len(params.Currency) > 0 || params.Charges != nil {
+		hotel, err := h.store.Hotel.GetHotelByID(c.Context(), id)
+		if err != nil {
+			if errors.Is(err, db.ErrNotFound) {
+				return ErrResourceNotFound()
+			}
+			return err
+		}
+
+		currency := hotel.BaseCurrency()
+		if len(params.Currency) > 0 && params.Currency != currency {
+			rooms, err := h.store.Room.GetRooms(c.Context(), db.RoomFilter{HotelID: oid})
+			if err != nil {
+				return err
+			}
+			if len(rooms) > 0 {
+				return NewError(http.StatusConflict, fmt.Sprintf("cannot change the currency of hotel %s, it has %d rooms", id, len(rooms)))
+			}
+			currency = params.Currency
+		}
+
+		charges := params.Charges
+		if charges == nil {
+			charges = hotel.Charges
+		}
+		if params.Charges, err = chargesInCurrency(charges, currency); err != nil {
+			return err
+		}
+	}
+
+	if err := h.store.Hotel.Update(c.Context(), id, params); err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return ErrResourceNotFound()
+		}
+		return err
+	}
+
+	return c.JSON(map[string]string{"updated": id})
+}
+
+func chargesInCurrency(charges []types.ChargeRule, currency types.Currency) ([]types.ChargeRule, error) {
+	if charges == nil {
+		return nil, nil
+	}
+	checked := make([]types.ChargeRule, len(charges))
+	for i, charge := range charges {
+		if charge.Kind != types.ChargePercent {
+			amount, err := priceInCurrency(charge.Amount, currency)
+			if err != nil {
+				return nil, err
+			}
+			charge.Amount = amount
+		}
+		checked[i] = charge
+	}
+	return checked, nil
+}
+
+// HandleDeleteHotel keeps hotels with rooms, so no booking points to a missing hotel.
+func (h *HotelHandler) HandleDeleteHotel(c *fiber.Ctx) error {
+	id := c.Params("id")
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return ErrInvalidID()
+	}
+
+	rooms, err := h.store.Room.GetRooms(c.Context(), db.RoomFilter{HotelID: oid})
+	if err != nil {
+		return err
+	}
+	if len(rooms) > 0 {
+		return NewError(http.StatusConflict, fmt.Sprintf("hotel %s still has %d rooms", id, len(rooms)))
+	}
+
+	if err := h.store.Hotel.Delete(c.Context(), id); err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return ErrResourceNotFound()
+		}
+		return err
+	}
+
+	return c.JSON(map[string]string{"deleted": id})
+}
@@ -0,0 +1,174 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/raphaelmb/go-hotel-reservation/db/fixtures"
+	"github.com/raphaelmb/go-hotel-reservation/policy"
+	"github.com/raphaelmb/go-hotel-reservation/types"
+)
+
+func TestGetHotels(t *testing.T) {
+	db := setup(t)
+	defer db.tearDown(t)
+
+	var (
+		user         = fixtures.AddUser(db.Store, "james", "foo", false)
+		_            = fixtures.AddHotel(db.Store, "hotel a", "anywhere", 3, nil)
+		fourStars    = fixtures.AddHotel(db.Store, "hotel b", "anywhere", 4, nil)
+		hotelHandler = NewHotelHandler(db.Store)
+
+		app   = fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+		route = app.Group("/", JWTAuthentication(db.User, db.Token, testTokens, nil))
+	)
+	route.Get("/", hotelHandler.HandleGetHotels)
+
+	getHotels := func(t *testing.T, query string) []*types.Hotel {
+		req := httptest.NewRequest(http.MethodGet, "/"+query, nil)
+		req.Header.Add("X-Api-Token", createToken(user))
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200 response but got %d", resp.StatusCode)
+		}
+		var hotels []*types.Hotel
+		body := ResourceResp{Data: &hotels}
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		return hotels
+	}
+
+	t.Run("without rating returns every hotel", func(t *testing.T) {
+		if hotels := getHotels(t, ""); len(hotels) != 2 {
+			t.Fatalf("expected 2 hotels but got %d", len(hotels))
+		}
+	})
+
+	t.Run("with rating returns matching hotels", func(t *testing.T) {
+		hotels := getHotels(t, "?rating=4")
+		if len(hotels) != 1 {
+			t.Fatalf("expected 1 hotel but got %d", len(hotels))
+		}
+		if hotels[0].ID != fourStars.ID {
+			t.Fatalf("expected hotel %s but got %s", fourStars.ID, hotels[0].ID)
+		}
+	})
+}
+
+func TestAdminManageHotels(t *testing.T) {
+	db := setup(t)
+	defer db.tearDown(t)
+
+	var (
+		admin        = fixtures.AddUser(db.Store, "admin", "admin", true)
+		hotelHandler = NewHotelHandler(db.Store)
+
+		app   = fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+		route = app.Group("/", JWTAuthentication(db.User, db.Token, testTokens, nil), Require(policy.ManageHotels))
+	)
+	route.Post("/", hotelHandler.HandlePostHotel)
+	route.Put("/:id", hotelHandler.HandlePutHotel)
+	route.Patch("/:id", hotelHandler.HandlePatchHotel)
+	route.Delete("/:id", hotelHandler.HandleDeleteHotel)
+
+	send := func(t *testing.T, method, target string, body any) *http.Response {
+		b, _ := json.Marshal(body)
+		req := httptest.NewRequest(method, target, bytes.NewReader(b))
+		req.Header.Add("Content-Type", "application/json")
+		req.Header.Add("X-Api-Token", createToken(admin))
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	var hotel types.Hotel
+	t.Run("should create a hotel", func(t *testing.T) {
+		resp := send(t, http.MethodPost, "/", types.CreateHotelParams{Name: "hotel", Location: "anywhere", Rating: 4})
+		if resp.StatusCode != http.StatusCreated {
+			t.Fatalf("expected 201 response but got %d", resp.StatusCode)
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&hotel); err != nil {
+			t.Fatal(err)
+		}
+		if hotel.ID.IsZero() {
+			t.Fatalf("expected hotel id to be set")
+		}
+	})
+
+	t.Run("should reject an invalid hotel", func(t *testing.T) {
+		resp := send(t, http.MethodPost, "/", types.CreateHotelParams{Name: "h", Rating: 9})
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("expected 400 response but got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("should reject invalid pricing", func(t *testing.T) {
+		pricing := &types.PricingRules{StayDiscounts: []types.StayDiscount{{MinNights: 7, Percent: 120}}}
+		resp := send(t, http.MethodPatch, "/"+hotel.ID.Hex(), types.UpdateHotelParams{Pricing: pricing})
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("expected 400 response but got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("should reject charges in another currency", func(t *testing.T) {
+		charges := []types.ChargeRule{{Name: "cleaning", Kind: types.ChargePerStay, Amount: types.NewMoney(30, "EUR")}}
+		resp := send(t, http.MethodPatch, "/"+hotel.ID.Hex(), types.UpdateHotelParams{Charges: charges})
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("expected 400 response but got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("should patch a hotel", func(t *testing.T) {
+		resp := send(t, http.MethodPatch, "/"+hotel.ID.Hex(), types.UpdateHotelParams{Rating: 2})
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200 response but got %d", resp.StatusCode)
+		}
+		updated, err := db.Hotel.GetHotelByID(context.Background(), hotel.ID.Hex())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if updated.Rating != 2 || updated.Name != hotel.Name {
+			t.Fatalf("expected rating 2 and name %s but got %d and %s", hotel.Name, updated.Rating, updated.Name)
+		}
+	})
+
+	t.Run("should not put a hotel partially", func(t *testing.T) {
+		resp := send(t, http.MethodPut, "/"+hotel.ID.Hex(), types.CreateHotelParams{Name: "renamed"})
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("expected 400 response but got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("should not delete a hotel with rooms", func(t *testing.T) {
+		room := fixtures.AddRoom(db.Store, "small", true, 5.5, hotel.ID)
+		resp := send(t, http.MethodDelete, "/"+hotel.ID.Hex(), nil)
+		if resp.StatusCode != http.StatusConflict {
+			t.Fatalf("expected 409 response but got %d", resp.StatusCode)
+		}
+		if err := db.Room.DeleteRoom(context.Background(), room.ID.Hex()); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("should delete a hotel", func(t *testing.T) {
+		resp := send(t, http.MethodDelete, "/"+hotel.ID.Hex(), nil)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200 response but got %d", resp.StatusCode)
+		}
+		resp = send(t, http.MethodDelete, "/"+hotel.ID.Hex(), nil)
+		if resp.StatusCode != http.StatusNotFound {
+			t.Fatalf("expected 404 response but got %d", resp.StatusCode)
+		}
+	})
+}
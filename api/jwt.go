@@ -1,37 +1,157 @@
 package api
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/raphaelmb/go-hotel-reservation/db"
+	"github.com/raphaelmb/go-hotel-reservation/signing"
+	"github.com/raphaelmb/go-hotel-reservation/types"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
-func JWTAuthentication(userStore db.UserStore) fiber.Handler {
+const (
+	// JWTSecretEnvName is only read without a keys directory, to sign with HS256.
+	JWTKeysDirEnvName      = "JWT_KEYS_DIR"
+	JWTSigningKeyIDEnvName = "JWT_SIGNING_KEY_ID"
+	JWTSecretEnvName       = "JWT_SECRET"
+	JWTIssuerEnvName       = "JWT_ISSUER"
+	JWTAudienceEnvName     = "JWT_AUDIENCE"
+
+	defaultJWTIssuer   = "go-hotel-reservation"
+	defaultJWTAudience = "go-hotel-reservation-api"
+)
+
+type AccessClaims struct {
+	jwt.RegisteredClaims
+	Email string `json:"email,omitempty"`
+	// SessionID is the refresh token family of the session of the token.
+	SessionID string `json:"sid"`
+}
+
+type TokenIssuer struct {
+	keys     *signing.KeySet
+	issuer   string
+	audience string
+}
+
+func NewTokenIssuer(keys *signing.KeySet, issuer, audience string) *TokenIssuer {
+	return &TokenIssuer{
+		keys:     keys,
+		issuer:   issuer,
+		audience: audience,
+	}
+}
+
+func NewTokenIssuerFromEnv() (*TokenIssuer, error) {
+	var (
+		keys *signing.KeySet
+		err  error
+	)
+	if dir := os.Getenv(JWTKeysDirEnvName); len(dir) > 0 {
+		keys, err = signing.LoadKeySet(dir, os.Getenv(JWTSigningKeyIDEnvName))
+	} else if secret := os.Getenv(JWTSecretEnvName); len(secret) > 0 {
+		keys, err = signing.NewHMACKeySet([]byte(secret))
+	} else {
+		err = fmt.Errorf("no jwt signing key configured, set %s or %s", JWTKeysDirEnvName, JWTSecretEnvName)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	issuer := os.Getenv(JWTIssuerEnvName)
+	if len(issuer) == 0 {
+		issuer = defaultJWTIssuer
+	}
+	audience := os.Getenv(JWTAudienceEnvName)
+	if len(audience) == 0 {
+		audience = defaultJWTAudience
+	}
+	return NewTokenIssuer(keys, issuer, audience), nil
+}
+
+func (i *TokenIssuer) createAccessToken(user *types.User, session primitive.ObjectID, now time.Time) (string, error) {
+	claims := AccessClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   user.ID.Hex(),
+			Issuer:    i.issuer,
+			Audience:  jwt.ClaimStrings{i.audience},
+			ExpiresAt: jwt.NewNumericDate(now.Add(accessTokenTTL)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ID:        primitive.NewObjectID().Hex(),
+		},
+		Email:     user.Email,
+		SessionID: session.Hex(),
+	}
+	return i.keys.Sign(claims)
+}
+
+func (i *TokenIssuer) Verify(tokenStr string) (*AccessClaims, error) {
+	var claims AccessClaims
+	_, err := jwt.ParseWithClaims(tokenStr, &claims, i.keys.Keyfunc,
+		jwt.WithValidMethods(i.keys.Methods()),
+		jwt.WithIssuer(i.issuer),
+		jwt.WithAudience(i.audience),
+		jwt.WithIssuedAt(),
+	)
+	if err != nil {
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return nil, NewError(http.StatusUnauthorized, "token expired")
+		}
+		return nil, ErrUnauthorized()
+	}
+	// the parser accepts tokens which never expire
+	if claims.ExpiresAt == nil || len(claims.Subject) == 0 {
+		return nil, ErrUnauthorized()
+	}
+	return &claims, nil
+}
+
+func (i *TokenIssuer) HandleJWKS(c *fiber.Ctx) error {
+	return c.JSON(i.keys.JWKS())
+}
+
+// JWTAuthentication rejects tokens without a session or of a revoked one.
+func JWTAuthentication(userStore db.UserStore, tokenStore db.TokenStore, tokens *TokenIssuer, cookies *SessionCookies) fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		token, ok := c.GetReqHeaders()["X-Api-Token"]
-		if !ok {
+		token, fromCookie := requestToken(c, cookies)
+		if len(token) == 0 {
 			return ErrUnauthorized()
 		}
 
-		claims, err := validateToken(token)
+		claims, err := tokens.Verify(token)
 		if err != nil {
-			return err
+			return invalidToken(c, err)
+		}
+		if fromCookie {
+			if err := cookies.checkCSRF(c); err != nil {
+				return err
+			}
+			c.Context().SetUserValue("cookieSession", true)
 		}
 
-		expires := int64(claims["expires"].(float64))
-		if time.Now().Unix() > expires {
-			return NewError(http.StatusUnauthorized, "token expired")
+		session, err := primitive.ObjectIDFromHex(claims.SessionID)
+		if err != nil {
+			return invalidToken(c, ErrUnauthorized())
+		}
+		revoked, err := tokenStore.IsTokenFamilyRevoked(c.Context(), session)
+		if err != nil {
+			return err
+		}
+		if revoked {
+			return invalidToken(c, NewError(http.StatusUnauthorized, "session revoked"))
 		}
 
-		userID := claims["id"].(string)
-		user, err := userStore.GetUserByID(c.Context(), userID)
+		user, err := userStore.GetUserByID(c.Context(), claims.Subject)
 		if err != nil {
-			return ErrUnauthorized()
+			return invalidToken(c, ErrUnauthorized())
 		}
 		// set the current authenticated user in the context
 		c.Context().SetUserValue("user", user)
@@ -40,28 +160,26 @@ func JWTAuthentication(userStore db.UserStore) fiber.Handler {
 	}
 }
 
-func validateToken(tokenStr string) (jwt.MapClaims, error) {
-	token, err := jwt.Parse(tokenStr, func(token *jwt.Token) (any, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			fmt.Println("invalid signing method", token.Header["alg"])
-			return nil, ErrUnauthorized()
-		}
-		secret := os.Getenv("JWT_SECRET")
-		return []byte(secret), nil
-	})
-	if err != nil {
-		fmt.Println("failed to parse jwt token", err)
-		return nil, ErrUnauthorized()
-	}
+func inCookieSession(c *fiber.Ctx) bool {
+	inCookies, _ := c.Context().UserValue("cookieSession").(bool)
+	return inCookies
+}
 
-	if !token.Valid {
-		fmt.Println("invalid token", err)
-		return nil, ErrUnauthorized()
+func requestToken(c *fiber.Ctx, cookies *SessionCookies) (token string, fromCookie bool) {
+	if scheme, token, ok := strings.Cut(c.Get(fiber.HeaderAuthorization), " "); ok && strings.EqualFold(scheme, "Bearer") {
+		return strings.TrimSpace(token), false
 	}
-
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if !ok {
-		return nil, ErrUnauthorized()
+	if token := c.Get("X-Api-Token"); len(token) > 0 {
+		return token, false
 	}
-	return claims, nil
+	if token := cookies.accessToken(c); len(token) > 0 {
+		return token, true
+	}
+	return "", false
+}
+
+// invalidToken describes the rejection as RFC 6750 does.
+func invalidToken(c *fiber.Ctx, err error) error {
+	c.Set(fiber.HeaderWWWAuthenticate, fmt.Sprintf("Bearer realm=%q, error=\"invalid_token\", error_description=%q", authRealm, err.Error()))
+	return err
 }
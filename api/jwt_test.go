@@ -0,0 +1,203 @@
+package api
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/raphaelmb/go-hotel-reservation/db/fixtures"
+	"github.com/raphaelmb/go-hotel-reservation/signing"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func writeKey(t *testing.T, dir, kid string, key any) {
+	t.Helper()
+	var block *pem.Block
+	switch k := key.(type) {
+	case ed25519.PublicKey, *rsa.PublicKey:
+		b, err := x509.MarshalPKIXPublicKey(k)
+		if err != nil {
+			t.Fatal(err)
+		}
+		block = &pem.Block{Type: "PUBLIC KEY", Bytes: b}
+	default:
+		b, err := x509.MarshalPKCS8PrivateKey(k)
+		if err != nil {
+			t.Fatal(err)
+		}
+		block = &pem.Block{Type: "PRIVATE KEY", Bytes: b}
+	}
+	if err := os.WriteFile(filepath.Join(dir, kid+".pem"), pem.EncodeToMemory(block), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSigningKeys(t *testing.T) {
+	tdb := setup(t)
+	defer tdb.tearDown(t)
+	user := fixtures.AddUser(tdb.Store, "james", "foo", false)
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edPublic, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	writeKey(t, dir, "2023-01", rsaKey)
+	writeKey(t, dir, "2023-02", edKey)
+
+	load := func(t *testing.T, signingID string) *TokenIssuer {
+		keys, err := signing.LoadKeySet(dir, signingID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return NewTokenIssuer(keys, defaultJWTIssuer, defaultJWTAudience)
+	}
+	authenticate := func(t *testing.T, tokens *TokenIssuer, token string, status int) {
+		app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+		app.Get("/me", JWTAuthentication(tdb.User, tdb.Token, tokens, nil), func(c *fiber.Ctx) error {
+			return c.SendStatus(http.StatusOK)
+		})
+		req := httptest.NewRequest(http.MethodGet, "/me", nil)
+		req.Header.Add("X-Api-Token", token)
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != status {
+			t.Fatalf("expected %d response but got %d", status, resp.StatusCode)
+		}
+	}
+
+	if _, err := signing.LoadKeySet(dir, ""); err == nil {
+		t.Fatalf("expected an error without the id of the signing key")
+	}
+
+	createToken := func(t *testing.T, tokens *TokenIssuer) string {
+		resp, err := StartSession(context.Background(), tdb.Token, tokens, user)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.Token
+	}
+
+	rsaTokens := load(t, "2023-01")
+	rsaToken := createToken(t, rsaTokens)
+
+	t.Run("should sign with registered claims", func(t *testing.T) {
+		claims, err := rsaTokens.Verify(rsaToken)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if claims.Subject != user.ID.Hex() || claims.Issuer != defaultJWTIssuer || len(claims.ID) == 0 {
+			t.Fatalf("unexpected claims %+v", claims)
+		}
+		if claims.IssuedAt == nil || claims.NotBefore == nil || claims.ExpiresAt == nil {
+			t.Fatalf("expected iat, nbf and exp to be set but got %+v", claims)
+		}
+		authenticate(t, rsaTokens, rsaToken, http.StatusOK)
+	})
+
+	t.Run("should keep verifying tokens of rotated keys", func(t *testing.T) {
+		// the rsa key is retired, only its public key is kept
+		writeKey(t, dir, "2023-01", &rsaKey.PublicKey)
+		edTokens := load(t, "2023-02")
+		edToken := createToken(t, edTokens)
+		token, _, err := jwt.NewParser().ParseUnverified(edToken, &AccessClaims{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token.Method.Alg() != "EdDSA" || token.Header["kid"] != "2023-02" {
+			t.Fatalf("expected an EdDSA token of key 2023-02 but got %v", token.Header)
+		}
+		authenticate(t, edTokens, edToken, http.StatusOK)
+		authenticate(t, edTokens, rsaToken, http.StatusOK)
+
+		if _, err := signing.LoadKeySet(dir, "2023-01"); err == nil {
+			t.Fatalf("expected an error signing with a public key")
+		}
+		if err := os.Remove(filepath.Join(dir, "2023-01.pem")); err != nil {
+			t.Fatal(err)
+		}
+		authenticate(t, load(t, ""), rsaToken, http.StatusUnauthorized)
+	})
+
+	t.Run("should reject tokens not issued for the api", func(t *testing.T) {
+		edTokens := load(t, "2023-02")
+		other := NewTokenIssuer(edTokens.keys, defaultJWTIssuer, "another-api")
+		authenticate(t, edTokens, createToken(t, other), http.StatusUnauthorized)
+
+		sessionless, err := edTokens.keys.Sign(AccessClaims{
+			RegisteredClaims: jwt.RegisteredClaims{
+				Subject:   user.ID.Hex(),
+				Issuer:    defaultJWTIssuer,
+				Audience:  jwt.ClaimStrings{defaultJWTAudience},
+				ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+				IssuedAt:  jwt.NewNumericDate(time.Now()),
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		authenticate(t, edTokens, sessionless, http.StatusUnauthorized)
+
+		// an hmac token using the public key as secret
+		forged := jwt.NewWithClaims(jwt.SigningMethodHS256, AccessClaims{
+			RegisteredClaims: jwt.RegisteredClaims{
+				Subject:   user.ID.Hex(),
+				Issuer:    defaultJWTIssuer,
+				Audience:  jwt.ClaimStrings{defaultJWTAudience},
+				ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+				ID:        primitive.NewObjectID().Hex(),
+			},
+		})
+		forged.Header["kid"] = "2023-02"
+		forgedToken, err := forged.SignedString([]byte(edPublic))
+		if err != nil {
+			t.Fatal(err)
+		}
+		authenticate(t, edTokens, forgedToken, http.StatusUnauthorized)
+	})
+
+	t.Run("should publish the public keys", func(t *testing.T) {
+		writeKey(t, dir, "2023-01", &rsaKey.PublicKey)
+		tokens := load(t, "2023-02")
+		app := fiber.New()
+		app.Get("/.well-known/jwks.json", tokens.HandleJWKS)
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var jwks signing.JWKS
+		if err := json.NewDecoder(resp.Body).Decode(&jwks); err != nil {
+			t.Fatal(err)
+		}
+		if len(jwks.Keys) != 2 {
+			t.Fatalf("expected 2 keys but got %d", len(jwks.Keys))
+		}
+		if k := jwks.Keys[0]; k.Kid != "2023-01" || k.Kty != "RSA" || k.Alg != "RS256" || k.E != "AQAB" {
+			t.Fatalf("unexpected rsa key %+v", k)
+		}
+		if k := jwks.Keys[1]; k.Kid != "2023-02" || k.Kty != "OKP" || k.Crv != "Ed25519" || len(k.X) == 0 {
+			t.Fatalf("unexpected ed25519 key %+v", k)
+		}
+		if keys := testTokens.keys.JWKS().Keys; len(keys) != 0 {
+			t.Fatalf("expected hmac secrets not to be published but got %+v", keys)
+		}
+	})
+}
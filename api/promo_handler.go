@@ -0,0 +1,94 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/raphaelmb/go-hotel-reservation/db"
+	"github.com/raphaelmb/go-hotel-reservation/types"
+)
+
+type PromoHandler struct {
+	store *db.Store
+}
+
+func NewPromoHandler(store *db.Store) *PromoHandler {
+	return &PromoHandler{
+		store: store,
+	}
+}
+
+func (h *PromoHandler) HandlePostPromoCode(c *fiber.Ctx) error {
+	var params types.CreatePromoCodeParams
+	if err := c.BodyParser(&params); err != nil {
+		return ErrBadRequest()
+	}
+
+	if errors := params.Validate(); len(errors) > 0 {
+		return c.Status(http.StatusBadRequest).JSON(errors)
+	}
+
+	promo, err := types.NewPromoCodeFromParams(params)
+	if err != nil {
+		return err
+	}
+	// a fixed discount only applies to hotels in its currency
+	for _, hotelID := range promo.HotelIDs {
+		hotel, err := h.store.Hotel.GetHotelByID(c.Context(), hotelID.Hex())
+		if err != nil {
+			if errors.Is(err, db.ErrNotFound) {
+				return NewError(http.StatusNotFound, fmt.Sprintf("hotel %s not found", hotelID.Hex()))
+			}
+			return err
+		}
+		if promo.Kind == types.PromoFixed && promo.Amount.Currency != hotel.BaseCurrency() {
+			return NewError(http.StatusBadRequest, fmt.Sprintf("amount should be in %s, the currency of hotel %s", hotel.BaseCurrency(), hotelID.Hex()))
+		}
+	}
+
+	promo, err = h.store.Promo.InsertPromoCode(c.Context(), promo)
+	if err != nil {
+		if errors.Is(err, db.ErrPromoCodeExists) {
+			return NewError(http.StatusConflict, fmt.Sprintf("promo code %s already exists", types.NormalizePromoCode(params.Code)))
+		}
+		return err
+	}
+
+	return c.Status(http.StatusCreated).JSON(promo)
+}
+
+func (h *PromoHandler) HandleGetPromoCodes(c *fiber.Ctx) error {
+	promos, err := h.store.Promo.GetPromoCodes(c.Context())
+	if err != nil {
+		return err
+	}
+	if promos == nil {
+		promos = []*types.PromoCode{}
+	}
+	return c.JSON(promos)
+}
+
+func (h *PromoHandler) HandleGetPromoCode(c *fiber.Ctx) error {
+	promo, err := h.store.Promo.GetPromoCodeByCode(c.Context(), c.Params("code"))
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return ErrResourceNotFound()
+		}
+		return err
+	}
+	return c.JSON(promo)
+}
+
+// Bookings keep the discount of a deleted code.
+func (h *PromoHandler) HandleDeletePromoCode(c *fiber.Ctx) error {
+	code := c.Params("code")
+	if err := h.store.Promo.DeletePromoCode(c.Context(), code); err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return ErrResourceNotFound()
+		}
+		return err
+	}
+	return c.JSON(map[string]string{"deleted": types.NormalizePromoCode(code)})
+}
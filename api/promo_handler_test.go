@@ -0,0 +1,370 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/raphaelmb/go-hotel-reservation/db"
+	"github.com/raphaelmb/go-hotel-reservation/db/fixtures"
+	"github.com/raphaelmb/go-hotel-reservation/events"
+	"github.com/raphaelmb/go-hotel-reservation/policy"
+	"github.com/raphaelmb/go-hotel-reservation/types"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func promoRoomRequest(action string, room *types.Room, user *types.User, from, till time.Time, code string) *http.Request {
+	b, _ := json.Marshal(BookRoomParams{
+		FromDate:   from,
+		TillDate:   till,
+		NumPersons: 1,
+		PromoCode:  code,
+	})
+	req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/%s/%s", room.ID.Hex(), action), bytes.NewReader(b))
+	req.Header.Add("Content-Type", "application/json")
+	req.Header.Add("X-Api-Token", createToken(user))
+	return req
+}
+
+func TestAdminManagePromoCodes(t *testing.T) {
+	db := setup(t)
+	defer db.tearDown(t)
+
+	var (
+		admin        = fixtures.AddUser(db.Store, "admin", "admin", true)
+		hotel        = fixtures.AddHotel(db.Store, "hotel", "anywhere", 4, nil)
+		promoHandler = NewPromoHandler(db.Store)
+
+		app   = fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+		route = app.Group("/", JWTAuthentication(db.User, db.Token, testTokens, nil), Require(policy.ManagePromos))
+	)
+	route.Post("/", promoHandler.HandlePostPromoCode)
+	route.Get("/", promoHandler.HandleGetPromoCodes)
+	route.Get("/:code", promoHandler.HandleGetPromoCode)
+	route.Delete("/:code", promoHandler.HandleDeletePromoCode)
+
+	send := func(t *testing.T, method, target string, body any) *http.Response {
+		b, _ := json.Marshal(body)
+		req := httptest.NewRequest(method, target, bytes.NewReader(b))
+		req.Header.Add("Content-Type", "application/json")
+		req.Header.Add("X-Api-Token", createToken(admin))
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	t.Run("should create a promo code", func(t *testing.T) {
+		params := types.CreatePromoCodeParams{Code: "summer-10", Kind: types.PromoPercent, Percent: 10, HotelIDs: []string{hotel.ID.Hex()}}
+		resp := send(t, http.MethodPost, "/", params)
+		if resp.StatusCode != http.StatusCreated {
+			t.Fatalf("expected 201 response but got %d", resp.StatusCode)
+		}
+		var promo types.PromoCode
+		if err := json.NewDecoder(resp.Body).Decode(&promo); err != nil {
+			t.Fatal(err)
+		}
+		if promo.Code != "SUMMER-10" {
+			t.Fatalf("expected code SUMMER-10 but got %s", promo.Code)
+		}
+	})
+
+	tests := []struct {
+		name   string
+		params types.CreatePromoCodeParams
+		status int
+	}{
+		{"should reject a taken code", types.CreatePromoCodeParams{Code: "Summer-10", Kind: types.PromoPercent, Percent: 5}, http.StatusConflict},
+		{"should reject an invalid code", types.CreatePromoCodeParams{Code: "no spaces", Kind: types.PromoPercent, Percent: 5}, http.StatusBadRequest},
+		{"should reject an invalid percent", types.CreatePromoCodeParams{Code: "HALF", Kind: types.PromoPercent, Percent: 150}, http.StatusBadRequest},
+		{"should reject a fixed amount in another currency than the hotel", types.CreatePromoCodeParams{
+			Code:     "FIVE",
+			Kind:     types.PromoFixed,
+			Amount:   types.NewMoney(5, "EUR"),
+			HotelIDs: []string{hotel.ID.Hex()},
+		}, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := send(t, http.MethodPost, "/", tt.params)
+			if resp.StatusCode != tt.status {
+				t.Fatalf("expected %d response but got %d", tt.status, resp.StatusCode)
+			}
+		})
+	}
+
+	t.Run("should list promo codes", func(t *testing.T) {
+		resp := send(t, http.MethodGet, "/", nil)
+		var promos []*types.PromoCode
+		if err := json.NewDecoder(resp.Body).Decode(&promos); err != nil {
+			t.Fatal(err)
+		}
+		if len(promos) != 1 || promos[0].Code != "SUMMER-10" {
+			t.Fatalf("expected only promo code SUMMER-10 but got %+v", promos)
+		}
+	})
+
+	t.Run("should delete a promo code", func(t *testing.T) {
+		if resp := send(t, http.MethodDelete, "/summer-10", nil); resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200 response but got %d", resp.StatusCode)
+		}
+		if resp := send(t, http.MethodGet, "/SUMMER-10", nil); resp.StatusCode != http.StatusNotFound {
+			t.Fatalf("expected 404 response but got %d", resp.StatusCode)
+		}
+	})
+}
+
+func TestBookRoomWithPromoCode(t *testing.T) {
+	db := setup(t)
+	defer db.tearDown(t)
+
+	var (
+		user  = fixtures.AddUser(db.Store, "james", "foo", false)
+		hotel = fixtures.AddHotel(db.Store, "hotel", "anywhere", 4, nil)
+		room  = fixtures.AddRoom(db.Store, "small", true, 100, hotel.ID)
+		other = fixtures.AddHotel(db.Store, "other", "anywhere", 4, nil)
+
+		roomHandler = NewRoomHandler(db.Store)
+		app         = fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+		route       = app.Group("/", JWTAuthentication(db.User, db.Token, testTokens, nil))
+	)
+	route.Post("/:id/quote", roomHandler.HandleQuoteRoom)
+	route.Post("/:id/book", roomHandler.HandleBookRoom)
+
+	for _, promo := range []*types.PromoCode{
+		{Code: "TENOFF", Kind: types.PromoPercent, Percent: 10, MinNights: 2, MaxUsesPerUser: 1},
+		{Code: "FIFTY", Kind: types.PromoFixed, Amount: types.NewMoney(50, types.DefaultCurrency)},
+		{Code: "ELSEWHERE", Kind: types.PromoPercent, Percent: 10, HotelIDs: []primitive.ObjectID{other.ID}},
+		{Code: "EXPIRED", Kind: types.PromoPercent, Percent: 10, ValidTill: time.Now().AddDate(0, 0, -1)},
+		{Code: "LIMITED", Kind: types.PromoPercent, Percent: 5, MaxUses: 3},
+	} {
+		if _, err := db.Promo.InsertPromoCode(context.Background(), promo); err != nil {
+			t.Fatal(err)
+		}
+	}
+	getUses := func(t *testing.T, code string) int {
+		promo, err := db.Promo.GetPromoCodeByCode(context.Background(), code)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return promo.Uses
+	}
+
+	// a tuesday, to stay clear of weekend pricing
+	from := time.Now().UTC().AddDate(0, 0, 7)
+	for from.Weekday() != time.Tuesday {
+		from = from.AddDate(0, 0, 1)
+	}
+
+	tests := []struct {
+		name   string
+		code   string
+		nights int
+		status int
+		total  types.Money
+	}{
+		{"percent code", "TENOFF", 2, http.StatusOK, types.NewMoney(180, types.DefaultCurrency)},
+		{"fixed code in any case", "fifty", 2, http.StatusOK, types.NewMoney(150, types.DefaultCurrency)},
+		{"unknown code", "NOPE", 2, http.StatusUnprocessableEntity, types.Money{}},
+		{"stay too short", "TENOFF", 1, http.StatusUnprocessableEntity, types.Money{}},
+		{"code of another hotel", "ELSEWHERE", 2, http.StatusUnprocessableEntity, types.Money{}},
+		{"expired code", "EXPIRED", 2, http.StatusUnprocessableEntity, types.Money{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := app.Test(promoRoomRequest("quote", room, user, from, from.AddDate(0, 0, tt.nights), tt.code))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.status {
+				t.Fatalf("expected %d response but got %d", tt.status, resp.StatusCode)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+			var quote types.PriceQuote
+			if err := json.NewDecoder(resp.Body).Decode(&quote); err != nil {
+				t.Fatal(err)
+			}
+			if quote.Promo == nil || quote.Total != tt.total {
+				t.Fatalf("expected total %s with a promo discount but got %s and %+v", tt.total, quote.Total, quote.Promo)
+			}
+		})
+	}
+
+	t.Run("booking should record the discount and count the use", func(t *testing.T) {
+		resp, err := app.Test(promoRoomRequest("book", room, user, from, from.AddDate(0, 0, 2), "tenoff"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200 response but got %d", resp.StatusCode)
+		}
+		var booking *types.Booking
+		if err := json.NewDecoder(resp.Body).Decode(&booking); err != nil {
+			t.Fatal(err)
+		}
+		stored, err := db.Booking.GetBookingByID(context.Background(), booking.ID.Hex())
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := types.PromoDiscount{Code: "TENOFF", Discount: types.NewMoney(20, types.DefaultCurrency)}
+		if stored.Price == nil || stored.Price.Promo == nil || *stored.Price.Promo != want {
+			t.Fatalf("expected promo discount %+v but got %+v", want, stored.Price)
+		}
+		if uses := getUses(t, "TENOFF"); uses != 1 {
+			t.Fatalf("expected 1 use but got %d", uses)
+		}
+	})
+
+	t.Run("should reject a code used up by the user", func(t *testing.T) {
+		next := from.AddDate(0, 0, 7)
+		resp, err := app.Test(promoRoomRequest("book", room, user, next, next.AddDate(0, 0, 2), "TENOFF"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != http.StatusUnprocessableEntity {
+			t.Fatalf("expected 422 response but got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("should not count the use of a failed booking", func(t *testing.T) {
+		resp, err := app.Test(promoRoomRequest("book", room, user, from, from.AddDate(0, 0, 2), "FIFTY"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != http.StatusConflict {
+			t.Fatalf("expected 409 response but got %d", resp.StatusCode)
+		}
+		if uses := getUses(t, "FIFTY"); uses != 0 {
+			t.Fatalf("expected 0 uses but got %d", uses)
+		}
+	})
+
+	t.Run("concurrent bookings should not exceed the cap", func(t *testing.T) {
+		const attempts = 10
+		var (
+			wg    sync.WaitGroup
+			mu    sync.Mutex
+			codes = map[int]int{}
+		)
+		for i := 0; i < attempts; i++ {
+			guest := fixtures.AddUser(db.Store, fmt.Sprintf("guest%d", i), "foo", false)
+			stay := from.AddDate(0, 0, 14+3*i)
+			req := promoRoomRequest("book", room, guest, stay, stay.AddDate(0, 0, 2), "LIMITED")
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				resp, err := app.Test(req, -1)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				mu.Lock()
+				codes[resp.StatusCode]++
+				mu.Unlock()
+			}()
+		}
+		wg.Wait()
+
+		if codes[http.StatusOK] != 3 || codes[http.StatusUnprocessableEntity] != attempts-3 {
+			t.Fatalf("expected 3 bookings and %d rejections but got %v", attempts-3, codes)
+		}
+		if uses := getUses(t, "LIMITED"); uses != 3 {
+			t.Fatalf("expected 3 uses but got %d", uses)
+		}
+	})
+}
+
+func TestReleaseHoldPromoCode(t *testing.T) {
+	tdb := setup(t)
+	defer tdb.tearDown(t)
+
+	var (
+		ctx   = context.Background()
+		user  = fixtures.AddUser(tdb.Store, "james", "foo", false)
+		hotel = fixtures.AddHotel(tdb.Store, "hotel", "anywhere", 4, nil)
+		room  = fixtures.AddRoom(tdb.Store, "small", true, 100, hotel.ID)
+		from  = time.Now().UTC().AddDate(0, 0, 7)
+
+		roomHandler    = NewRoomHandler(tdb.Store)
+		bookingHandler = NewBookingHandler(tdb.Store, &events.Recorder{})
+		app            = fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+		route          = app.Group("/", JWTAuthentication(tdb.User, tdb.Token, testTokens, nil))
+	)
+	route.Post("/:id/hold", roomHandler.HandleHoldRoom)
+	route.Post("/:id/book", roomHandler.HandleBookRoom)
+	route.Post("/booking/:id/cancel", bookingHandler.HandleCancelBooking)
+
+	if _, err := tdb.Promo.InsertPromoCode(ctx, &types.PromoCode{Code: "ONCE", Kind: types.PromoPercent, Percent: 10, MaxUses: 1, MaxUsesPerUser: 1}); err != nil {
+		t.Fatal(err)
+	}
+	hold := func(t *testing.T) *types.Booking {
+		resp, err := app.Test(promoRoomRequest("hold", room, user, from, from.AddDate(0, 0, 2), "ONCE"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200 response but got %d", resp.StatusCode)
+		}
+		var hold *types.Booking
+		if err := json.NewDecoder(resp.Body).Decode(&hold); err != nil {
+			t.Fatal(err)
+		}
+		return hold
+	}
+	uses := func(t *testing.T) int {
+		promo, err := tdb.Promo.GetPromoCodeByCode(ctx, "ONCE")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return promo.Uses
+	}
+
+	t.Run("an expired hold should give back its use of the code", func(t *testing.T) {
+		hold(t)
+		if _, err := db.ExpireHolds(ctx, tdb.Store, time.Now().Add(holdDuration+time.Minute)); err != nil {
+			t.Fatal(err)
+		}
+		if n := uses(t); n != 0 {
+			t.Fatalf("expected 0 uses but got %d", n)
+		}
+	})
+
+	t.Run("a cancelled hold should give back its use of the code", func(t *testing.T) {
+		booking := hold(t)
+		req := httptest.NewRequest(http.MethodPost, "/booking/"+booking.ID.Hex()+"/cancel", nil)
+		req.Header.Add("X-Api-Token", createToken(user))
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200 response but got %d", resp.StatusCode)
+		}
+		if n := uses(t); n != 0 {
+			t.Fatalf("expected 0 uses but got %d", n)
+		}
+	})
+
+	t.Run("should book with the released code", func(t *testing.T) {
+		resp, err := app.Test(promoRoomRequest("book", room, user, from, from.AddDate(0, 0, 2), "ONCE"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200 response but got %d", resp.StatusCode)
+		}
+		if n := uses(t); n != 1 {
+			t.Fatalf("expected 1 use but got %d", n)
+		}
+	})
+}
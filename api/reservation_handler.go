@@ -0,0 +1,255 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/raphaelmb/go-hotel-reservation/db"
+	"github.com/raphaelmb/go-hotel-reservation/events"
+	"github.com/raphaelmb/go-hotel-reservation/types"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type ReservationHandler struct {
+	store     *db.Store
+	publisher events.Publisher
+}
+
+func NewReservationHandler(store *db.Store, publisher events.Publisher) *ReservationHandler {
+	return &ReservationHandler{
+		store:     store,
+		publisher: publisher,
+	}
+}
+
+type ReservationResp struct {
+	*types.Reservation
+	Bookings []*types.Booking `json:"bookings"`
+}
+
+// HandlePostReservation books every room of the reservation or none.
+func (h *ReservationHandler) HandlePostReservation(c *fiber.Ctx) error {
+	var params types.CreateReservationParams
+	if err := c.BodyParser(&params); err != nil {
+		return ErrBadRequest()
+	}
+	if errors := params.Validate(); len(errors) > 0 {
+		return c.Status(http.StatusBadRequest).JSON(errors)
+	}
+	stay := BookRoomParams{FromDate: params.FromDate, TillDate: params.TillDate, NumPersons: 1}
+	if err := stay.validate(); err != nil {
+		return err
+	}
+	user, err := getAuthUser(c)
+	if err != nil {
+		return ErrUnauthorized()
+	}
+
+	rooms := make([]*types.Room, len(params.Rooms))
+	for i, p := range params.Rooms {
+		room, err := h.store.Room.GetRoomByID(c.Context(), p.RoomID)
+		if err != nil {
+			if errors.Is(err, db.ErrNotFound) {
+				return NewError(http.StatusNotFound, fmt.Sprintf("room %s not found", p.RoomID))
+			}
+			return err
+		}
+		if i > 0 && room.HotelID != rooms[0].HotelID {
+			return NewError(http.StatusUnprocessableEntity, "rooms should all be in the same hotel")
+		}
+		if p.NumPersons > room.Capacity() {
+			return NewError(http.StatusUnprocessableEntity, fmt.Sprintf("room %s fits at most %d persons", room.ID.Hex(), room.Capacity()))
+		}
+		rooms[i] = room
+	}
+	hotel, err := h.store.Hotel.GetHotelByID(c.Context(), rooms[0].HotelID.Hex())
+	if err != nil {
+		return err
+	}
+
+	reservation := &types.Reservation{
+		ID:       primitive.NewObjectID(),
+		UserID:   user.ID,
+		HotelID:  hotel.ID,
+		FromDate: params.FromDate,
+		TillDate: params.TillDate,
+		Status:   types.ReservationConfirmed,
+		Total:    types.Money{Currency: hotel.BaseCurrency()},
+	}
+	newBookings := func() []*types.Booking {
+		bookings := make([]*types.Booking, len(rooms))
+		for i, room := range rooms {
+			bookings[i] = &types.Booking{
+				UserID:             user.ID,
+				RoomID:             room.ID,
+				HotelID:            hotel.ID,
+				ReservationID:      reservation.ID,
+				FromDate:           params.FromDate,
+				TillDate:           params.TillDate,
+				NumPersons:         params.Rooms[i].NumPersons,
+				Price:              hotel.Quote(room, params.FromDate, params.TillDate, params.Rooms[i].NumPersons, nil),
+				CancellationPolicy: hotel.CancellationPolicyFor(room),
+			}
+			bookings[i].SetStatus(types.BookingConfirmed, time.Now())
+		}
+		return bookings
+	}
+
+	// expired holds might be the ones holding the rooms
+	bookings, err := h.store.Booking.InsertBookings(c.Context(), newBookings())
+	if errors.Is(err, db.ErrRoomNotAvailable) {
+		expired, expireErr := db.ExpireHolds(c.Context(), h.store, time.Now())
+		if expireErr != nil {
+			err = expireErr
+		} else if len(expired) > 0 {
+			bookings, err = h.store.Booking.InsertBookings(c.Context(), newBookings())
+		}
+	}
+	if err != nil {
+		if errors.Is(err, db.ErrRoomNotAvailable) {
+			return NewError(http.StatusConflict, "the rooms are not all available for the stay")
+		}
+		return err
+	}
+
+	// the code of the first booking keeps reservation codes unique among booking codes
+	reservation.ConfirmationCode = bookings[0].ConfirmationCode
+	for _, booking := range bookings {
+		reservation.BookingIDs = append(reservation.BookingIDs, booking.ID)
+		reservation.Total = reservation.Total.Add(booking.Price.Total)
+	}
+	if _, err := h.store.Reservation.InsertReservation(c.Context(), reservation); err != nil {
+		// release the rooms of a reservation which could not be stored
+		for _, booking := range bookings {
+			if _, cancelErr := h.store.Booking.UpdateBookingStatus(c.Context(), booking.ID.Hex(), types.BookingCancelled); cancelErr != nil {
+				err = errors.Join(err, fmt.Errorf("releasing booking %s: %w", booking.ID.Hex(), cancelErr))
+			}
+		}
+		return err
+	}
+
+	return h.respond(c, reservation, bookings)
+}
+
+func (h *ReservationHandler) HandleGetReservation(c *fiber.Ctx) error {
+	reservation, err := h.getUserReservation(c)
+	if err != nil {
+		return err
+	}
+	bookings, err := h.getBookings(c, reservation)
+	if err != nil {
+		return err
+	}
+	return h.respond(c, reservation, bookings)
+}
+
+func (h *ReservationHandler) HandleCancelReservation(c *fiber.Ctx) error {
+	reservation, err := h.getUserReservation(c)
+	if err != nil {
+		return err
+	}
+	if reservation.Status == types.ReservationCancelled {
+		return NewError(http.StatusConflict, fmt.Sprintf("reservation %s is already cancelled", reservation.ID.Hex()))
+	}
+	params, err := parseCancelBookingParams(c)
+	if err != nil {
+		return err
+	}
+	if errors := params.Validate(); len(errors) > 0 {
+		return c.Status(http.StatusBadRequest).JSON(errors)
+	}
+	bookings, err := h.getBookings(c, reservation)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if arrival := types.Nights(reservation.FromDate, reservation.TillDate)[0]; now.After(arrival.AddDate(0, 0, 1)) {
+		return NewError(http.StatusConflict, fmt.Sprintf("reservation %s started on %s", reservation.ID.Hex(), arrival.Format(dateLayout)))
+	}
+	summary := &types.Cancellation{
+		At:      now.UTC(),
+		By:      reservation.UserID,
+		Reason:  params.Reason,
+		Penalty: types.Money{Currency: reservation.Total.Currency},
+		Refund:  types.Money{Currency: reservation.Total.Currency},
+	}
+	for _, booking := range bookings {
+		cancellation := booking.Cancellation
+		if booking.Status.CanTransitionTo(types.BookingCancelled) {
+			cancellation = booking.CancellationAt(now)
+		}
+		// bookings cancelled on their own count as well
+		if cancellation != nil {
+			summary.Penalty = summary.Penalty.Add(cancellation.Penalty)
+			summary.Refund = summary.Refund.Add(cancellation.Refund)
+		}
+	}
+
+	// claim the reservation first, so concurrent requests cancel it once
+	cancelled, err := h.store.Reservation.UpdateReservationStatus(c.Context(), reservation.ID.Hex(), reservation.Status, types.ReservationCancelled, summary)
+	if err != nil {
+		if errors.Is(err, db.ErrReservationStatusChanged) {
+			return NewError(http.StatusConflict, fmt.Sprintf("reservation %s is already cancelled", reservation.ID.Hex()))
+		}
+		return err
+	}
+	for i, booking := range bookings {
+		if !booking.Status.CanTransitionTo(types.BookingCancelled) {
+			continue
+		}
+		if bookings[i], err = cancelBooking(c, h.store, booking, reservation.UserID, params.Reason, now); err != nil {
+			if _, revertErr := h.store.Reservation.UpdateReservationStatus(c.Context(), reservation.ID.Hex(), types.ReservationCancelled, types.ReservationPartiallyCancelled, nil); revertErr != nil {
+				log.Printf("failed to record the partial cancellation of reservation %s: %v", reservation.ID.Hex(), revertErr)
+			}
+			return err
+		}
+		if err := offerFreedRoom(c.Context(), h.store, h.publisher, bookings[i], now); err != nil {
+			log.Printf("failed to offer room %s to the waitlist: %v", booking.RoomID.Hex(), err)
+		}
+	}
+	return h.respond(c, cancelled, bookings)
+}
+
+func (h *ReservationHandler) getUserReservation(c *fiber.Ctx) (*types.Reservation, error) {
+	if _, err := primitive.ObjectIDFromHex(c.Params("id")); err != nil {
+		return nil, ErrInvalidID()
+	}
+	reservation, err := h.store.Reservation.GetReservationByID(c.Context(), c.Params("id"))
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return nil, ErrResourceNotFound()
+		}
+		return nil, err
+	}
+	user, err := getAuthUser(c)
+	if err != nil {
+		return nil, ErrUnauthorized()
+	}
+	if reservation.UserID != user.ID {
+		return nil, ErrForbidden()
+	}
+	return reservation, nil
+}
+
+func (h *ReservationHandler) getBookings(c *fiber.Ctx, reservation *types.Reservation) ([]*types.Booking, error) {
+	filter := db.BookingFilter{ReservationID: reservation.ID, SortBy: db.SortByCreation}
+	return h.store.Booking.GetBookings(c.Context(), filter, nil)
+}
+
+func (h *ReservationHandler) respond(c *fiber.Ctx, reservation *types.Reservation, bookings []*types.Booking) error {
+	conv := getPriceConverter(c)
+	reservation, err := conv.reservation(reservation)
+	if err != nil {
+		return err
+	}
+	bookings, err = conv.bookings(bookings)
+	if err != nil {
+		return err
+	}
+	return c.JSON(ReservationResp{Reservation: reservation, Bookings: bookings})
+}
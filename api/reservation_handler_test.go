@@ -0,0 +1,286 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/raphaelmb/go-hotel-reservation/db/fixtures"
+	"github.com/raphaelmb/go-hotel-reservation/events"
+	"github.com/raphaelmb/go-hotel-reservation/types"
+)
+
+func reservationRequest(user *types.User, from, till time.Time, rooms ...*types.Room) *http.Request {
+	params := types.CreateReservationParams{FromDate: from, TillDate: till}
+	for _, room := range rooms {
+		params.Rooms = append(params.Rooms, types.ReservationRoomParams{RoomID: room.ID.Hex(), NumPersons: 1})
+	}
+	b, _ := json.Marshal(params)
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(b))
+	req.Header.Add("Content-Type", "application/json")
+	req.Header.Add("X-Api-Token", createToken(user))
+	return req
+}
+
+func TestReservation(t *testing.T) {
+	db := setup(t)
+	defer db.tearDown(t)
+
+	var (
+		user       = fixtures.AddUser(db.Store, "james", "foo", false)
+		otherUser  = fixtures.AddUser(db.Store, "another", "user", false)
+		hotel      = fixtures.AddHotel(db.Store, "hotel", "anywhere", 4, nil)
+		small      = fixtures.AddRoom(db.Store, "small", true, 100, hotel.ID)
+		large      = fixtures.AddRoom(db.Store, "large", true, 200, hotel.ID)
+		spare      = fixtures.AddRoom(db.Store, "small", false, 100, hotel.ID)
+		otherHotel = fixtures.AddHotel(db.Store, "other", "anywhere", 4, nil)
+		elsewhere  = fixtures.AddRoom(db.Store, "small", true, 100, otherHotel.ID)
+
+		reservationHandler = NewReservationHandler(db.Store, &events.Recorder{})
+		bookingHandler     = NewBookingHandler(db.Store, &events.Recorder{})
+		app                = fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+		route              = app.Group("/", JWTAuthentication(db.User, db.Token, testTokens, nil))
+	)
+	route.Patch("/booking/:id", bookingHandler.HandleModifyBooking)
+	route.Post("/booking/:id/cancel", bookingHandler.HandleCancelBooking)
+	route.Get("/booking/:id/cancel-preview", bookingHandler.HandleCancelPreview)
+	route.Post("/", reservationHandler.HandlePostReservation)
+	route.Get("/:id", reservationHandler.HandleGetReservation)
+	route.Post("/:id/cancel", reservationHandler.HandleCancelReservation)
+	route.Post("/lookup", bookingHandler.HandleLookupBooking)
+
+	ctx := context.Background()
+	policy := types.UpdateHotelParams{CancellationPolicy: &types.CancellationPolicy{FreeDays: 7, PenaltyPercent: 50}}
+	if err := db.Hotel.Update(ctx, hotel.ID.Hex(), policy); err != nil {
+		t.Fatal(err)
+	}
+
+	send := func(t *testing.T, req *http.Request, status int) *ReservationResp {
+		resp, err := app.Test(req, -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != status {
+			t.Fatalf("expected %d response but got %d", status, resp.StatusCode)
+		}
+		var reservation *ReservationResp
+		if status == http.StatusOK {
+			if err := json.NewDecoder(resp.Body).Decode(&reservation); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return reservation
+	}
+	day := func(days int) time.Time {
+		return time.Now().UTC().AddDate(0, 0, days)
+	}
+	reservationPath := func(reservation *ReservationResp, user *types.User, action string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "/"+reservation.ID.Hex(), nil)
+		if len(action) > 0 {
+			req = httptest.NewRequest(http.MethodPost, "/"+reservation.ID.Hex()+"/"+action, nil)
+		}
+		req.Header.Add("X-Api-Token", createToken(user))
+		return req
+	}
+
+	var reservation *ReservationResp
+	t.Run("should book every room of the reservation", func(t *testing.T) {
+		reservation = send(t, reservationRequest(user, day(30), day(32), small, large), http.StatusOK)
+		if len(reservation.ConfirmationCode) != 8 || strings.ContainsAny(reservation.ConfirmationCode, "O0I1") {
+			t.Fatalf("expected a readable confirmation code but got %q", reservation.ConfirmationCode)
+		}
+		if len(reservation.Bookings) != 2 {
+			t.Fatalf("expected 2 bookings but got %d", len(reservation.Bookings))
+		}
+		total := types.Money{Currency: types.DefaultCurrency}
+		for _, booking := range reservation.Bookings {
+			if booking.ReservationID != reservation.ID || booking.Status != types.BookingConfirmed {
+				t.Fatalf("expected a confirmed booking of the reservation but got %+v", booking)
+			}
+			total = total.Add(booking.Price.Total)
+		}
+		if reservation.Total != total {
+			t.Fatalf("expected a total of %s but got %s", total, reservation.Total)
+		}
+	})
+
+	t.Run("should book none of the rooms if one is taken", func(t *testing.T) {
+		fixtures.AddBooking(db.Store, otherUser.ID, spare.ID, day(40), day(42))
+		send(t, reservationRequest(user, day(40), day(42), small, spare), http.StatusConflict)
+		send(t, reservationRequest(user, day(40), day(42), small), http.StatusOK)
+	})
+
+	tests := []struct {
+		name   string
+		req    *http.Request
+		status int
+	}{
+		{"no rooms", reservationRequest(user, day(50), day(52)), http.StatusBadRequest},
+		{"the same room twice", reservationRequest(user, day(50), day(52), small, small), http.StatusBadRequest},
+		{"a stay in the past", reservationRequest(user, day(-2), day(1), small), http.StatusBadRequest},
+		{"rooms of several hotels", reservationRequest(user, day(50), day(52), small, elsewhere), http.StatusUnprocessableEntity},
+		{"a room of the other user's reservation", reservationPath(reservation, otherUser, ""), http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run("should reject "+tt.name, func(t *testing.T) {
+			send(t, tt.req, tt.status)
+		})
+	}
+
+	t.Run("should get the reservation with its bookings", func(t *testing.T) {
+		got := send(t, reservationPath(reservation, user, ""), http.StatusOK)
+		if got.ConfirmationCode != reservation.ConfirmationCode || len(got.Bookings) != 2 {
+			t.Fatalf("expected reservation %s with 2 bookings but got %+v", reservation.ConfirmationCode, got)
+		}
+	})
+
+	t.Run("should look up the reservation by its confirmation code", func(t *testing.T) {
+		if reservation.ConfirmationCode != reservation.Bookings[0].ConfirmationCode {
+			t.Fatalf("expected the code of the first booking but got %q", reservation.ConfirmationCode)
+		}
+		lookup := func(code string) *http.Request {
+			b, _ := json.Marshal(LookupBookingParams{ConfirmationCode: code, LastName: user.LastName})
+			req := httptest.NewRequest(http.MethodPost, "/lookup", bytes.NewReader(b))
+			req.Header.Add("Content-Type", "application/json")
+			req.Header.Add("X-Api-Token", createToken(user))
+			return req
+		}
+		got := send(t, lookup(reservation.ConfirmationCode), http.StatusOK)
+		if got.Reservation == nil || got.ID != reservation.ID || len(got.Bookings) != 2 {
+			t.Fatalf("expected reservation %s with 2 bookings but got %+v", reservation.ID.Hex(), got)
+		}
+		got = send(t, lookup(reservation.Bookings[1].ConfirmationCode), http.StatusOK)
+		if got.ID != reservation.Bookings[1].ID || len(got.Bookings) != 0 {
+			t.Fatalf("expected booking %s but got %+v", reservation.Bookings[1].ID.Hex(), got)
+		}
+	})
+
+	t.Run("should not change or cancel a booking of the reservation on its own", func(t *testing.T) {
+		path := "/booking/" + reservation.Bookings[0].ID.Hex()
+		b, _ := json.Marshal(ModifyBookingParams{NumPersons: 1})
+		reqs := []*http.Request{
+			httptest.NewRequest(http.MethodPatch, path, bytes.NewReader(b)),
+			httptest.NewRequest(http.MethodPost, path+"/cancel", nil),
+			httptest.NewRequest(http.MethodGet, path+"/cancel-preview", nil),
+		}
+		for _, req := range reqs {
+			req.Header.Add("Content-Type", "application/json")
+			req.Header.Add("X-Api-Token", createToken(user))
+			send(t, req, http.StatusConflict)
+		}
+	})
+
+	t.Run("should cancel every booking of the reservation", func(t *testing.T) {
+		cancelled := send(t, reservationPath(reservation, user, "cancel"), http.StatusOK)
+		if cancelled.Status != types.ReservationCancelled || cancelled.Cancellation == nil {
+			t.Fatalf("expected a cancelled reservation but got %+v", cancelled.Reservation)
+		}
+		if cancelled.Cancellation.Refund != reservation.Total {
+			t.Fatalf("expected a refund of %s but got %s", reservation.Total, cancelled.Cancellation.Refund)
+		}
+		for _, booking := range cancelled.Bookings {
+			if booking.Status != types.BookingCancelled {
+				t.Fatalf("expected booking %s to be cancelled but got %s", booking.ID.Hex(), booking.Status)
+			}
+		}
+		send(t, reservationPath(reservation, user, "cancel"), http.StatusConflict)
+	})
+
+	t.Run("should sum the penalties of bookings cancelled on their own", func(t *testing.T) {
+		late := send(t, reservationRequest(user, day(3), day(5), small, large), http.StatusOK)
+		first := late.Bookings[0]
+		if _, err := db.Booking.CancelBooking(ctx, first, first.CancellationAt(time.Now())); err != nil {
+			t.Fatal(err)
+		}
+		cancelled := send(t, reservationPath(late, user, "cancel"), http.StatusOK)
+		if want := late.Total.Percent(50); cancelled.Cancellation.Penalty != want {
+			t.Fatalf("expected a penalty of %s but got %s", want, cancelled.Cancellation.Penalty)
+		}
+	})
+
+	t.Run("concurrent reservations of the same rooms should not both succeed", func(t *testing.T) {
+		const attempts = 5
+		var (
+			wg    sync.WaitGroup
+			mu    sync.Mutex
+			codes = map[int]int{}
+		)
+		for i := 0; i < attempts; i++ {
+			req := reservationRequest(user, day(60), day(62), small, large)
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				resp, err := app.Test(req, -1)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				mu.Lock()
+				codes[resp.StatusCode]++
+				mu.Unlock()
+			}()
+		}
+		wg.Wait()
+
+		if codes[http.StatusOK] != 1 || codes[http.StatusConflict] != attempts-1 {
+			t.Fatalf("expected 1 reservation and %d conflicts but got %v", attempts-1, codes)
+		}
+	})
+
+	t.Run("concurrent cancellations should cancel the reservation once", func(t *testing.T) {
+		const attempts = 5
+		var (
+			target = send(t, reservationRequest(user, day(70), day(72), small, large), http.StatusOK)
+			wg     sync.WaitGroup
+			mu     sync.Mutex
+			codes  = map[int]int{}
+		)
+		for i := 0; i < attempts; i++ {
+			req := reservationPath(target, user, "cancel")
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				resp, err := app.Test(req, -1)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				mu.Lock()
+				codes[resp.StatusCode]++
+				mu.Unlock()
+			}()
+		}
+		wg.Wait()
+
+		if codes[http.StatusOK] != 1 || codes[http.StatusConflict] != attempts-1 {
+			t.Fatalf("expected 1 cancellation and %d conflicts but got %v", attempts-1, codes)
+		}
+	})
+
+	t.Run("should finish cancelling a partially cancelled reservation", func(t *testing.T) {
+		partial := send(t, reservationRequest(user, day(80), day(82), small, large), http.StatusOK)
+		first := partial.Bookings[0]
+		if _, err := db.Booking.CancelBooking(ctx, first, first.CancellationAt(time.Now())); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.Reservation.UpdateReservationStatus(ctx, partial.ID.Hex(), types.ReservationConfirmed, types.ReservationPartiallyCancelled, nil); err != nil {
+			t.Fatal(err)
+		}
+		cancelled := send(t, reservationPath(partial, user, "cancel"), http.StatusOK)
+		if cancelled.Status != types.ReservationCancelled || cancelled.Cancellation.Refund != partial.Total {
+			t.Fatalf("expected a cancelled reservation refunding %s but got %+v", partial.Total, cancelled.Reservation)
+		}
+		for _, booking := range cancelled.Bookings {
+			if booking.Status != types.BookingCancelled {
+				t.Fatalf("expected booking %s to be cancelled but got %s", booking.ID.Hex(), booking.Status)
+			}
+		}
+	})
+}
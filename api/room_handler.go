@@ -1,28 +1,37 @@
 package api
 
 import (
-	"context"
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/raphaelmb/go-hotel-reservation/db"
 	"github.com/raphaelmb/go-hotel-reservation/types"
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+const holdDuration = 15 * time.Minute
+
 type BookRoomParams struct {
 	FromDate   time.Time `json:"fromDate"`
 	TillDate   time.Time `json:"tillDate"`
 	NumPersons int       `json:"numPersons"`
+	PromoCode  string    `json:"promoCode,omitempty"`
 }
 
 func (p BookRoomParams) validate() error {
 	now := time.Now()
 	if now.After(p.FromDate) || now.After(p.TillDate) {
-		return fmt.Errorf("cannot book a room in the past")
+		return NewError(http.StatusBadRequest, "cannot book a room in the past")
+	}
+	if !p.TillDate.After(p.FromDate) {
+		return NewError(http.StatusBadRequest, "tillDate should be after fromDate")
+	}
+	if p.NumPersons < 1 {
+		return NewError(http.StatusBadRequest, "numPersons should be at least 1")
 	}
 
 	return nil
@@ -39,7 +48,11 @@ func NewRoomHandler(store *db.Store) *RoomHandler {
 }
 
 func (h *RoomHandler) HandleGetRooms(c *fiber.Ctx) error {
-	rooms, err := h.store.Room.GetRooms(c.Context(), bson.M{})
+	rooms, err := h.store.Room.GetRooms(c.Context(), db.RoomFilter{})
+	if err != nil {
+		return err
+	}
+	rooms, err = getPriceConverter(c).rooms(rooms)
 	if err != nil {
 		return err
 	}
@@ -47,16 +60,50 @@ func (h *RoomHandler) HandleGetRooms(c *fiber.Ctx) error {
 }
 
 func (h *RoomHandler) HandleBookRoom(c *fiber.Ctx) error {
-	var params BookRoomParams
-	if err := c.BodyParser(&params); err != nil {
-		return fmt.Errorf("unable to parse request body")
-	}
+	return h.reserveRoom(c, func(booking *types.Booking) (*types.Booking, error) {
+		booking.SetStatus(types.BookingConfirmed, time.Now())
+		return h.store.Booking.InsertBooking(c.Context(), booking)
+	})
+}
 
-	if err := params.validate(); err != nil {
+func (h *RoomHandler) HandleHoldRoom(c *fiber.Ctx) error {
+	return h.reserveRoom(c, func(booking *types.Booking) (*types.Booking, error) {
+		return h.store.Booking.InsertHold(c.Context(), booking, time.Now().Add(holdDuration))
+	})
+}
+
+func (h *RoomHandler) HandleQuoteRoom(c *fiber.Ctx) error {
+	params, room, err := h.parseReservation(c)
+	if err != nil {
+		return err
+	}
+	hotel, err := h.store.Hotel.GetHotelByID(c.Context(), room.HotelID.Hex())
+	if err != nil {
+		return err
+	}
+	quote, err := h.quote(c, hotel, room, params)
+	if err != nil {
 		return err
 	}
+	quote, err = getPriceConverter(c).quote(quote)
+	if err != nil {
+		return err
+	}
+	return c.JSON(quote)
+}
 
-	roomID, err := primitive.ObjectIDFromHex(c.Params("id"))
+// reserveRoom redeems the promo code before the insert, so concurrent bookings
+// cannot exceed its limits.
+func (h *RoomHandler) reserveRoom(c *fiber.Ctx, insert func(*types.Booking) (*types.Booking, error)) error {
+	params, room, err := h.parseReservation(c)
+	if err != nil {
+		return err
+	}
+	hotel, err := h.store.Hotel.GetHotelByID(c.Context(), room.HotelID.Hex())
+	if err != nil {
+		return err
+	}
+	price, err := h.quote(c, hotel, room, params)
 	if err != nil {
 		return err
 	}
@@ -68,48 +115,279 @@ func (h *RoomHandler) HandleBookRoom(c *fiber.Ctx) error {
 		})
 	}
 
-	ok, err = h.isRoomAvailable(c.Context(), roomID, params)
+	newBooking := func() *types.Booking {
+		return &types.Booking{
+			UserID:             user.ID,
+			RoomID:             room.ID,
+			HotelID:            room.HotelID,
+			FromDate:           params.FromDate,
+			TillDate:           params.TillDate,
+			NumPersons:         params.NumPersons,
+			Price:              price,
+			CancellationPolicy: hotel.CancellationPolicyFor(room),
+		}
+	}
+
+	if price.Promo != nil {
+		if err := h.store.Promo.RedeemPromoCode(c.Context(), price.Promo.Code, user.ID); err != nil {
+			if errors.Is(err, db.ErrPromoCodeExhausted) || errors.Is(err, db.ErrPromoCodeUserLimit) {
+				return NewError(http.StatusUnprocessableEntity, fmt.Sprintf("%s: %s", price.Promo.Code, err))
+			}
+			return err
+		}
+	}
+
+	inserted, err := insert(newBooking())
+	if errors.Is(err, db.ErrRoomNotAvailable) {
+		expired, expireErr := db.ExpireHolds(c.Context(), h.store, time.Now())
+		if expireErr != nil {
+			err = expireErr
+		} else if len(expired) > 0 {
+			inserted, err = insert(newBooking())
+		}
+	}
+	if err != nil && price.Promo != nil {
+		if releaseErr := h.store.Promo.ReleasePromoCode(c.Context(), price.Promo.Code, user.ID); releaseErr != nil {
+			log.Printf("failed to release promo code %s: %v", price.Promo.Code, releaseErr)
+		}
+	}
 	if err != nil {
+		if errors.Is(err, db.ErrRoomNotAvailable) {
+			return c.Status(http.StatusConflict).JSON(genericResp{
+				Type: "error",
+				Msg:  fmt.Sprintf("room %s is already booked", c.Params("id")),
+			})
+		}
 		return err
 	}
-	if !ok {
-		return c.Status(http.StatusConflict).JSON(genericResp{
-			Type: "error",
-			Msg:  fmt.Sprintf("room %s is already booked", c.Params("id")),
-		})
+
+	inserted, err = getPriceConverter(c).booking(inserted)
+	if err != nil {
+		return err
+	}
+	return c.JSON(inserted)
+}
+
+func (h *RoomHandler) parseReservation(c *fiber.Ctx) (*BookRoomParams, *types.Room, error) {
+	var params BookRoomParams
+	if err := c.BodyParser(&params); err != nil {
+		return nil, nil, fmt.Errorf("unable to parse request body")
+	}
+	if err := params.validate(); err != nil {
+		return nil, nil, err
+	}
+
+	room, err := h.getRoom(c)
+	if err != nil {
+		return nil, nil, err
+	}
+	if params.NumPersons > room.Capacity() {
+		return nil, nil, NewError(http.StatusUnprocessableEntity, fmt.Sprintf("room %s fits at most %d persons", room.ID.Hex(), room.Capacity()))
+	}
+	return &params, room, nil
+}
+
+func (h *RoomHandler) quote(c *fiber.Ctx, hotel *types.Hotel, room *types.Room, params *BookRoomParams) (*types.PriceQuote, error) {
+	return quoteStay(c, h.store, hotel, room, params, time.Now())
+}
+
+// quoteStay applies the promo code if it could be used to book at the given time.
+func quoteStay(c *fiber.Ctx, store *db.Store, hotel *types.Hotel, room *types.Room, params *BookRoomParams, at time.Time) (*types.PriceQuote, error) {
+	var (
+		promo *types.PromoCode
+		err   error
+	)
+	if len(params.PromoCode) > 0 {
+		promo, err = store.Promo.GetPromoCodeByCode(c.Context(), params.PromoCode)
+		if err != nil {
+			if errors.Is(err, db.ErrNotFound) {
+				return nil, NewError(http.StatusUnprocessableEntity, fmt.Sprintf("promo code %s does not exist", params.PromoCode))
+			}
+			return nil, err
+		}
+		nights := len(types.Nights(params.FromDate, params.TillDate))
+		if err := promo.Applies(hotel, nights, at); err != nil {
+			return nil, NewError(http.StatusUnprocessableEntity, err.Error())
+		}
+	}
+	return hotel.Quote(room, params.FromDate, params.TillDate, params.NumPersons, promo), nil
+}
+
+func (h *RoomHandler) HandlePostRoom(c *fiber.Ctx) error {
+	var params types.CreateRoomParams
+	if err := c.BodyParser(&params); err != nil {
+		return ErrBadRequest()
+	}
+
+	if errors := params.Validate(); len(errors) > 0 {
+		return c.Status(http.StatusBadRequest).JSON(errors)
 	}
 
-	booking := types.Booking{
-		UserID:     user.ID,
-		RoomID:     roomID,
-		FromDate:   params.FromDate,
-		TillDate:   params.TillDate,
-		NumPersons: params.NumPersons,
+	hotel, err := h.store.Hotel.GetHotelByID(c.Context(), params.HotelID)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return NewError(http.StatusNotFound, fmt.Sprintf("hotel %s not found", params.HotelID))
+		}
+		return err
+	}
+	if params.Price, err = priceInCurrency(params.Price, hotel.BaseCurrency()); err != nil {
+		return err
 	}
 
-	inserted, err := h.store.Booking.InsertBooking(c.Context(), &booking)
+	room, err := types.NewRoomFromParams(params)
 	if err != nil {
 		return err
 	}
 
-	return c.JSON(inserted)
+	inserted, err := h.store.Room.InsertRoom(c.Context(), room)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(inserted)
+}
+
+func (h *RoomHandler) HandlePutRoom(c *fiber.Ctx) error {
+	var params types.CreateRoomParams
+	if err := c.BodyParser(&params); err != nil {
+		return ErrBadRequest()
+	}
+
+	if errors := params.Validate(); len(errors) > 0 {
+		return c.Status(http.StatusBadRequest).JSON(errors)
+	}
+	// lists left out of the request are cleared
+	if params.Beds == nil {
+		params.Beds = []types.Bed{}
+	}
+	if params.Amenities == nil {
+		params.Amenities = []string{}
+	}
+	// without a policy the room falls back to the policy of its hotel
+	if params.CancellationPolicy == nil {
+		params.CancellationPolicy = &types.CancellationPolicy{}
+	}
+
+	return h.updateRoom(c, types.UpdateRoomParams{
+		Type:               params.Type,
+		Size:               params.Size,
+		MaxOccupancy:       params.MaxOccupancy,
+		Beds:               params.Beds,
+		Amenities:          params.Amenities,
+		Seaside:            &params.Seaside,
+		Price:              params.Price,
+		HotelID:            params.HotelID,
+		CancellationPolicy: params.CancellationPolicy,
+	})
+}
+
+func (h *RoomHandler) HandlePatchRoom(c *fiber.Ctx) error {
+	var params types.UpdateRoomParams
+	if err := c.BodyParser(&params); err != nil {
+		return ErrBadRequest()
+	}
+
+	if errors := params.Validate(); len(errors) > 0 {
+		return c.Status(http.StatusBadRequest).JSON(errors)
+	}
+
+	return h.updateRoom(c, params)
+}
+
+func (h *RoomHandler) updateRoom(c *fiber.Ctx, params types.UpdateRoomParams) error {
+	id := c.Params("id")
+	room, err := h.getRoom(c)
+	if err != nil {
+		return err
+	}
+
+	currency := room.Price.Currency
+	if len(params.HotelID) > 0 && params.HotelID != room.HotelID.Hex() {
+		hotel, err := h.store.Hotel.GetHotelByID(c.Context(), params.HotelID)
+		if err != nil {
+			if errors.Is(err, db.ErrNotFound) {
+				return NewError(http.StatusNotFound, fmt.Sprintf("hotel %s not found", params.HotelID))
+			}
+			return err
+		}
+		if err := h.ensureNoUpcomingBookings(c, room); err != nil {
+			return err
+		}
+		currency = hotel.BaseCurrency()
+	}
+
+	if !params.Price.IsZero() {
+		if params.Price, err = priceInCurrency(params.Price, currency); err != nil {
+			return err
+		}
+	} else if currency != room.Price.Currency {
+		return NewError(http.StatusBadRequest, fmt.Sprintf("the room needs a price in %s, the currency of its new hotel", currency))
+	}
+
+	if err := h.store.Room.UpdateRoom(c.Context(), id, params); err != nil {
+		return err
+	}
+
+	return c.JSON(map[string]string{"updated": id})
+}
+
+func (h *RoomHandler) HandleDeleteRoom(c *fiber.Ctx) error {
+	id := c.Params("id")
+	room, err := h.getRoom(c)
+	if err != nil {
+		return err
+	}
+
+	if err := h.ensureNoUpcomingBookings(c, room); err != nil {
+		return err
+	}
+
+	if err := h.store.Room.DeleteRoom(c.Context(), id); err != nil {
+		return err
+	}
+
+	return c.JSON(map[string]string{"deleted": id})
 }
 
-func (h *RoomHandler) isRoomAvailable(ctx context.Context, roomID primitive.ObjectID, params BookRoomParams) (bool, error) {
-	where := bson.M{
-		"_id": roomID,
-		"fromDate": bson.M{
-			"$gte": params.FromDate,
-		},
-		"tillDate": bson.M{
-			"$lte": params.TillDate,
-		},
+func (h *RoomHandler) getRoom(c *fiber.Ctx) (*types.Room, error) {
+	id := c.Params("id")
+	if _, err := primitive.ObjectIDFromHex(id); err != nil {
+		return nil, ErrInvalidID()
 	}
-	bookings, err := h.store.Booking.GetBookings(ctx, where)
+
+	room, err := h.store.Room.GetRoomByID(c.Context(), id)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return nil, ErrResourceNotFound()
+		}
+		return nil, err
+	}
+	return room, nil
+}
+
+// priceInCurrency takes a price without a currency to be in the one of the hotel.
+func priceInCurrency(price types.Money, currency types.Currency) (types.Money, error) {
+	price, err := price.WithCurrency(currency)
 	if err != nil {
-		return false, err
+		return price, NewError(http.StatusBadRequest, err.Error())
+	}
+	if price.Currency != currency {
+		return price, NewError(http.StatusBadRequest, fmt.Sprintf("price should be in %s, the currency of the hotel", currency))
 	}
+	return price, nil
+}
 
-	ok := len(bookings) == 0
-	return ok, nil
+func (h *RoomHandler) ensureNoUpcomingBookings(c *fiber.Ctx, room *types.Room) error {
+	bookings, err := h.store.Booking.GetBookings(c.Context(), db.BookingFilter{
+		RoomID:   room.ID,
+		Overlaps: &db.DateRange{From: time.Now()},
+		Statuses: types.ActiveBookingStatuses(),
+	}, nil)
+	if err != nil {
+		return err
+	}
+	if len(bookings) > 0 {
+		return NewError(http.StatusConflict, fmt.Sprintf("room %s has %d upcoming bookings", room.ID.Hex(), len(bookings)))
+	}
+	return nil
 }
@@ -0,0 +1,694 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/raphaelmb/go-hotel-reservation/db/fixtures"
+	"github.com/raphaelmb/go-hotel-reservation/events"
+	"github.com/raphaelmb/go-hotel-reservation/policy"
+	"github.com/raphaelmb/go-hotel-reservation/types"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func bookRoomRequest(room *types.Room, user *types.User, from, till time.Time) *http.Request {
+	return bookRoomRequestFor(room, user, from, till, 2)
+}
+
+func bookRoomRequestFor(room *types.Room, user *types.User, from, till time.Time, persons int) *http.Request {
+	return reserveRoomRequest("book", room, user, from, till, persons)
+}
+
+func holdRoomRequest(room *types.Room, user *types.User, from, till time.Time) *http.Request {
+	return reserveRoomRequest("hold", room, user, from, till, 2)
+}
+
+func reserveRoomRequest(action string, room *types.Room, user *types.User, from, till time.Time, persons int) *http.Request {
+	b, _ := json.Marshal(BookRoomParams{
+		FromDate:   from,
+		TillDate:   till,
+		NumPersons: persons,
+	})
+	req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/%s/%s", room.ID.Hex(), action), bytes.NewReader(b))
+	req.Header.Add("Content-Type", "application/json")
+	req.Header.Add("X-Api-Token", createToken(user))
+	return req
+}
+
+func TestBookRoomOverlap(t *testing.T) {
+	db := setup(t)
+	defer db.tearDown(t)
+
+	var (
+		user  = fixtures.AddUser(db.Store, "james", "foo", false)
+		hotel = fixtures.AddHotel(db.Store, "hotel", "anywhere", 4, nil)
+		room  = fixtures.AddRoom(db.Store, "small", true, 5.5, hotel.ID)
+
+		from    = time.Now().AddDate(0, 0, 10)
+		till    = from.AddDate(0, 0, 5)
+		booking = fixtures.AddBooking(db.Store, user.ID, room.ID, from, till)
+
+		roomHandler = NewRoomHandler(db.Store)
+		app         = fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+		route       = app.Group("/", JWTAuthentication(db.User, db.Token, testTokens, nil))
+	)
+	route.Post("/:id/book", roomHandler.HandleBookRoom)
+
+	tests := []struct {
+		name string
+		from time.Time
+		till time.Time
+		code int
+	}{
+		{"starts inside existing booking", from.AddDate(0, 0, 2), till.AddDate(0, 0, 2), http.StatusConflict},
+		{"ends inside existing booking", from.AddDate(0, 0, -2), from.AddDate(0, 0, 2), http.StatusConflict},
+		{"contains existing booking", from.AddDate(0, 0, -1), till.AddDate(0, 0, 1), http.StatusConflict},
+		{"inside existing booking", from.AddDate(0, 0, 1), till.AddDate(0, 0, -1), http.StatusConflict},
+		{"checks in on check out day", till, till.AddDate(0, 0, 2), http.StatusOK},
+		{"checks out on check in day", from.AddDate(0, 0, -2), from, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := app.Test(bookRoomRequest(room, user, tt.from, tt.till))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.code {
+				t.Fatalf("expected %d response but got %d", tt.code, resp.StatusCode)
+			}
+		})
+	}
+
+	t.Run("cancelled booking frees the room", func(t *testing.T) {
+		if _, err := db.Booking.UpdateBookingStatus(context.Background(), booking.ID.Hex(), types.BookingCancelled); err != nil {
+			t.Fatal(err)
+		}
+		resp, err := app.Test(bookRoomRequest(room, user, from.AddDate(0, 0, 1), till.AddDate(0, 0, -1)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200 response but got %d", resp.StatusCode)
+		}
+	})
+}
+
+func TestBookRoomConcurrently(t *testing.T) {
+	db := setup(t)
+	defer db.tearDown(t)
+
+	var (
+		user  = fixtures.AddUser(db.Store, "james", "foo", false)
+		hotel = fixtures.AddHotel(db.Store, "hotel", "anywhere", 4, nil)
+		room  = fixtures.AddRoom(db.Store, "small", true, 5.5, hotel.ID)
+
+		from = time.Now().AddDate(0, 0, 1)
+		till = from.AddDate(0, 0, 3)
+
+		roomHandler = NewRoomHandler(db.Store)
+		app         = fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+		route       = app.Group("/", JWTAuthentication(db.User, db.Token, testTokens, nil))
+	)
+	route.Post("/:id/book", roomHandler.HandleBookRoom)
+
+	const attempts = 20
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		codes = map[int]int{}
+	)
+	for i := 0; i < attempts; i++ {
+		req := bookRoomRequest(room, user, from, till)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := app.Test(req, -1)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			mu.Lock()
+			codes[resp.StatusCode]++
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	if codes[http.StatusOK] != 1 {
+		t.Fatalf("expected exactly 1 successful booking but got %d", codes[http.StatusOK])
+	}
+	if codes[http.StatusConflict] != attempts-1 {
+		t.Fatalf("expected %d conflicting bookings but got %d", attempts-1, codes[http.StatusConflict])
+	}
+}
+
+func TestAdminManageRooms(t *testing.T) {
+	db := setup(t)
+	defer db.tearDown(t)
+
+	var (
+		admin       = fixtures.AddUser(db.Store, "admin", "admin", true)
+		hotel       = fixtures.AddHotel(db.Store, "hotel", "anywhere", 4, nil)
+		otherHotel  = fixtures.AddHotel(db.Store, "other hotel", "anywhere", 3, nil)
+		roomHandler = NewRoomHandler(db.Store)
+
+		app   = fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+		route = app.Group("/", JWTAuthentication(db.User, db.Token, testTokens, nil), Require(policy.ManageHotels))
+	)
+	route.Post("/", roomHandler.HandlePostRoom)
+	route.Patch("/:id", roomHandler.HandlePatchRoom)
+	route.Delete("/:id", roomHandler.HandleDeleteRoom)
+
+	send := func(t *testing.T, method, target string, body any) *http.Response {
+		b, _ := json.Marshal(body)
+		req := httptest.NewRequest(method, target, bytes.NewReader(b))
+		req.Header.Add("Content-Type", "application/json")
+		req.Header.Add("X-Api-Token", createToken(admin))
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+	hotelRooms := func(t *testing.T, hotel *types.Hotel) []primitive.ObjectID {
+		h, err := db.Hotel.GetHotelByID(context.Background(), hotel.ID.Hex())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return h.Rooms
+	}
+
+	var room types.Room
+	t.Run("should create a room in a hotel", func(t *testing.T) {
+		resp := send(t, http.MethodPost, "/", types.CreateRoomParams{
+			Type:      types.RoomTypeFamily,
+			Size:      "large",
+			Beds:      []types.Bed{{Type: types.BedTypeQueen, Count: 1}, {Type: types.BedTypeSingle, Count: 2}},
+			Amenities: []string{"wifi", "minibar"},
+			Price:     types.NewMoney(99.9, ""),
+			HotelID:   hotel.ID.Hex(),
+		})
+		if resp.StatusCode != http.StatusCreated {
+			t.Fatalf("expected 201 response but got %d", resp.StatusCode)
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&room); err != nil {
+			t.Fatal(err)
+		}
+		if rooms := hotelRooms(t, hotel); len(rooms) != 1 || rooms[0] != room.ID {
+			t.Fatalf("expected hotel rooms to be [%s] but got %v", room.ID, rooms)
+		}
+		stored, err := db.Room.GetRoomByID(context.Background(), room.ID.Hex())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stored.Capacity() != 4 || len(stored.Beds) != 2 || len(stored.Amenities) != 2 {
+			t.Fatalf("expected a family room for 4 with beds and amenities but got %+v", stored)
+		}
+	})
+
+	t.Run("should not create a room with an unknown type", func(t *testing.T) {
+		resp := send(t, http.MethodPost, "/", types.CreateRoomParams{Type: "castle", Size: "small", Price: types.NewMoney(99.9, ""), HotelID: hotel.ID.Hex()})
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("expected 400 response but got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("should not create a room in an unknown hotel", func(t *testing.T) {
+		resp := send(t, http.MethodPost, "/", types.CreateRoomParams{Type: types.RoomTypeSingle, Size: "small", Price: types.NewMoney(99.9, ""), HotelID: primitive.NewObjectID().Hex()})
+		if resp.StatusCode != http.StatusNotFound {
+			t.Fatalf("expected 404 response but got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("should not price a room in another currency than its hotel", func(t *testing.T) {
+		resp := send(t, http.MethodPost, "/", types.CreateRoomParams{Type: types.RoomTypeSingle, Size: "small", Price: types.NewMoney(99.9, "EUR"), HotelID: hotel.ID.Hex()})
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("expected 400 response but got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("should move a room to another hotel", func(t *testing.T) {
+		resp := send(t, http.MethodPatch, "/"+room.ID.Hex(), types.UpdateRoomParams{HotelID: otherHotel.ID.Hex()})
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200 response but got %d", resp.StatusCode)
+		}
+		if rooms := hotelRooms(t, hotel); len(rooms) != 0 {
+			t.Fatalf("expected hotel to have no rooms but got %v", rooms)
+		}
+		if rooms := hotelRooms(t, otherHotel); len(rooms) != 1 || rooms[0] != room.ID {
+			t.Fatalf("expected hotel rooms to be [%s] but got %v", room.ID, rooms)
+		}
+	})
+
+	t.Run("should not delete a room with upcoming bookings", func(t *testing.T) {
+		from := time.Now().AddDate(0, 0, 3)
+		booking := fixtures.AddBooking(db.Store, admin.ID, room.ID, from, from.AddDate(0, 0, 2))
+		resp := send(t, http.MethodDelete, "/"+room.ID.Hex(), nil)
+		if resp.StatusCode != http.StatusConflict {
+			t.Fatalf("expected 409 response but got %d", resp.StatusCode)
+		}
+		if _, err := db.Booking.UpdateBookingStatus(context.Background(), booking.ID.Hex(), types.BookingCancelled); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("should delete a room", func(t *testing.T) {
+		resp := send(t, http.MethodDelete, "/"+room.ID.Hex(), nil)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200 response but got %d", resp.StatusCode)
+		}
+		if rooms := hotelRooms(t, otherHotel); len(rooms) != 0 {
+			t.Fatalf("expected hotel to have no rooms but got %v", rooms)
+		}
+	})
+}
+
+func TestPricesWithoutCurrency(t *testing.T) {
+	db := setup(t)
+	defer db.tearDown(t)
+
+	var (
+		admin        = fixtures.AddUser(db.Store, "admin", "admin", true)
+		roomHandler  = NewRoomHandler(db.Store)
+		hotelHandler = NewHotelHandler(db.Store)
+
+		app   = fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+		route = app.Group("/", JWTAuthentication(db.User, db.Token, testTokens, nil), Require(policy.ManageHotels))
+	)
+	route.Post("/room", roomHandler.HandlePostRoom)
+	route.Post("/hotel", hotelHandler.HandlePostHotel)
+
+	send := func(t *testing.T, target, body string, status int, v any) {
+		req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+		req.Header.Add("Content-Type", "application/json")
+		req.Header.Add("X-Api-Token", createToken(admin))
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != status {
+			t.Fatalf("expected %d response but got %d", status, resp.StatusCode)
+		}
+		if v != nil {
+			if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	addHotel := func(t *testing.T, currency types.Currency) *types.Hotel {
+		hotel, err := db.Hotel.Insert(context.Background(), &types.Hotel{Name: "hotel", Location: "anywhere", Rating: 4, Currency: currency})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return hotel
+	}
+	var (
+		yen   = addHotel(t, "JPY")
+		dinar = addHotel(t, "KWD")
+	)
+
+	tests := []struct {
+		name   string
+		hotel  *types.Hotel
+		price  string
+		status int
+		want   types.Money
+	}{
+		{"a bare number in yen", yen, `1000`, http.StatusCreated, types.Money{Amount: 1000, Currency: "JPY"}},
+		{"an amount in yen", yen, `{"amount": "1000"}`, http.StatusCreated, types.Money{Amount: 1000, Currency: "JPY"}},
+		{"a fraction of a yen", yen, `{"amount": "1000.5"}`, http.StatusBadRequest, types.Money{}},
+		{"a bare number in dinars", dinar, `12.345`, http.StatusCreated, types.Money{Amount: 12345, Currency: "KWD"}},
+		{"an amount in dinars", dinar, `{"amount": "12.5"}`, http.StatusCreated, types.Money{Amount: 12500, Currency: "KWD"}},
+	}
+	for _, tt := range tests {
+		t.Run("should price a room with "+tt.name, func(t *testing.T) {
+			body := fmt.Sprintf(`{"type": %q, "size": "small", "hotelID": %q, "price": %s}`, types.RoomTypeSingle, tt.hotel.ID.Hex(), tt.price)
+			var room types.Room
+			send(t, "/room", body, tt.status, &room)
+			if tt.status == http.StatusCreated && room.Price != tt.want {
+				t.Fatalf("expected price %s but got %s", tt.want, room.Price)
+			}
+		})
+	}
+
+	t.Run("should charge fixed amounts in the currency of the hotel", func(t *testing.T) {
+		var hotel types.Hotel
+		send(t, "/hotel", `{"name": "dinar hotel", "location": "Kuwait", "rating": 4, "currency": "KWD", "charges": [{"name": "city tax", "kind": "per_night", "amount": 1.5}]}`, http.StatusCreated, &hotel)
+		want := types.Money{Amount: 1500, Currency: "KWD"}
+		if len(hotel.Charges) != 1 || hotel.Charges[0].Amount != want {
+			t.Fatalf("expected a charge of %s but got %+v", want, hotel.Charges)
+		}
+	})
+}
+
+func TestBookRoomCapacity(t *testing.T) {
+	db := setup(t)
+	defer db.tearDown(t)
+
+	var (
+		user  = fixtures.AddUser(db.Store, "james", "foo", false)
+		hotel = fixtures.AddHotel(db.Store, "hotel", "anywhere", 4, nil)
+		from  = time.Now().AddDate(0, 0, 1)
+		till  = from.AddDate(0, 0, 2)
+
+		roomHandler = NewRoomHandler(db.Store)
+		app         = fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+		route       = app.Group("/", JWTAuthentication(db.User, db.Token, testTokens, nil))
+	)
+	route.Post("/:id/book", roomHandler.HandleBookRoom)
+
+	room, err := db.Room.InsertRoom(context.Background(), &types.Room{
+		Type:    types.RoomTypeSingle,
+		Size:    "small",
+		Price:   types.NewMoney(50, types.DefaultCurrency),
+		HotelID: hotel.ID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		persons int
+		code    int
+	}{
+		{"no persons", 0, http.StatusBadRequest},
+		{"negative persons", -1, http.StatusBadRequest},
+		{"over capacity", 2, http.StatusUnprocessableEntity},
+		{"within capacity", 1, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := app.Test(bookRoomRequestFor(room, user, from, till, tt.persons))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.code {
+				t.Fatalf("expected %d response but got %d", tt.code, resp.StatusCode)
+			}
+		})
+	}
+}
+
+func TestHoldRoom(t *testing.T) {
+	db := setup(t)
+	defer db.tearDown(t)
+
+	var (
+		user      = fixtures.AddUser(db.Store, "james", "foo", false)
+		otherUser = fixtures.AddUser(db.Store, "another", "user", false)
+		hotel     = fixtures.AddHotel(db.Store, "hotel", "anywhere", 4, nil)
+		room      = fixtures.AddRoom(db.Store, "small", true, 5.5, hotel.ID)
+		from      = time.Now().AddDate(0, 0, 1)
+		till      = from.AddDate(0, 0, 2)
+
+		roomHandler    = NewRoomHandler(db.Store)
+		bookingHandler = NewBookingHandler(db.Store, &events.Recorder{})
+		app            = fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+		route          = app.Group("/", JWTAuthentication(db.User, db.Token, testTokens, nil))
+	)
+	route.Post("/:id/book", roomHandler.HandleBookRoom)
+	route.Post("/:id/hold", roomHandler.HandleHoldRoom)
+	route.Post("/:id/confirm", bookingHandler.HandleConfirmBooking)
+
+	confirm := func(t *testing.T, booking *types.Booking, user *types.User) *http.Response {
+		req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/%s/confirm", booking.ID.Hex()), nil)
+		req.Header.Add("X-Api-Token", createToken(user))
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	var hold *types.Booking
+	t.Run("should hold the room", func(t *testing.T) {
+		resp, err := app.Test(holdRoomRequest(room, user, from, till))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200 response but got %d", resp.StatusCode)
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&hold); err != nil {
+			t.Fatal(err)
+		}
+		if hold.Status != types.BookingPending {
+			t.Fatalf("expected status %s but got %s", types.BookingPending, hold.Status)
+		}
+		if hold.ExpiresAt.Before(time.Now()) || hold.ExpiresAt.After(time.Now().Add(holdDuration)) {
+			t.Fatalf("expected hold to expire within %s but got %s", holdDuration, hold.ExpiresAt)
+		}
+	})
+
+	t.Run("live hold should occupy the room", func(t *testing.T) {
+		resp, err := app.Test(bookRoomRequest(room, otherUser, from, till))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != http.StatusConflict {
+			t.Fatalf("expected 409 response but got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("should not confirm the hold of another user", func(t *testing.T) {
+		if resp := confirm(t, hold, otherUser); resp.StatusCode != http.StatusForbidden {
+			t.Fatalf("expected 403 response but got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("should confirm the hold", func(t *testing.T) {
+		resp := confirm(t, hold, user)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200 response but got %d", resp.StatusCode)
+		}
+		var confirmed *types.Booking
+		if err := json.NewDecoder(resp.Body).Decode(&confirmed); err != nil {
+			t.Fatal(err)
+		}
+		if confirmed.Status != types.BookingConfirmed {
+			t.Fatalf("expected status %s but got %s", types.BookingConfirmed, confirmed.Status)
+		}
+	})
+
+	t.Run("expired hold should release the room", func(t *testing.T) {
+		from, till := from.AddDate(0, 0, 5), till.AddDate(0, 0, 5)
+		expired, err := db.Booking.InsertHold(context.Background(), &types.Booking{
+			UserID:   user.ID,
+			RoomID:   room.ID,
+			HotelID:  hotel.ID,
+			FromDate: from,
+			TillDate: till,
+		}, time.Now().Add(-time.Minute))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		resp, err := app.Test(bookRoomRequest(room, otherUser, from, till))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200 response but got %d", resp.StatusCode)
+		}
+		if resp := confirm(t, expired, user); resp.StatusCode != http.StatusConflict {
+			t.Fatalf("expected 409 response but got %d", resp.StatusCode)
+		}
+		expired, err = db.Booking.GetBookingByID(context.Background(), expired.ID.Hex())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expired.Status != types.BookingExpired {
+			t.Fatalf("expected status %s but got %s", types.BookingExpired, expired.Status)
+		}
+	})
+}
+
+func TestQuoteRoom(t *testing.T) {
+	db := setup(t)
+	defer db.tearDown(t)
+
+	var (
+		user  = fixtures.AddUser(db.Store, "james", "foo", false)
+		hotel = fixtures.AddHotel(db.Store, "hotel", "anywhere", 4, nil)
+		room  = fixtures.AddRoom(db.Store, "small", true, 100, hotel.ID)
+
+		roomHandler = NewRoomHandler(db.Store)
+		app         = fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+		route       = app.Group("/", JWTAuthentication(db.User, db.Token, testTokens, nil))
+	)
+	route.Post("/:id/quote", roomHandler.HandleQuoteRoom)
+	route.Post("/:id/book", roomHandler.HandleBookRoom)
+
+	// a monday at noon a few weeks ahead
+	monday := time.Now().UTC().AddDate(0, 0, 21)
+	monday = time.Date(monday.Year(), monday.Month(), monday.Day(), 12, 0, 0, 0, time.UTC)
+	for monday.Weekday() != time.Monday {
+		monday = monday.AddDate(0, 0, 1)
+	}
+	peak := monday.AddDate(0, 0, 14)
+
+	rules := &types.PricingRules{
+		Seasons: []types.Season{
+			{Name: "peak", From: peak, Till: peak.AddDate(0, 0, 2), Multiplier: 2},
+		},
+		WeekendMultiplier: 1.5,
+		StayDiscounts: []types.StayDiscount{
+			{MinNights: 3, Percent: 5},
+			{MinNights: 7, Percent: 10},
+		},
+	}
+	if err := db.Hotel.Update(context.Background(), hotel.ID.Hex(), types.UpdateHotelParams{Pricing: rules}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		from     time.Time
+		nights   int
+		subtotal int64
+		total    int64
+	}{
+		{"weekdays", monday, 2, 20000, 20000},
+		{"weekend", monday.AddDate(0, 0, 3), 3, 40000, 38000},
+		{"whole week", monday, 7, 80000, 72000},
+		{"peak season", peak, 3, 50000, 47500},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := app.Test(reserveRoomRequest("quote", room, user, tt.from, tt.from.AddDate(0, 0, tt.nights), 1))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("expected 200 response but got %d", resp.StatusCode)
+			}
+			var quote types.PriceQuote
+			if err := json.NewDecoder(resp.Body).Decode(&quote); err != nil {
+				t.Fatal(err)
+			}
+			if len(quote.Nights) != tt.nights {
+				t.Fatalf("expected %d nights but got %d", tt.nights, len(quote.Nights))
+			}
+			if quote.Subtotal.Amount != tt.subtotal || quote.Total.Amount != tt.total {
+				t.Fatalf("expected subtotal %d and total %d but got %s and %s", tt.subtotal, tt.total, quote.Subtotal, quote.Total)
+			}
+		})
+	}
+
+	t.Run("booking should snapshot the price", func(t *testing.T) {
+		resp, err := app.Test(bookRoomRequest(room, user, monday, monday.AddDate(0, 0, 7)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200 response but got %d", resp.StatusCode)
+		}
+		var booking *types.Booking
+		if err := json.NewDecoder(resp.Body).Decode(&booking); err != nil {
+			t.Fatal(err)
+		}
+		stored, err := db.Booking.GetBookingByID(context.Background(), booking.ID.Hex())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stored.Price == nil || stored.Price.Total != types.NewMoney(720, types.DefaultCurrency) || len(stored.Price.Nights) != 7 {
+			t.Fatalf("expected booking to be priced at 720 for 7 nights but got %+v", stored.Price)
+		}
+	})
+}
+
+func TestQuoteRoomCharges(t *testing.T) {
+	db := setup(t)
+	defer db.tearDown(t)
+
+	var (
+		user  = fixtures.AddUser(db.Store, "james", "foo", false)
+		hotel = fixtures.AddHotel(db.Store, "hotel", "anywhere", 4, nil)
+		room  = fixtures.AddRoom(db.Store, "small", true, 100, hotel.ID)
+
+		roomHandler = NewRoomHandler(db.Store)
+		app         = fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+		route       = app.Group("/", JWTAuthentication(db.User, db.Token, testTokens, nil))
+	)
+	route.Post("/:id/quote", roomHandler.HandleQuoteRoom)
+	route.Post("/:id/book", roomHandler.HandleBookRoom)
+
+	charges := []types.ChargeRule{
+		{Name: "VAT", Kind: types.ChargePercent, Percent: 10},
+		{Name: "city tax", Kind: types.ChargePerPersonNight, Amount: types.NewMoney(2.5, types.DefaultCurrency)},
+		{Name: "cleaning", Kind: types.ChargePerStay, Amount: types.NewMoney(30, types.DefaultCurrency)},
+	}
+	if err := db.Hotel.Update(context.Background(), hotel.ID.Hex(), types.UpdateHotelParams{Charges: charges}); err != nil {
+		t.Fatal(err)
+	}
+
+	// a tuesday, to stay clear of weekend pricing
+	from := time.Now().UTC().AddDate(0, 0, 7)
+	for from.Weekday() != time.Tuesday {
+		from = from.AddDate(0, 0, 1)
+	}
+	till := from.AddDate(0, 0, 2)
+	want := []types.Charge{
+		{Name: "VAT", Kind: types.ChargePercent, Amount: types.NewMoney(20, types.DefaultCurrency)},
+		{Name: "city tax", Kind: types.ChargePerPersonNight, Amount: types.NewMoney(10, types.DefaultCurrency)},
+		{Name: "cleaning", Kind: types.ChargePerStay, Amount: types.NewMoney(30, types.DefaultCurrency)},
+	}
+
+	checkQuote := func(t *testing.T, quote *types.PriceQuote) {
+		if quote == nil || len(quote.Charges) != len(want) {
+			t.Fatalf("expected %d charges but got %+v", len(want), quote)
+		}
+		for i, charge := range want {
+			if quote.Charges[i] != charge {
+				t.Fatalf("expected charge %+v but got %+v", charge, quote.Charges[i])
+			}
+		}
+		if total := types.NewMoney(260, types.DefaultCurrency); quote.Total != total {
+			t.Fatalf("expected total %s but got %s", total, quote.Total)
+		}
+	}
+
+	t.Run("quote should include taxes and fees", func(t *testing.T) {
+		resp, err := app.Test(reserveRoomRequest("quote", room, user, from, till, 2))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200 response but got %d", resp.StatusCode)
+		}
+		var quote types.PriceQuote
+		if err := json.NewDecoder(resp.Body).Decode(&quote); err != nil {
+			t.Fatal(err)
+		}
+		checkQuote(t, &quote)
+	})
+
+	t.Run("booking should snapshot the taxes and fees", func(t *testing.T) {
+		resp, err := app.Test(bookRoomRequest(room, user, from, till))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200 response but got %d", resp.StatusCode)
+		}
+		var booking *types.Booking
+		if err := json.NewDecoder(resp.Body).Decode(&booking); err != nil {
+			t.Fatal(err)
+		}
+		stored, err := db.Booking.GetBookingByID(context.Background(), booking.ID.Hex())
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkQuote(t, stored.Price)
+	})
+}
@@ -0,0 +1,124 @@
+package api
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	// SessionCookiesInsecureEnvName lets the cookies go over plain http, for local development.
+	SessionCookiesEnvName         = "SESSION_COOKIES"
+	SessionCookiesDomainEnvName   = "SESSION_COOKIES_DOMAIN"
+	SessionCookiesInsecureEnvName = "SESSION_COOKIES_INSECURE"
+
+	accessTokenCookie  = "access_token"
+	refreshTokenCookie = "refresh_token"
+	csrfTokenCookie    = "csrf_token"
+	csrfTokenHeader    = "X-CSRF-Token"
+
+	sessionQuery  = "session"
+	cookieSession = "cookie"
+
+	csrfTokenBytes = 32
+)
+
+// Requests authenticated by cookie must echo the csrf_token cookie in the X-CSRF-Token
+// header unless they are safe. A nil SessionCookies disables cookie sessions.
+type SessionCookies struct {
+	Domain   string
+	Insecure bool
+}
+
+func NewSessionCookiesFromEnv() *SessionCookies {
+	if os.Getenv(SessionCookiesEnvName) != "true" {
+		return nil
+	}
+	return &SessionCookies{
+		Domain:   os.Getenv(SessionCookiesDomainEnvName),
+		Insecure: os.Getenv(SessionCookiesInsecureEnvName) == "true",
+	}
+}
+
+func (s *SessionCookies) requested(c *fiber.Ctx) (bool, error) {
+	if c.Query(sessionQuery) != cookieSession {
+		return false, nil
+	}
+	if s == nil {
+		return false, NewError(http.StatusBadRequest, "cookie sessions are disabled")
+	}
+	return true, nil
+}
+
+// set replaces the tokens of the response by the csrf token the client must send back.
+func (s *SessionCookies) set(c *fiber.Ctx, resp *AuthResponse) error {
+	b := make([]byte, csrfTokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return err
+	}
+	csrfToken := base64.RawURLEncoding.EncodeToString(b)
+
+	s.setCookie(c, accessTokenCookie, resp.Token, "/api", accessTokenTTL, true)
+	s.setCookie(c, refreshTokenCookie, resp.RefreshToken, "/api/auth", refreshTokenTTL, true)
+	// the csrf token is read by the scripts of the front-end
+	s.setCookie(c, csrfTokenCookie, csrfToken, "/", refreshTokenTTL, false)
+
+	resp.Token = ""
+	resp.RefreshToken = ""
+	resp.CSRFToken = csrfToken
+	return nil
+}
+
+func (s *SessionCookies) clear(c *fiber.Ctx) {
+	s.setCookie(c, accessTokenCookie, "", "/api", -time.Second, true)
+	s.setCookie(c, refreshTokenCookie, "", "/api/auth", -time.Second, true)
+	s.setCookie(c, csrfTokenCookie, "", "/", -time.Second, false)
+}
+
+func (s *SessionCookies) setCookie(c *fiber.Ctx, name, value, path string, ttl time.Duration, httpOnly bool) {
+	cookie := &fiber.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     path,
+		Domain:   s.Domain,
+		MaxAge:   int(ttl.Seconds()),
+		Secure:   !s.Insecure,
+		HTTPOnly: httpOnly,
+		SameSite: fiber.CookieSameSiteStrictMode,
+	}
+	if ttl < 0 {
+		cookie.Expires = time.Unix(0, 0)
+	}
+	c.Cookie(cookie)
+}
+
+func (s *SessionCookies) refreshToken(c *fiber.Ctx) string {
+	if s == nil {
+		return ""
+	}
+	return c.Cookies(refreshTokenCookie)
+}
+
+func (s *SessionCookies) accessToken(c *fiber.Ctx) string {
+	if s == nil {
+		return ""
+	}
+	return c.Cookies(accessTokenCookie)
+}
+
+func (s *SessionCookies) checkCSRF(c *fiber.Ctx) error {
+	switch c.Method() {
+	case fiber.MethodGet, fiber.MethodHead, fiber.MethodOptions:
+		return nil
+	}
+	cookie, header := c.Cookies(csrfTokenCookie), c.Get(csrfTokenHeader)
+	if len(cookie) == 0 || subtle.ConstantTimeCompare([]byte(cookie), []byte(header)) != 1 {
+		return NewError(http.StatusForbidden, "invalid csrf token")
+	}
+	return nil
+}
@@ -8,16 +8,44 @@ import (
 
 	"github.com/joho/godotenv"
 	"github.com/raphaelmb/go-hotel-reservation/db"
+	"github.com/raphaelmb/go-hotel-reservation/db/memory"
+	"github.com/raphaelmb/go-hotel-reservation/db/sqlstore"
+	"github.com/raphaelmb/go-hotel-reservation/signing"
+	"github.com/raphaelmb/go-hotel-reservation/types"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+var testTokens = newTestTokenIssuer()
+
+func newTestTokenIssuer() *TokenIssuer {
+	keys, err := signing.NewHMACKeySet([]byte("test secret"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	return NewTokenIssuer(keys, defaultJWTIssuer, defaultJWTAudience)
+}
+
+// testSessions is the token store of the running test, where createToken starts sessions.
+var testSessions db.TokenStore
+
+func createToken(user *types.User) string {
+	resp, err := StartSession(context.TODO(), testSessions, testTokens, user)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return resp.Token
+}
+
 type testDB struct {
 	client *mongo.Client
 	*db.Store
 }
 
 func (tdb *testDB) tearDown(t *testing.T) {
+	if tdb.client == nil {
+		return
+	}
 	dbName := os.Getenv(db.MongoDBNameEnvName)
 	if err := tdb.client.Database(dbName).Drop(context.TODO()); err != nil {
 		t.Fatal(err)
@@ -25,9 +53,25 @@ func (tdb *testDB) tearDown(t *testing.T) {
 }
 
 func setup(t *testing.T) *testDB {
-	if err := godotenv.Load("../.env"); err != nil {
-		t.Fatal(err)
+	// the .env file is only required for the mongo backend
+	_ = godotenv.Load("../.env")
+	switch os.Getenv(db.BackendEnvName + "_TEST") {
+	case db.BackendMongo:
+	case db.BackendSQLite:
+		conn, err := sqlstore.Open(context.TODO(), ":memory:")
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { conn.Close() })
+		store := sqlstore.NewStore(conn)
+		testSessions = store.Token
+		return &testDB{Store: store}
+	default:
+		store := memory.NewStore()
+		testSessions = store.Token
+		return &testDB{Store: store}
 	}
+
 	dbURI := os.Getenv("MONGO_DB_URL_TEST")
 	client, err := mongo.Connect(context.TODO(), options.Client().ApplyURI(dbURI))
 	if err != nil {
@@ -35,14 +79,28 @@ func setup(t *testing.T) *testDB {
 	}
 
 	hotelStore := db.NewMongoHotelStore(client)
+	bookingStore := db.NewMongoBookingStore(client)
+	if err := bookingStore.MigrateStatus(context.TODO()); err != nil {
+		t.Fatal(err)
+	}
+	reservationStore := db.NewMongoReservationStore(client)
+	if err := reservationStore.MigrateConfirmationCodes(context.TODO()); err != nil {
+		t.Fatal(err)
+	}
 
+	tokenStore := db.NewMongoTokenStore(client)
+	testSessions = tokenStore
 	return &testDB{
 		client: client,
 		Store: &db.Store{
-			User:    db.NewMongoUserStore(client),
-			Hotel:   hotelStore,
-			Room:    db.NewMongoRoomStore(client, hotelStore),
-			Booking: db.NewMongoBookingStore(client),
+			User:        db.NewMongoUserStore(client),
+			Hotel:       hotelStore,
+			Room:        db.NewMongoRoomStore(client, hotelStore),
+			Booking:     bookingStore,
+			Promo:       db.NewMongoPromoCodeStore(client),
+			Reservation: reservationStore,
+			Waitlist:    db.NewMongoWaitlistStore(client),
+			Token:       tokenStore,
 		},
 	}
 }
@@ -2,28 +2,44 @@ package api
 
 import (
 	"errors"
+	"fmt"
+	"net/http"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/raphaelmb/go-hotel-reservation/db"
 	"github.com/raphaelmb/go-hotel-reservation/types"
-	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 type UserHandler struct {
-	userStore db.UserStore
+	userStore  db.UserStore
+	tokenStore db.TokenStore
+	tokens     *TokenIssuer
+	cookies    *SessionCookies
 }
 
-func NewUserHandler(userStore db.UserStore) *UserHandler {
+func NewUserHandler(userStore db.UserStore, tokenStore db.TokenStore, tokens *TokenIssuer, cookies *SessionCookies) *UserHandler {
 	return &UserHandler{
-		userStore: userStore,
+		userStore:  userStore,
+		tokenStore: tokenStore,
+		tokens:     tokens,
+		cookies:    cookies,
 	}
 }
 
 func (h *UserHandler) HandleDeleteUser(c *fiber.Ctx) error {
 	userID := c.Params("id")
+	oid, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return ErrInvalidID()
+	}
 	if err := h.userStore.DeleteUser(c.Context(), userID); err != nil {
 		return err
 	}
+	if err := h.tokenStore.RevokeUserTokens(c.Context(), oid, time.Now()); err != nil {
+		return err
+	}
 	return c.JSON(map[string]string{"deleted": userID})
 }
 
@@ -35,10 +51,11 @@ func (h *UserHandler) HandleUpdateUser(c *fiber.Ctx) error {
 	if err := c.BodyParser(&params); err != nil {
 		return ErrBadRequest()
 	}
+	if errors := params.Validate(); len(errors) > 0 {
+		return c.Status(http.StatusBadRequest).JSON(errors)
+	}
 
-	filter := db.Map{"_id": id}
-
-	if err := h.userStore.UpdateUser(c.Context(), filter, params); err != nil {
+	if err := h.userStore.UpdateUser(c.Context(), id, params); err != nil {
 		return err
 	}
 
@@ -50,29 +67,62 @@ func (h *UserHandler) HandlePostUser(c *fiber.Ctx) error {
 	if err := c.BodyParser(&params); err != nil {
 		return ErrBadRequest()
 	}
-
 	if errors := params.Validate(); len(errors) > 0 {
-		return c.JSON(errors)
+		return c.Status(http.StatusBadRequest).JSON(errors)
 	}
 
-	user, err := types.NewUserFromParams(params)
+	user, err := h.insertUser(c, params)
 	if err != nil {
 		return err
 	}
+	return c.Status(fiber.StatusCreated).JSON(user)
+}
 
-	insertedUser, err := h.userStore.InsertUser(c.Context(), user)
+func (h *UserHandler) HandleRegister(c *fiber.Ctx) error {
+	var params types.CreateUserParams
+	if err := c.BodyParser(&params); err != nil {
+		return ErrBadRequest()
+	}
+	if errors := params.Validate(); len(errors) > 0 {
+		return c.Status(http.StatusBadRequest).JSON(errors)
+	}
+	inCookies, err := h.cookies.requested(c)
 	if err != nil {
 		return err
 	}
 
-	return c.Status(fiber.StatusCreated).JSON(insertedUser)
+	user, err := h.insertUser(c, params)
+	if err != nil {
+		return err
+	}
+	resp, err := StartSession(c.Context(), h.tokenStore, h.tokens, user)
+	if err != nil {
+		return err
+	}
+	c.Status(fiber.StatusCreated)
+	return sendSession(c, h.cookies, resp, inCookies)
+}
+
+func (h *UserHandler) insertUser(c *fiber.Ctx, params types.CreateUserParams) (*types.User, error) {
+	user, err := types.NewUserFromParams(params)
+	if err != nil {
+		return nil, err
+	}
+	insertedUser, err := h.userStore.InsertUser(c.Context(), user)
+	if err != nil {
+		if errors.Is(err, db.ErrEmailExists) {
+			return nil, NewError(http.StatusConflict, fmt.Sprintf("email %s is already registered", params.Email))
+		}
+		return nil, err
+	}
+	return insertedUser, nil
 }
 
 func (h *UserHandler) HandleGetUser(c *fiber.Ctx) error {
 	id := c.Params("id")
 	user, err := h.userStore.GetUserByID(c.Context(), id)
 	if err != nil {
-		if errors.Is(err, mongo.ErrNoDocuments) {
+		if errors.Is(err, db.ErrNotFound) {
 			return c.Status(404).JSON(map[string]string{"error": "not found"})
 		}
 		return err
@@ -89,3 +139,97 @@ func (h *UserHandler) HandleGetUsers(c *fiber.Ctx) error {
 
 	return c.JSON(users)
 }
+
+func (h *UserHandler) HandleGetMe(c *fiber.Ctx) error {
+	user, err := getAuthUser(c)
+	if err != nil {
+		return ErrUnauthorized()
+	}
+	return c.JSON(user)
+}
+
+func (h *UserHandler) HandleUpdateMe(c *fiber.Ctx) error {
+	user, err := getAuthUser(c)
+	if err != nil {
+		return ErrUnauthorized()
+	}
+	var params types.UpdateUserParams
+	if err := c.BodyParser(&params); err != nil {
+		return ErrBadRequest()
+	}
+	if errors := params.Validate(); len(errors) > 0 {
+		return c.Status(http.StatusBadRequest).JSON(errors)
+	}
+
+	if err := h.userStore.UpdateUser(c.Context(), user.ID.Hex(), params); err != nil {
+		return err
+	}
+	updated, err := h.userStore.GetUserByID(c.Context(), user.ID.Hex())
+	if err != nil {
+		return err
+	}
+	return c.JSON(updated)
+}
+
+// HandleChangePassword revokes every session of the user and starts a new one.
+func (h *UserHandler) HandleChangePassword(c *fiber.Ctx) error {
+	user, err := getAuthUser(c)
+	if err != nil {
+		return ErrUnauthorized()
+	}
+	var params types.ChangePasswordParams
+	if err := c.BodyParser(&params); err != nil {
+		return ErrBadRequest()
+	}
+	if errors := params.Validate(); len(errors) > 0 {
+		return c.Status(http.StatusBadRequest).JSON(errors)
+	}
+	if !types.IsPasswordValid(user.EncryptedPassword, params.CurrentPassword) {
+		return NewError(http.StatusBadRequest, "current password is wrong")
+	}
+
+	encpw, err := types.EncryptPassword(params.NewPassword)
+	if err != nil {
+		return err
+	}
+	if err := h.userStore.UpdateUserPassword(c.Context(), user.ID.Hex(), encpw); err != nil {
+		return err
+	}
+	if err := h.tokenStore.RevokeUserTokens(c.Context(), user.ID, time.Now()); err != nil {
+		return err
+	}
+	resp, err := StartSession(c.Context(), h.tokenStore, h.tokens, user)
+	if err != nil {
+		return err
+	}
+	return sendSession(c, h.cookies, resp, inCookieSession(c))
+}
+
+func (h *UserHandler) HandleUpdateUserRole(c *fiber.Ctx) error {
+	var params types.UpdateRoleParams
+	if err := c.BodyParser(&params); err != nil {
+		return ErrBadRequest()
+	}
+	if errors := params.Validate(); len(errors) > 0 {
+		return c.Status(http.StatusBadRequest).JSON(errors)
+	}
+	if _, err := primitive.ObjectIDFromHex(c.Params("id")); err != nil {
+		return ErrInvalidID()
+	}
+
+	hotelIDs := make([]primitive.ObjectID, len(params.HotelIDs))
+	for i, id := range params.HotelIDs {
+		hotelIDs[i], _ = primitive.ObjectIDFromHex(id)
+	}
+	if err := h.userStore.UpdateUserRole(c.Context(), c.Params("id"), params.Role, hotelIDs); err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return ErrResourceNotFound()
+		}
+		return err
+	}
+	user, err := h.userStore.GetUserByID(c.Context(), c.Params("id"))
+	if err != nil {
+		return err
+	}
+	return c.JSON(user)
+}
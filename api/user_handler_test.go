@@ -8,6 +8,8 @@ import (
 	"testing"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/raphaelmb/go-hotel-reservation/db/fixtures"
+	"github.com/raphaelmb/go-hotel-reservation/policy"
 	"github.com/raphaelmb/go-hotel-reservation/types"
 )
 
@@ -16,7 +18,7 @@ func TestCreateUser(t *testing.T) {
 	defer tdb.tearDown(t)
 
 	app := fiber.New()
-	userHandler := NewUserHandler(tdb.User)
+	userHandler := NewUserHandler(tdb.User, tdb.Token, testTokens, nil)
 	app.Post("/", userHandler.HandlePostUser)
 
 	params := types.CreateUserParams{
@@ -52,3 +54,111 @@ func TestCreateUser(t *testing.T) {
 		t.Errorf("expected email %s but got %s", params.Email, user.Email)
 	}
 }
+
+func TestRegister(t *testing.T) {
+	tdb := setup(t)
+	defer tdb.tearDown(t)
+
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	userHandler := NewUserHandler(tdb.User, tdb.Token, testTokens, nil)
+	app.Post("/register", userHandler.HandleRegister)
+
+	register := func(params types.CreateUserParams) *http.Response {
+		b, _ := json.Marshal(params)
+		req := httptest.NewRequest(http.MethodPost, "/register", bytes.NewReader(b))
+		req.Header.Add("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+	params := types.CreateUserParams{
+		Email:     "jane@doe.com",
+		FirstName: "Jane",
+		LastName:  "Doe",
+		Password:  "12345678",
+	}
+
+	resp := register(params)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected 201 response but got %d", resp.StatusCode)
+	}
+	var auth AuthResponse
+	if err := json.NewDecoder(resp.Body).Decode(&auth); err != nil {
+		t.Fatal(err)
+	}
+	if auth.User.Email != params.Email || auth.User.IsAdmin || len(auth.Token) == 0 {
+		t.Fatalf("expected a logged in guest but got %+v", auth)
+	}
+
+	if resp := register(params); resp.StatusCode != http.StatusConflict {
+		t.Fatalf("expected 409 response for a taken email but got %d", resp.StatusCode)
+	}
+	params.Email = "not an email"
+	if resp := register(params); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 response for an invalid email but got %d", resp.StatusCode)
+	}
+}
+
+func TestMe(t *testing.T) {
+	tdb := setup(t)
+	defer tdb.tearDown(t)
+
+	var (
+		user      = fixtures.AddUser(tdb.Store, "james", "foo", false)
+		otherUser = fixtures.AddUser(tdb.Store, "another", "user", false)
+
+		userHandler = NewUserHandler(tdb.User, tdb.Token, testTokens, nil)
+		app         = fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+		route       = app.Group("/", JWTAuthentication(tdb.User, tdb.Token, testTokens, nil))
+		admin       = route.Group("/admin", Require(policy.ManageUsers))
+	)
+	route.Get("/me", userHandler.HandleGetMe)
+	route.Put("/me", userHandler.HandleUpdateMe)
+	admin.Get("/user", userHandler.HandleGetUsers)
+	admin.Delete("/user/:id", userHandler.HandleDeleteUser)
+
+	send := func(t *testing.T, method, path string, body any, status int) *types.User {
+		req := httptest.NewRequest(method, path, nil)
+		if body != nil {
+			b, _ := json.Marshal(body)
+			req = httptest.NewRequest(method, path, bytes.NewReader(b))
+			req.Header.Add("Content-Type", "application/json")
+		}
+		req.Header.Add("X-Api-Token", createToken(user))
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != status {
+			t.Fatalf("expected %d response but got %d", status, resp.StatusCode)
+		}
+		var got *types.User
+		if status == http.StatusOK {
+			if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return got
+	}
+
+	t.Run("should get the profile of the user", func(t *testing.T) {
+		if me := send(t, http.MethodGet, "/me", nil, http.StatusOK); me.ID != user.ID {
+			t.Fatalf("expected user %s but got %s", user.ID.Hex(), me.ID.Hex())
+		}
+	})
+
+	t.Run("should update the names of the user", func(t *testing.T) {
+		me := send(t, http.MethodPut, "/me", types.UpdateUserParams{FirstName: "Jim"}, http.StatusOK)
+		if me.FirstName != "Jim" || me.LastName != user.LastName {
+			t.Fatalf("expected only the first name to change but got %+v", me)
+		}
+		send(t, http.MethodPut, "/me", types.UpdateUserParams{LastName: "x"}, http.StatusBadRequest)
+	})
+
+	t.Run("should not manage other users", func(t *testing.T) {
+		send(t, http.MethodGet, "/admin/user", nil, http.StatusForbidden)
+		send(t, http.MethodDelete, "/admin/user/"+otherUser.ID.Hex(), nil, http.StatusForbidden)
+	})
+}
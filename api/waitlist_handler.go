@@ -0,0 +1,291 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/raphaelmb/go-hotel-reservation/db"
+	"github.com/raphaelmb/go-hotel-reservation/events"
+	"github.com/raphaelmb/go-hotel-reservation/types"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const waitlistHoldDuration = 24 * time.Hour
+
+type WaitlistHandler struct {
+	store *db.Store
+}
+
+func NewWaitlistHandler(store *db.Store) *WaitlistHandler {
+	return &WaitlistHandler{
+		store: store,
+	}
+}
+
+func (h *WaitlistHandler) HandlePostWaitlistEntry(c *fiber.Ctx) error {
+	var params types.CreateWaitlistEntryParams
+	if err := c.BodyParser(&params); err != nil {
+		return ErrBadRequest()
+	}
+	if errors := params.Validate(); len(errors) > 0 {
+		return c.Status(http.StatusBadRequest).JSON(errors)
+	}
+	stay := BookRoomParams{FromDate: params.FromDate, TillDate: params.TillDate, NumPersons: params.NumPersons}
+	if err := stay.validate(); err != nil {
+		return err
+	}
+	user, err := getAuthUser(c)
+	if err != nil {
+		return ErrUnauthorized()
+	}
+
+	entry := &types.WaitlistEntry{
+		UserID:     user.ID,
+		RoomType:   params.RoomType,
+		FromDate:   params.FromDate,
+		TillDate:   params.TillDate,
+		NumPersons: params.NumPersons,
+		Status:     types.WaitlistWaiting,
+	}
+	var wanted []*types.Room
+	if len(params.RoomID) > 0 {
+		room, err := h.store.Room.GetRoomByID(c.Context(), params.RoomID)
+		if err != nil {
+			if errors.Is(err, db.ErrNotFound) {
+				return NewError(http.StatusNotFound, fmt.Sprintf("room %s not found", params.RoomID))
+			}
+			return err
+		}
+		if params.NumPersons > room.Capacity() {
+			return NewError(http.StatusUnprocessableEntity, fmt.Sprintf("room %s fits at most %d persons", room.ID.Hex(), room.Capacity()))
+		}
+		entry.RoomID = room.ID
+		entry.HotelID = room.HotelID
+		wanted = append(wanted, room)
+	} else {
+		hotelID, _ := primitive.ObjectIDFromHex(params.HotelID)
+		rooms, err := h.store.Room.GetRooms(c.Context(), db.RoomFilter{HotelID: hotelID})
+		if err != nil {
+			return err
+		}
+		entry.HotelID = hotelID
+		for _, room := range rooms {
+			if entry.Wants(room) {
+				wanted = append(wanted, room)
+			}
+		}
+		if len(wanted) == 0 {
+			return NewError(http.StatusUnprocessableEntity, fmt.Sprintf("hotel %s has no %s room for %d persons", params.HotelID, params.RoomType, params.NumPersons))
+		}
+	}
+
+	roomIDs := make([]primitive.ObjectID, len(wanted))
+	for i, room := range wanted {
+		roomIDs[i] = room.ID
+	}
+	booked, err := bookedRooms(c.Context(), h.store.Booking, roomIDs, entry.FromDate, entry.TillDate)
+	if err != nil {
+		return err
+	}
+	for _, room := range wanted {
+		if !booked[room.ID] {
+			return NewError(http.StatusConflict, fmt.Sprintf("room %s is available for the stay", room.ID.Hex()))
+		}
+	}
+
+	waiting, err := h.store.Waitlist.GetWaitlistEntries(c.Context(), db.WaitlistFilter{
+		UserID:   user.ID,
+		HotelID:  entry.HotelID,
+		Status:   types.WaitlistWaiting,
+		Overlaps: &db.DateRange{From: entry.FromDate, Till: entry.TillDate},
+	})
+	if err != nil {
+		return err
+	}
+	for _, other := range waiting {
+		sameStay := other.FromDate.Equal(entry.FromDate) && other.TillDate.Equal(entry.TillDate)
+		if sameStay && other.RoomID == entry.RoomID && other.RoomType == entry.RoomType {
+			return NewError(http.StatusConflict, fmt.Sprintf("already on the waitlist with entry %s", other.ID.Hex()))
+		}
+	}
+
+	inserted, err := h.store.Waitlist.InsertWaitlistEntry(c.Context(), entry)
+	if err != nil {
+		return err
+	}
+	return c.Status(http.StatusCreated).JSON(inserted)
+}
+
+func (h *WaitlistHandler) HandleGetWaitlistEntries(c *fiber.Ctx) error {
+	user, err := getAuthUser(c)
+	if err != nil {
+		return ErrUnauthorized()
+	}
+	entries, err := h.store.Waitlist.GetWaitlistEntries(c.Context(), db.WaitlistFilter{UserID: user.ID})
+	if err != nil {
+		return err
+	}
+	return c.JSON(entries)
+}
+
+// HandleDeleteWaitlistEntry leaves entries offered a hold, whose hold is cancelled instead.
+func (h *WaitlistHandler) HandleDeleteWaitlistEntry(c *fiber.Ctx) error {
+	if _, err := primitive.ObjectIDFromHex(c.Params("id")); err != nil {
+		return ErrInvalidID()
+	}
+	entry, err := h.store.Waitlist.GetWaitlistEntryByID(c.Context(), c.Params("id"))
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return ErrResourceNotFound()
+		}
+		return err
+	}
+	user, err := getAuthUser(c)
+	if err != nil {
+		return ErrUnauthorized()
+	}
+	if entry.UserID != user.ID {
+		return ErrForbidden()
+	}
+
+	entry, err = h.store.Waitlist.UpdateWaitlistStatus(c.Context(), entry.ID.Hex(), types.WaitlistWaiting, types.WaitlistWithdrawn, primitive.NilObjectID)
+	if err != nil {
+		if errors.Is(err, db.ErrWaitlistStatusChanged) {
+			return NewError(http.StatusConflict, fmt.Sprintf("waitlist entry %s is no longer waiting", c.Params("id")))
+		}
+		return err
+	}
+	return c.JSON(entry)
+}
+
+// OfferExpiredHolds offers the nights of expired holds to the waitlist. Entries whose
+// hold was cancelled are withdrawn, the cancellation offered the nights already.
+func OfferExpiredHolds(ctx context.Context, store *db.Store, publisher events.Publisher, expired []*types.Booking, now time.Time) error {
+	entries, err := store.Waitlist.GetWaitlistEntries(ctx, db.WaitlistFilter{Status: types.WaitlistOffered})
+	if err != nil {
+		return err
+	}
+	freed := map[primitive.ObjectID]bool{}
+	for _, hold := range expired {
+		freed[hold.ID] = true
+	}
+	for _, entry := range entries {
+		// the hold of entries being offered is not recorded yet
+		if entry.HoldID.IsZero() {
+			continue
+		}
+		hold, err := store.Booking.GetBookingByID(ctx, entry.HoldID.Hex())
+		if err != nil {
+			return err
+		}
+		var to types.WaitlistStatus
+		switch hold.Status {
+		case types.BookingExpired:
+			to = types.WaitlistExpired
+		case types.BookingCancelled:
+			to = types.WaitlistWithdrawn
+		default:
+			continue
+		}
+		_, err = store.Waitlist.UpdateWaitlistStatus(ctx, entry.ID.Hex(), types.WaitlistOffered, to, primitive.NilObjectID)
+		if errors.Is(err, db.ErrWaitlistStatusChanged) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		// holds expired outside of the reaper are not among the expired ones
+		if to == types.WaitlistExpired && !freed[hold.ID] {
+			freed[hold.ID] = true
+			expired = append(expired, hold)
+		}
+	}
+	for _, hold := range expired {
+		if err := offerFreedRoom(ctx, store, publisher, hold, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// offerFreedRoom holds the room for every waiting guest, in line order, whose whole
+// stay is free.
+func offerFreedRoom(ctx context.Context, store *db.Store, publisher events.Publisher, cancelled *types.Booking, now time.Time) error {
+	room, err := store.Room.GetRoomByID(ctx, cancelled.RoomID.Hex())
+	if err != nil {
+		return err
+	}
+	hotel, err := store.Hotel.GetHotelByID(ctx, room.HotelID.Hex())
+	if err != nil {
+		return err
+	}
+	entries, err := store.Waitlist.GetWaitlistEntries(ctx, db.WaitlistFilter{
+		HotelID:  room.HotelID,
+		Status:   types.WaitlistWaiting,
+		Overlaps: &db.DateRange{From: cancelled.FromDate, Till: cancelled.TillDate},
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if !entry.Wants(room) || now.After(entry.FromDate) {
+			continue
+		}
+		// claim the entry first, so concurrent cancellations offer it once
+		_, err := store.Waitlist.UpdateWaitlistStatus(ctx, entry.ID.Hex(), types.WaitlistWaiting, types.WaitlistOffered, primitive.NilObjectID)
+		if errors.Is(err, db.ErrWaitlistStatusChanged) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		expiresAt := now.Add(waitlistHoldDuration)
+		hold, err := store.Booking.InsertHold(ctx, &types.Booking{
+			UserID:             entry.UserID,
+			RoomID:             room.ID,
+			HotelID:            room.HotelID,
+			FromDate:           entry.FromDate,
+			TillDate:           entry.TillDate,
+			NumPersons:         entry.NumPersons,
+			Price:              hotel.Quote(room, entry.FromDate, entry.TillDate, entry.NumPersons, nil),
+			CancellationPolicy: hotel.CancellationPolicyFor(room),
+		}, expiresAt)
+		if err != nil {
+			// put the guest back in line
+			if _, revertErr := store.Waitlist.UpdateWaitlistStatus(ctx, entry.ID.Hex(), types.WaitlistOffered, types.WaitlistWaiting, primitive.NilObjectID); revertErr != nil {
+				return revertErr
+			}
+			if errors.Is(err, db.ErrRoomNotAvailable) {
+				continue
+			}
+			return err
+		}
+		if _, err := store.Waitlist.UpdateWaitlistStatus(ctx, entry.ID.Hex(), types.WaitlistOffered, types.WaitlistOffered, hold.ID); err != nil {
+			return err
+		}
+
+		err = publisher.Publish(ctx, events.Event{
+			Type:   events.WaitlistOffered,
+			At:     now.UTC(),
+			UserID: entry.UserID,
+			Data: events.WaitlistOffer{
+				EntryID:   entry.ID,
+				HoldID:    hold.ID,
+				RoomID:    room.ID,
+				FromDate:  entry.FromDate,
+				TillDate:  entry.TillDate,
+				ExpiresAt: expiresAt,
+			},
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,425 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/raphaelmb/go-hotel-reservation/db"
+	"github.com/raphaelmb/go-hotel-reservation/db/fixtures"
+	"github.com/raphaelmb/go-hotel-reservation/events"
+	"github.com/raphaelmb/go-hotel-reservation/types"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func waitlistRequest(user *types.User, params types.CreateWaitlistEntryParams) *http.Request {
+	b, _ := json.Marshal(params)
+	req := httptest.NewRequest(http.MethodPost, "/waitlist", bytes.NewReader(b))
+	req.Header.Add("Content-Type", "application/json")
+	req.Header.Add("X-Api-Token", createToken(user))
+	return req
+}
+
+func TestWaitlist(t *testing.T) {
+	db := setup(t)
+	defer db.tearDown(t)
+
+	ctx := context.Background()
+	var (
+		user      = fixtures.AddUser(db.Store, "james", "foo", false)
+		otherUser = fixtures.AddUser(db.Store, "another", "user", false)
+		guest     = fixtures.AddUser(db.Store, "booked", "guest", false)
+		hotel     = fixtures.AddHotel(db.Store, "hotel", "anywhere", 4, nil)
+		room      = fixtures.AddRoom(db.Store, "small", true, 100, hotel.ID)
+
+		recorder        = &events.Recorder{}
+		bookingHandler  = NewBookingHandler(db.Store, recorder)
+		waitlistHandler = NewWaitlistHandler(db.Store)
+		app             = fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+		route           = app.Group("/", JWTAuthentication(db.User, db.Token, testTokens, nil))
+	)
+	route.Post("/waitlist", waitlistHandler.HandlePostWaitlistEntry)
+	route.Get("/waitlist", waitlistHandler.HandleGetWaitlistEntries)
+	route.Delete("/waitlist/:id", waitlistHandler.HandleDeleteWaitlistEntry)
+	route.Post("/booking/:id/cancel", bookingHandler.HandleCancelBooking)
+
+	suite, err := db.Room.InsertRoom(ctx, &types.Room{
+		Type:    types.RoomTypeSuite,
+		Size:    "large",
+		Price:   types.NewMoney(300, hotel.BaseCurrency()),
+		HotelID: hotel.ID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	send := func(t *testing.T, req *http.Request, status int, v any) {
+		resp, err := app.Test(req, -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != status {
+			t.Fatalf("expected %d response but got %d", status, resp.StatusCode)
+		}
+		if v != nil {
+			if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	day := func(days int) time.Time {
+		return time.Now().UTC().AddDate(0, 0, days).Truncate(time.Second)
+	}
+	forRoom := func(room *types.Room, from, till int) types.CreateWaitlistEntryParams {
+		return types.CreateWaitlistEntryParams{RoomID: room.ID.Hex(), FromDate: day(from), TillDate: day(till), NumPersons: 1}
+	}
+	forType := func(roomType types.RoomType, from, till int) types.CreateWaitlistEntryParams {
+		return types.CreateWaitlistEntryParams{HotelID: hotel.ID.Hex(), RoomType: roomType, FromDate: day(from), TillDate: day(till), NumPersons: 1}
+	}
+	deleteRequest := func(user *types.User, entry *types.WaitlistEntry) *http.Request {
+		req := httptest.NewRequest(http.MethodDelete, "/waitlist/"+entry.ID.Hex(), nil)
+		req.Header.Add("X-Api-Token", createToken(user))
+		return req
+	}
+	cancelRequest := func(user *types.User, booking *types.Booking) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/booking/"+booking.ID.Hex()+"/cancel", nil)
+		req.Header.Add("X-Api-Token", createToken(user))
+		return req
+	}
+
+	tests := []struct {
+		name   string
+		params types.CreateWaitlistEntryParams
+		status int
+	}{
+		{"neither a room nor a room type", types.CreateWaitlistEntryParams{FromDate: day(10), TillDate: day(12), NumPersons: 1}, http.StatusBadRequest},
+		{"a room and a room type", types.CreateWaitlistEntryParams{RoomID: room.ID.Hex(), HotelID: hotel.ID.Hex(), RoomType: types.RoomTypeSuite, FromDate: day(10), TillDate: day(12), NumPersons: 1}, http.StatusBadRequest},
+		{"a stay in the past", forRoom(room, -3, -1), http.StatusBadRequest},
+		{"a room type the hotel does not have", forType(types.RoomTypeFamily, 10, 12), http.StatusUnprocessableEntity},
+		{"more persons than the room fits", types.CreateWaitlistEntryParams{RoomID: room.ID.Hex(), FromDate: day(10), TillDate: day(12), NumPersons: 5}, http.StatusUnprocessableEntity},
+		{"a room which is free", forRoom(room, 60, 62), http.StatusConflict},
+		{"a room type with a free room", forType(types.RoomTypeSuite, 60, 62), http.StatusConflict},
+	}
+	for _, tt := range tests {
+		t.Run("should reject "+tt.name, func(t *testing.T) {
+			send(t, waitlistRequest(user, tt.params), tt.status, nil)
+		})
+	}
+
+	t.Run("should offer a freed room to the first guest waiting for it", func(t *testing.T) {
+		var (
+			booking = fixtures.AddBooking(db.Store, guest.ID, room.ID, day(10), day(13))
+			// booked on a night the cancellation does not free
+			blocked = fixtures.AddBooking(db.Store, guest.ID, room.ID, day(13), day(14))
+
+			tooLong, first, second types.WaitlistEntry
+		)
+		send(t, waitlistRequest(user, forRoom(room, 11, 14)), http.StatusCreated, &tooLong)
+		send(t, waitlistRequest(otherUser, forRoom(room, 10, 12)), http.StatusCreated, &first)
+		send(t, waitlistRequest(user, forRoom(room, 11, 13)), http.StatusCreated, &second)
+		send(t, waitlistRequest(user, forRoom(room, 11, 13)), http.StatusConflict, nil)
+
+		send(t, cancelRequest(guest, booking), http.StatusOK, nil)
+
+		offered, err := db.Waitlist.GetWaitlistEntryByID(ctx, first.ID.Hex())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if offered.Status != types.WaitlistOffered || offered.HoldID.IsZero() {
+			t.Fatalf("expected the entry to be offered a hold but got %+v", offered)
+		}
+		hold, err := db.Booking.GetBookingByID(ctx, offered.HoldID.Hex())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hold.Status != types.BookingPending || hold.UserID != otherUser.ID || !hold.FromDate.Equal(day(10)) {
+			t.Fatalf("expected a hold of the stay for the waiting guest but got %+v", hold)
+		}
+		if hold.ExpiresAt.Before(time.Now()) || hold.ExpiresAt.After(time.Now().Add(waitlistHoldDuration)) {
+			t.Fatalf("expected hold to expire within %s but got %s", waitlistHoldDuration, hold.ExpiresAt)
+		}
+		for _, entry := range []types.WaitlistEntry{tooLong, second} {
+			waiting, err := db.Waitlist.GetWaitlistEntryByID(ctx, entry.ID.Hex())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if waiting.Status != types.WaitlistWaiting {
+				t.Fatalf("expected entry %s to still wait but got %s", entry.ID.Hex(), waiting.Status)
+			}
+		}
+
+		published := recorder.Events()
+		if len(published) != 1 || published[0].Type != events.WaitlistOffered || published[0].UserID != otherUser.ID {
+			t.Fatalf("expected a single offer to the waiting guest but got %+v", published)
+		}
+		if offer := published[0].Data.(events.WaitlistOffer); offer.HoldID != hold.ID {
+			t.Fatalf("expected the offer of hold %s but got %s", hold.ID.Hex(), offer.HoldID.Hex())
+		}
+
+		// the held nights are not offered twice
+		send(t, cancelRequest(guest, blocked), http.StatusOK, nil)
+		waiting, err := db.Waitlist.GetWaitlistEntryByID(ctx, tooLong.ID.Hex())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if waiting.Status != types.WaitlistWaiting || len(recorder.Events()) != 1 {
+			t.Fatalf("expected entry %s to still wait but got %s", tooLong.ID.Hex(), waiting.Status)
+		}
+	})
+
+	t.Run("should offer a freed room to every guest it can be held for", func(t *testing.T) {
+		booking := fixtures.AddBooking(db.Store, guest.ID, room.ID, day(40), day(44))
+		var first, second types.WaitlistEntry
+		send(t, waitlistRequest(user, forRoom(room, 40, 42)), http.StatusCreated, &first)
+		send(t, waitlistRequest(otherUser, forRoom(room, 42, 44)), http.StatusCreated, &second)
+
+		send(t, cancelRequest(guest, booking), http.StatusOK, nil)
+
+		for _, entry := range []types.WaitlistEntry{first, second} {
+			offered, err := db.Waitlist.GetWaitlistEntryByID(ctx, entry.ID.Hex())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if offered.Status != types.WaitlistOffered {
+				t.Fatalf("expected entry %s to be offered a hold but got %s", entry.ID.Hex(), offered.Status)
+			}
+		}
+	})
+
+	t.Run("should offer a freed room of the room type waited for", func(t *testing.T) {
+		booking := fixtures.AddBooking(db.Store, guest.ID, suite.ID, day(20), day(22))
+		var entry types.WaitlistEntry
+		send(t, waitlistRequest(user, forType(types.RoomTypeSuite, 20, 22)), http.StatusCreated, &entry)
+
+		send(t, cancelRequest(guest, booking), http.StatusOK, nil)
+
+		offered, err := db.Waitlist.GetWaitlistEntryByID(ctx, entry.ID.Hex())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if offered.Status != types.WaitlistOffered {
+			t.Fatalf("expected the entry to be offered a hold but got %s", offered.Status)
+		}
+		hold, err := db.Booking.GetBookingByID(ctx, offered.HoldID.Hex())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hold.RoomID != suite.ID {
+			t.Fatalf("expected a hold of room %s but got %s", suite.ID.Hex(), hold.RoomID.Hex())
+		}
+	})
+
+	t.Run("should list and withdraw the entries of the user", func(t *testing.T) {
+		fixtures.AddBooking(db.Store, guest.ID, room.ID, day(30), day(32))
+		var entry types.WaitlistEntry
+		send(t, waitlistRequest(user, forRoom(room, 30, 32)), http.StatusCreated, &entry)
+
+		req := httptest.NewRequest(http.MethodGet, "/waitlist", nil)
+		req.Header.Add("X-Api-Token", createToken(otherUser))
+		var entries []*types.WaitlistEntry
+		send(t, req, http.StatusOK, &entries)
+		for _, e := range entries {
+			if e.UserID != otherUser.ID {
+				t.Fatalf("expected only entries of the user but got %+v", e)
+			}
+		}
+
+		send(t, deleteRequest(otherUser, &entry), http.StatusForbidden, nil)
+		var withdrawn types.WaitlistEntry
+		send(t, deleteRequest(user, &entry), http.StatusOK, &withdrawn)
+		if withdrawn.Status != types.WaitlistWithdrawn {
+			t.Fatalf("expected a withdrawn entry but got %s", withdrawn.Status)
+		}
+		send(t, deleteRequest(user, &entry), http.StatusConflict, nil)
+	})
+}
+
+func TestExpiredWaitlistOffer(t *testing.T) {
+	tdb := setup(t)
+	defer tdb.tearDown(t)
+
+	ctx := context.Background()
+	var (
+		user      = fixtures.AddUser(tdb.Store, "james", "foo", false)
+		otherUser = fixtures.AddUser(tdb.Store, "another", "user", false)
+		guest     = fixtures.AddUser(tdb.Store, "booked", "guest", false)
+		hotel     = fixtures.AddHotel(tdb.Store, "hotel", "anywhere", 4, nil)
+		room      = fixtures.AddRoom(tdb.Store, "small", true, 100, hotel.ID)
+
+		recorder        = &events.Recorder{}
+		bookingHandler  = NewBookingHandler(tdb.Store, recorder)
+		waitlistHandler = NewWaitlistHandler(tdb.Store)
+		app             = fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+		route           = app.Group("/", JWTAuthentication(tdb.User, tdb.Token, testTokens, nil))
+
+		from    = time.Now().UTC().AddDate(0, 0, 10).Truncate(time.Second)
+		till    = from.AddDate(0, 0, 2)
+		booking = fixtures.AddBooking(tdb.Store, guest.ID, room.ID, from, till)
+		params  = types.CreateWaitlistEntryParams{RoomID: room.ID.Hex(), FromDate: from, TillDate: till, NumPersons: 1}
+	)
+	route.Post("/waitlist", waitlistHandler.HandlePostWaitlistEntry)
+	route.Post("/booking/:id/cancel", bookingHandler.HandleCancelBooking)
+
+	send := func(req *http.Request, v any) {
+		resp, err := app.Test(req, -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode >= http.StatusBadRequest {
+			t.Fatalf("unexpected %d response", resp.StatusCode)
+		}
+		if v != nil {
+			if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	entry := func(id primitive.ObjectID) *types.WaitlistEntry {
+		entry, err := tdb.Waitlist.GetWaitlistEntryByID(ctx, id.Hex())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return entry
+	}
+
+	var first, second types.WaitlistEntry
+	send(waitlistRequest(otherUser, params), &first)
+	send(waitlistRequest(user, params), &second)
+	cancel := httptest.NewRequest(http.MethodPost, "/booking/"+booking.ID.Hex()+"/cancel", nil)
+	cancel.Header.Add("X-Api-Token", createToken(guest))
+	send(cancel, nil)
+	if offered := entry(first.ID); offered.Status != types.WaitlistOffered {
+		t.Fatalf("expected the first entry to be offered a hold but got %s", offered.Status)
+	}
+
+	// the first guest lets the hold expire
+	now := time.Now().Add(waitlistHoldDuration + time.Minute)
+	expired, err := db.ExpireHolds(ctx, tdb.Store, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := OfferExpiredHolds(ctx, tdb.Store, recorder, expired, now); err != nil {
+		t.Fatal(err)
+	}
+
+	if expired := entry(first.ID); expired.Status != types.WaitlistExpired {
+		t.Fatalf("expected the first entry to be expired but got %s", expired.Status)
+	}
+	offered := entry(second.ID)
+	if offered.Status != types.WaitlistOffered || offered.HoldID.IsZero() {
+		t.Fatalf("expected the second entry to be offered a hold but got %+v", offered)
+	}
+	hold, err := tdb.Booking.GetBookingByID(ctx, offered.HoldID.Hex())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hold.Status != types.BookingPending || hold.UserID != user.ID {
+		t.Fatalf("expected a hold for the second guest but got %+v", hold)
+	}
+	published := recorder.Events()
+	if len(published) != 2 || published[1].UserID != user.ID {
+		t.Fatalf("expected the room to be offered to the second guest but got %+v", published)
+	}
+
+	// the expired offer is handed on once
+	if err := OfferExpiredHolds(ctx, tdb.Store, recorder, nil, now); err != nil {
+		t.Fatal(err)
+	}
+	if len(recorder.Events()) != 2 {
+		t.Fatalf("expected no further offer but got %+v", recorder.Events())
+	}
+}
+
+func TestWaitlistFreedNights(t *testing.T) {
+	tdb := setup(t)
+	defer tdb.tearDown(t)
+
+	ctx := context.Background()
+	var (
+		user      = fixtures.AddUser(tdb.Store, "james", "foo", false)
+		otherUser = fixtures.AddUser(tdb.Store, "another", "user", false)
+		guest     = fixtures.AddUser(tdb.Store, "booked", "guest", false)
+		hotel     = fixtures.AddHotel(tdb.Store, "hotel", "anywhere", 4, nil)
+		room      = fixtures.AddRoom(tdb.Store, "small", true, 100, hotel.ID)
+
+		recorder        = &events.Recorder{}
+		bookingHandler  = NewBookingHandler(tdb.Store, recorder)
+		waitlistHandler = NewWaitlistHandler(tdb.Store)
+		app             = fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+		route           = app.Group("/", JWTAuthentication(tdb.User, tdb.Token, testTokens, nil))
+	)
+	route.Post("/waitlist", waitlistHandler.HandlePostWaitlistEntry)
+	route.Patch("/booking/:id", bookingHandler.HandleModifyBooking)
+
+	send := func(t *testing.T, req *http.Request, v any) {
+		resp, err := app.Test(req, -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode >= http.StatusBadRequest {
+			t.Fatalf("unexpected %d response", resp.StatusCode)
+		}
+		if v != nil {
+			if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	day := func(days int) time.Time {
+		return time.Now().UTC().AddDate(0, 0, days).Truncate(time.Second)
+	}
+	expectOffered := func(t *testing.T, entry types.WaitlistEntry) {
+		offered, err := tdb.Waitlist.GetWaitlistEntryByID(ctx, entry.ID.Hex())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if offered.Status != types.WaitlistOffered || offered.HoldID.IsZero() {
+			t.Fatalf("expected the entry to be offered a hold but got %+v", offered)
+		}
+	}
+
+	t.Run("should offer the nights of an expired hold", func(t *testing.T) {
+		_, err := tdb.Booking.InsertHold(ctx, &types.Booking{
+			UserID:   guest.ID,
+			RoomID:   room.ID,
+			HotelID:  hotel.ID,
+			FromDate: day(10),
+			TillDate: day(12),
+			Price:    hotel.Quote(room, day(10), day(12), 1, nil),
+		}, time.Now().Add(time.Hour))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var entry types.WaitlistEntry
+		send(t, waitlistRequest(user, types.CreateWaitlistEntryParams{RoomID: room.ID.Hex(), FromDate: day(10), TillDate: day(12), NumPersons: 1}), &entry)
+
+		now := time.Now().Add(2 * time.Hour)
+		expired, err := db.ExpireHolds(ctx, tdb.Store, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := OfferExpiredHolds(ctx, tdb.Store, recorder, expired, now); err != nil {
+			t.Fatal(err)
+		}
+		expectOffered(t, entry)
+	})
+
+	t.Run("should offer the nights freed by a modification", func(t *testing.T) {
+		booking := fixtures.AddBooking(tdb.Store, guest.ID, room.ID, day(20), day(22))
+		var entry types.WaitlistEntry
+		send(t, waitlistRequest(otherUser, types.CreateWaitlistEntryParams{RoomID: room.ID.Hex(), FromDate: day(20), TillDate: day(22), NumPersons: 1}), &entry)
+
+		from, till := day(24), day(26)
+		b, _ := json.Marshal(ModifyBookingParams{FromDate: &from, TillDate: &till, NumPersons: 1})
+		req := httptest.NewRequest(http.MethodPatch, "/booking/"+booking.ID.Hex(), bytes.NewReader(b))
+		req.Header.Add("Content-Type", "application/json")
+		req.Header.Add("X-Api-Token", createToken(guest))
+		send(t, req, nil)
+		expectOffered(t, entry)
+	})
+}
@@ -2,24 +2,64 @@ package db
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"os"
+	"time"
 
 	"github.com/raphaelmb/go-hotel-reservation/types"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+var (
+	ErrRoomNotAvailable   = errors.New("room is not available for the given dates")
+	ErrNoConfirmationCode = errors.New("no free confirmation code")
+)
+
+const ConfirmationCodeAttempts = 5
+
+type TransitionError struct {
+	From types.BookingStatus
+	To   types.BookingStatus
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("booking cannot go from %s to %s", e.From, e.To)
+}
+
 type BookingStore interface {
+	// InsertBooking reserves every night of the booking for its room or returns
+	// ErrRoomNotAvailable.
 	InsertBooking(context.Context, *types.Booking) (*types.Booking, error)
-	GetBookings(context.Context, bson.M) ([]*types.Booking, error)
+	InsertBookings(context.Context, []*types.Booking) ([]*types.Booking, error)
+	InsertHold(context.Context, *types.Booking, time.Time) (*types.Booking, error)
+	GetBookings(context.Context, BookingFilter, *Pagination) ([]*types.Booking, error)
 	GetBookingByID(context.Context, string) (*types.Booking, error)
-	UpdateBooking(context.Context, string, bson.M) error
+	GetBookingByConfirmationCode(context.Context, string) (*types.Booking, error)
+	UpdateBookingStatus(context.Context, string, types.BookingStatus) (*types.Booking, error)
+	// CancelBooking returns a *TransitionError unless the booking is still in the
+	// status it was given with.
+	CancelBooking(context.Context, *types.Booking, *types.Cancellation) (*types.Booking, error)
+	// ModifyBooking reserves the new nights before releasing the old ones.
+	ModifyBooking(context.Context, *types.Booking) (*types.Booking, error)
+}
+
+// The unique index of roomNight lets a room night be held by one booking.
+type roomNight struct {
+	RoomID    primitive.ObjectID `bson:"roomID"`
+	Night     time.Time          `bson:"night"`
+	BookingID primitive.ObjectID `bson:"bookingID"`
 }
 
 type MongoBookingStore struct {
 	client *mongo.Client
 	coll   *mongo.Collection
+	nights *mongo.Collection
+
+	indexes indexes
 
 	BookingStore
 }
@@ -29,20 +69,166 @@ func NewMongoBookingStore(client *mongo.Client) *MongoBookingStore {
 	return &MongoBookingStore{
 		client: client,
 		coll:   client.Database(dbName).Collection("bookings"),
+		nights: client.Database(dbName).Collection("room_nights"),
 	}
 }
 
-func (s *MongoBookingStore) UpdateBooking(ctx context.Context, id string, update bson.M) error {
+func (s *MongoBookingStore) ensureIndexes(ctx context.Context) error {
+	return s.indexes.ensure(ctx, func(ctx context.Context) error {
+		_, err := s.nights.Indexes().CreateMany(ctx, []mongo.IndexModel{
+			{
+				Keys:    bson.D{{Key: "roomID", Value: 1}, {Key: "night", Value: 1}},
+				Options: options.Index().SetUnique(true),
+			},
+			{
+				Keys: bson.D{{Key: "bookingID", Value: 1}},
+			},
+		})
+		if err != nil {
+			return err
+		}
+		// bookings made before confirmation codes have none
+		_, err = s.coll.Indexes().CreateOne(ctx, mongo.IndexModel{
+			Keys:    bson.D{{Key: "confirmationCode", Value: 1}},
+			Options: options.Index().SetUnique(true).SetSparse(true),
+		})
+		return err
+	})
+}
+
+func (s *MongoBookingStore) UpdateBookingStatus(ctx context.Context, id string, status types.BookingStatus) (*types.Booking, error) {
 	oid, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	return s.updateStatus(ctx, oid, types.BookingStatusesBefore(status), status, bson.M{"status": status})
+}
+
+func (s *MongoBookingStore) CancelBooking(ctx context.Context, booking *types.Booking, cancellation *types.Cancellation) (*types.Booking, error) {
+	if !booking.Status.CanTransitionTo(types.BookingCancelled) {
+		return nil, &TransitionError{From: booking.Status, To: types.BookingCancelled}
+	}
+	set := bson.M{"status": types.BookingCancelled, "cancellation": cancellation}
+	return s.updateStatus(ctx, booking.ID, []types.BookingStatus{booking.Status}, types.BookingCancelled, set)
+}
+
+func (s *MongoBookingStore) ModifyBooking(ctx context.Context, booking *types.Booking) (*types.Booking, error) {
+	if err := s.ensureIndexes(ctx); err != nil {
+		return nil, err
+	}
+
+	// claim the new nights the booking does not hold yet, rolling them back on failure
+	var claimed []time.Time
+	for _, night := range booking.Nights() {
+		_, err := s.nights.InsertOne(ctx, roomNight{RoomID: booking.RoomID, Night: night, BookingID: booking.ID})
+		if err == nil {
+			claimed = append(claimed, night)
+			continue
+		}
+		if mongo.IsDuplicateKeyError(err) {
+			var held int64
+			held, err = s.nights.CountDocuments(ctx, bson.M{"roomID": booking.RoomID, "night": night, "bookingID": booking.ID})
+			if err == nil && held > 0 {
+				continue
+			}
+			if err == nil {
+				err = ErrRoomNotAvailable
+			}
+		}
+		s.unclaimNights(ctx, booking, claimed)
+		return nil, err
+	}
+
+	var (
+		filter = bson.M{"_id": booking.ID, "status": booking.Status}
+		update = bson.M{"$set": bson.M{
+			"roomID":             booking.RoomID,
+			"fromDate":           booking.FromDate,
+			"tillDate":           booking.TillDate,
+			"numPersons":         booking.NumPersons,
+			"price":              booking.Price,
+			"cancellationPolicy": booking.CancellationPolicy,
+			"modifications":      booking.Modifications,
+		}}
+		opts     = options.FindOneAndUpdate().SetReturnDocument(options.After)
+		modified *types.Booking
+	)
+	if err := s.coll.FindOneAndUpdate(ctx, filter, update, opts).Decode(&modified); err != nil {
+		s.unclaimNights(ctx, booking, claimed)
+		if !errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, err
+		}
+		current, err := s.GetBookingByID(ctx, booking.ID.Hex())
+		if err != nil {
+			return nil, err
+		}
+		return nil, &TransitionError{From: current.Status, To: booking.Status}
 	}
-	m := bson.M{"$set": update}
-	_, err = s.coll.UpdateByID(ctx, oid, m)
+
+	// release the old nights the new stay does not cover
+	_, err := s.nights.DeleteMany(ctx, bson.M{
+		"bookingID": booking.ID,
+		"$nor":      bson.A{bson.M{"roomID": booking.RoomID, "night": bson.M{"$in": booking.Nights()}}},
+	})
 	if err != nil {
+		return nil, err
+	}
+	return modified, nil
+}
+
+func (s *MongoBookingStore) unclaimNights(ctx context.Context, booking *types.Booking, nights []time.Time) {
+	if len(nights) == 0 {
+		return
+	}
+	s.nights.DeleteMany(ctx, bson.M{"bookingID": booking.ID, "roomID": booking.RoomID, "night": bson.M{"$in": nights}})
+}
+
+func (s *MongoBookingStore) updateStatus(ctx context.Context, oid primitive.ObjectID, from []types.BookingStatus, status types.BookingStatus, set bson.M) (*types.Booking, error) {
+	// the status condition makes the transition atomic
+	var (
+		filter = bson.M{"_id": oid, "status": bson.M{"$in": from}}
+		update = bson.M{
+			"$set":  set,
+			"$push": bson.M{"statusHistory": types.BookingStatusChange{Status: status, At: time.Now().UTC()}},
+		}
+		opts    = options.FindOneAndUpdate().SetReturnDocument(options.After)
+		booking *types.Booking
+	)
+	if err := s.coll.FindOneAndUpdate(ctx, filter, update, opts).Decode(&booking); err != nil {
+		if !errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, err
+		}
+		current, err := s.GetBookingByID(ctx, oid.Hex())
+		if err != nil {
+			return nil, err
+		}
+		return nil, &TransitionError{From: current.Status, To: status}
+	}
+
+	if !status.IsActive() {
+		if err := s.releaseNights(ctx, oid); err != nil {
+			return nil, err
+		}
+	}
+	return booking, nil
+}
+
+// MigrateStatus derives the status of bookings stored before it from their
+// cancelled flag.
+func (s *MongoBookingStore) MigrateStatus(ctx context.Context) error {
+	legacy := bson.M{"status": bson.M{"$exists": false}}
+	migrate := func(filter bson.M, status types.BookingStatus) error {
+		update := bson.M{
+			"$set":   bson.M{"status": status, "statusHistory": bson.A{}},
+			"$unset": bson.M{"cancelled": ""},
+		}
+		_, err := s.coll.UpdateMany(ctx, filter, update)
+		return err
+	}
+	if err := migrate(bson.M{"$and": bson.A{legacy, bson.M{"cancelled": true}}}, types.BookingCancelled); err != nil {
 		return err
 	}
-	return nil
+	return migrate(legacy, types.BookingConfirmed)
 }
 
 func (s *MongoBookingStore) GetBookingByID(ctx context.Context, id string) (*types.Booking, error) {
@@ -52,13 +238,29 @@ func (s *MongoBookingStore) GetBookingByID(ctx context.Context, id string) (*typ
 	}
 	var booking *types.Booking
 	if err := s.coll.FindOne(ctx, bson.M{"_id": oid}).Decode(&booking); err != nil {
-		return nil, err
+		return nil, notFound(err)
 	}
 	return booking, nil
 }
 
-func (s *MongoBookingStore) GetBookings(ctx context.Context, filter bson.M) ([]*types.Booking, error) {
-	curr, err := s.coll.Find(ctx, filter)
+func (s *MongoBookingStore) GetBookingByConfirmationCode(ctx context.Context, code string) (*types.Booking, error) {
+	var booking *types.Booking
+	filter := bson.M{"confirmationCode": types.NormalizeConfirmationCode(code)}
+	if err := s.coll.FindOne(ctx, filter).Decode(&booking); err != nil {
+		return nil, notFound(err)
+	}
+	return booking, nil
+}
+
+func (s *MongoBookingStore) GetBookings(ctx context.Context, filter BookingFilter, pag *Pagination) ([]*types.Booking, error) {
+	opts := options.Find().SetSort(filter.SortBSON())
+	if pag != nil && pag.Limit > 0 {
+		if pag.Page > 1 {
+			opts.SetSkip((pag.Page - 1) * pag.Limit)
+		}
+		opts.SetLimit(pag.Limit)
+	}
+	curr, err := s.coll.Find(ctx, filter.ToBSON(), opts)
 	if err != nil {
 		return nil, err
 	}
@@ -70,11 +272,105 @@ func (s *MongoBookingStore) GetBookings(ctx context.Context, filter bson.M) ([]*
 }
 
 func (s *MongoBookingStore) InsertBooking(ctx context.Context, booking *types.Booking) (*types.Booking, error) {
-	res, err := s.coll.InsertOne(ctx, booking)
+	bookings, err := s.InsertBookings(ctx, []*types.Booking{booking})
 	if err != nil {
 		return nil, err
 	}
-	booking.ID = res.InsertedID.(primitive.ObjectID)
+	return bookings[0], nil
+}
 
-	return booking, nil
+func (s *MongoBookingStore) InsertBookings(ctx context.Context, bookings []*types.Booking) ([]*types.Booking, error) {
+	if err := s.ensureIndexes(ctx); err != nil {
+		return nil, err
+	}
+
+	docs := make([]any, len(bookings))
+	for i, booking := range bookings {
+		booking.ID = primitive.NewObjectID()
+		if len(booking.Status) == 0 {
+			booking.SetStatus(types.BookingConfirmed, time.Now())
+		}
+		if booking.Status.IsActive() {
+			if err := s.reserveNights(ctx, booking); err != nil {
+				// roll back the nights of the bookings before
+				for _, reserved := range bookings[:i] {
+					s.releaseNights(ctx, reserved.ID)
+				}
+				return nil, err
+			}
+		}
+		docs[i] = booking
+	}
+
+	if err := s.insertWithCodes(ctx, bookings, docs); err != nil {
+		for _, booking := range bookings {
+			s.releaseNights(ctx, booking.ID)
+		}
+		return nil, err
+	}
+
+	return bookings, nil
+}
+
+func (s *MongoBookingStore) insertWithCodes(ctx context.Context, bookings []*types.Booking, docs []any) error {
+	ids := make([]primitive.ObjectID, len(bookings))
+	for i, booking := range bookings {
+		ids[i] = booking.ID
+	}
+	for attempt := 0; attempt < ConfirmationCodeAttempts; attempt++ {
+		for _, booking := range bookings {
+			code, err := types.NewConfirmationCode()
+			if err != nil {
+				return err
+			}
+			booking.ConfirmationCode = code
+		}
+		_, err := s.coll.InsertMany(ctx, docs)
+		if err == nil {
+			return nil
+		}
+		// drop the bookings inserted before the failure
+		if _, delErr := s.coll.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": ids}}); delErr != nil {
+			return delErr
+		}
+		if !mongo.IsDuplicateKeyError(err) {
+			return err
+		}
+	}
+	return ErrNoConfirmationCode
+}
+
+func (s *MongoBookingStore) InsertHold(ctx context.Context, booking *types.Booking, expiresAt time.Time) (*types.Booking, error) {
+	booking.SetStatus(types.BookingPending, time.Now())
+	booking.ExpiresAt = expiresAt.UTC()
+	return s.InsertBooking(ctx, booking)
+}
+
+// reserveNights claims the nights in order, so concurrent requests for a stay race
+// for the same first night.
+func (s *MongoBookingStore) reserveNights(ctx context.Context, booking *types.Booking) error {
+	var docs []any
+	for _, night := range booking.Nights() {
+		docs = append(docs, roomNight{
+			RoomID:    booking.RoomID,
+			Night:     night,
+			BookingID: booking.ID,
+		})
+	}
+
+	_, err := s.nights.InsertMany(ctx, docs, options.InsertMany().SetOrdered(true))
+	if err == nil {
+		return nil
+	}
+	// roll back the nights claimed before the failure
+	s.releaseNights(ctx, booking.ID)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrRoomNotAvailable
+	}
+	return err
+}
+
+func (s *MongoBookingStore) releaseNights(ctx context.Context, bookingID primitive.ObjectID) error {
+	_, err := s.nights.DeleteMany(ctx, bson.M{"bookingID": bookingID})
+	return err
 }
@@ -1,6 +1,23 @@
 package db
 
-const MongoDBNameEnvName = "MONGO_DB_NAME"
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var ErrNotFound = errors.New("not found")
+
+const (
+	MongoDBNameEnvName = "MONGO_DB_NAME"
+	BackendEnvName     = "DB_BACKEND"
+
+	BackendMongo  = "mongo"
+	BackendMemory = "memory"
+	BackendSQLite = "sqlite"
+)
 
 type Pagination struct {
 	Limit int64
@@ -8,8 +25,38 @@ type Pagination struct {
 }
 
 type Store struct {
-	User    UserStore
-	Hotel   HotelStore
-	Room    RoomStore
-	Booking BookingStore
+	User        UserStore
+	Hotel       HotelStore
+	Room        RoomStore
+	Booking     BookingStore
+	Promo       PromoCodeStore
+	Reservation ReservationStore
+	Waitlist    WaitlistStore
+	Token       TokenStore
+}
+
+func notFound(err error) error {
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// indexes are created before the first write and retried on the next one if that fails.
+type indexes struct {
+	mu      sync.Mutex
+	created bool
+}
+
+func (i *indexes) ensure(ctx context.Context, create func(context.Context) error) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.created {
+		return nil
+	}
+	if err := create(ctx); err != nil {
+		return err
+	}
+	i.created = true
+	return nil
 }
@@ -0,0 +1,166 @@
+package db
+
+import (
+	"regexp"
+	"time"
+
+	"github.com/raphaelmb/go-hotel-reservation/types"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// DateRange is a half open [From, Till) interval. A zero Till leaves it open ended.
+type DateRange struct {
+	From time.Time
+	Till time.Time
+}
+
+type BookingSort string
+
+const (
+	SortByCreation BookingSort = "created"
+	SortByFromDate BookingSort = "fromDate"
+	SortByTillDate BookingSort = "tillDate"
+)
+
+func (s BookingSort) IsValid() bool {
+	return s == SortByCreation || s == SortByFromDate || s == SortByTillDate
+}
+
+type BookingFilter struct {
+	UserID primitive.ObjectID
+	RoomID primitive.ObjectID
+	// a non nil empty RoomIDs or HotelIDs matches no booking
+	RoomIDs       []primitive.ObjectID
+	HotelID       primitive.ObjectID
+	HotelIDs      []primitive.ObjectID
+	ReservationID primitive.ObjectID
+	Overlaps      *DateRange
+	Statuses      []types.BookingStatus
+	ExpiredBy     time.Time
+	EndedBy       time.Time
+	// bookings are sorted by creation when SortBy is empty
+	SortBy   BookingSort
+	SortDesc bool
+}
+
+func (f BookingFilter) ToBSON() bson.M {
+	m := bson.M{}
+	if !f.UserID.IsZero() {
+		m["userId"] = f.UserID
+	}
+	if !f.RoomID.IsZero() {
+		m["roomID"] = f.RoomID
+	} else if f.RoomIDs != nil {
+		m["roomID"] = bson.M{"$in": f.RoomIDs}
+	}
+	if !f.HotelID.IsZero() {
+		m["hotelID"] = f.HotelID
+	} else if f.HotelIDs != nil {
+		m["hotelID"] = bson.M{"$in": f.HotelIDs}
+	}
+	if !f.ReservationID.IsZero() {
+		m["reservationID"] = f.ReservationID
+	}
+	tillDate := bson.M{}
+	if f.Overlaps != nil {
+		if !f.Overlaps.Till.IsZero() {
+			m["fromDate"] = bson.M{"$lt": f.Overlaps.Till}
+		}
+		tillDate["$gt"] = f.Overlaps.From
+	}
+	if !f.EndedBy.IsZero() {
+		tillDate["$lte"] = f.EndedBy
+	}
+	if len(tillDate) > 0 {
+		m["tillDate"] = tillDate
+	}
+	if len(f.Statuses) > 0 {
+		m["status"] = bson.M{"$in": f.Statuses}
+	}
+	if !f.ExpiredBy.IsZero() {
+		m["expiresAt"] = bson.M{"$lte": f.ExpiredBy}
+	}
+	return m
+}
+
+// SortBSON lists ties in the order they were made.
+func (f BookingFilter) SortBSON() bson.D {
+	order := 1
+	if f.SortDesc {
+		order = -1
+	}
+	switch f.SortBy {
+	case SortByFromDate:
+		return bson.D{{Key: "fromDate", Value: order}, {Key: "_id", Value: order}}
+	case SortByTillDate:
+		return bson.D{{Key: "tillDate", Value: order}, {Key: "_id", Value: order}}
+	}
+	return bson.D{{Key: "_id", Value: order}}
+}
+
+type HotelFilter struct {
+	Rating    int
+	MinRating int
+	Location  string
+}
+
+func (f HotelFilter) ToBSON() bson.M {
+	m := bson.M{}
+	if f.Rating > 0 {
+		m["rating"] = f.Rating
+	} else if f.MinRating > 0 {
+		m["rating"] = bson.M{"$gte": f.MinRating}
+	}
+	if len(f.Location) > 0 {
+		m["location"] = primitive.Regex{Pattern: "^" + regexp.QuoteMeta(f.Location) + "$", Options: "i"}
+	}
+	return m
+}
+
+type RoomFilter struct {
+	HotelID primitive.ObjectID
+	// a non nil empty slice matches no room
+	HotelIDs []primitive.ObjectID
+	Seaside  *bool
+}
+
+func (f RoomFilter) ToBSON() bson.M {
+	m := bson.M{}
+	if !f.HotelID.IsZero() {
+		m["hotelID"] = f.HotelID
+	} else if f.HotelIDs != nil {
+		m["hotelID"] = bson.M{"$in": f.HotelIDs}
+	}
+	if f.Seaside != nil {
+		m["seaside"] = *f.Seaside
+	}
+	return m
+}
+
+type WaitlistFilter struct {
+	UserID   primitive.ObjectID
+	HotelID  primitive.ObjectID
+	Status   types.WaitlistStatus
+	Overlaps *DateRange
+}
+
+func (f WaitlistFilter) ToBSON() bson.M {
+	m := bson.M{}
+	if !f.UserID.IsZero() {
+		m["userId"] = f.UserID
+	}
+	if !f.HotelID.IsZero() {
+		m["hotelID"] = f.HotelID
+	}
+	if len(f.Status) > 0 {
+		m["status"] = f.Status
+	}
+	if f.Overlaps != nil {
+		if !f.Overlaps.Till.IsZero() {
+			m["fromDate"] = bson.M{"$lt": f.Overlaps.Till}
+		}
+		m["tillDate"] = bson.M{"$gt": f.Overlaps.From}
+	}
+	return m
+}
@@ -12,9 +12,14 @@ import (
 )
 
 func AddBooking(store *db.Store, uid, rid primitive.ObjectID, from, till time.Time) *types.Booking {
+	room, err := store.Room.GetRoomByID(context.Background(), rid.Hex())
+	if err != nil {
+		log.Fatal(err)
+	}
 	booking := &types.Booking{
 		UserID:   uid,
 		RoomID:   rid,
+		HotelID:  room.HotelID,
 		FromDate: from,
 		TillDate: till,
 	}
@@ -26,10 +31,14 @@ func AddBooking(store *db.Store, uid, rid primitive.ObjectID, from, till time.Ti
 }
 
 func AddRoom(store *db.Store, size string, ss bool, price float64, hid primitive.ObjectID) *types.Room {
+	hotel, err := store.Hotel.GetHotelByID(context.Background(), hid.Hex())
+	if err != nil {
+		log.Fatal(err)
+	}
 	room := &types.Room{
 		Size:    size,
 		Seaside: ss,
-		Price:   price,
+		Price:   types.NewMoney(price, hotel.BaseCurrency()),
 		HotelID: hid,
 	}
 	insertedRoom, err := store.Room.InsertRoom(context.TODO(), room)
@@ -49,6 +58,7 @@ func AddHotel(store *db.Store, name string, loc string, rating int, rooms []prim
 		Location: loc,
 		Rooms:    roomIDs,
 		Rating:   rating,
+		Currency: types.DefaultCurrency,
 	}
 	insertedHotel, err := store.Hotel.Insert(context.TODO(), &hotel)
 	if err != nil {
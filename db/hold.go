@@ -0,0 +1,69 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/raphaelmb/go-hotel-reservation/types"
+)
+
+// ExpireHolds releases the nights and promo codes of the holds expired at now.
+func ExpireHolds(ctx context.Context, store *Store, now time.Time) ([]*types.Booking, error) {
+	holds, err := store.Booking.GetBookings(ctx, BookingFilter{
+		Statuses:  []types.BookingStatus{types.BookingPending},
+		ExpiredBy: now,
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var expired []*types.Booking
+	for _, hold := range holds {
+		_, err := store.Booking.UpdateBookingStatus(ctx, hold.ID.Hex(), types.BookingExpired)
+		if err != nil {
+			// the hold was confirmed or cancelled in the meantime
+			var transitionErr *TransitionError
+			if errors.As(err, &transitionErr) {
+				continue
+			}
+			return expired, err
+		}
+		if err := ReleaseHoldPromo(ctx, store.Promo, hold); err != nil {
+			return expired, err
+		}
+		expired = append(expired, hold)
+	}
+	return expired, nil
+}
+
+// ReapHolds expires holds every interval and hands them to released, if not nil.
+func ReapHolds(ctx context.Context, store *Store, interval time.Duration, released func(ctx context.Context, expired []*types.Booking, now time.Time) error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			expired, err := ExpireHolds(ctx, store, now)
+			if err != nil {
+				log.Printf("failed to expire holds: %v", err)
+				continue
+			}
+			if released != nil {
+				if err := released(ctx, expired, now); err != nil {
+					log.Printf("failed to release expired holds: %v", err)
+				}
+			}
+		}
+	}
+}
+
+func ReleaseHoldPromo(ctx context.Context, store PromoCodeStore, hold *types.Booking) error {
+	if hold.Status != types.BookingPending || hold.Price == nil || hold.Price.Promo == nil {
+		return nil
+	}
+	return store.ReleasePromoCode(ctx, hold.Price.Promo.Code, hold.UserID)
+}
@@ -13,9 +13,14 @@ import (
 
 type HotelStore interface {
 	Insert(context.Context, *types.Hotel) (*types.Hotel, error)
-	Update(context.Context, Map, Map) error
-	GetHotels(context.Context, Map, *Pagination) ([]*types.Hotel, error)
+	Update(context.Context, string, types.UpdateHotelParams) error
+	Delete(context.Context, string) error
+	GetHotels(context.Context, HotelFilter, *Pagination) ([]*types.Hotel, error)
 	GetHotelByID(context.Context, string) (*types.Hotel, error)
+	// AddRoom and RemoveRoom keep the rooms of a hotel in sync with the
+	// room store.
+	AddRoom(ctx context.Context, hotelID, roomID primitive.ObjectID) error
+	RemoveRoom(ctx context.Context, hotelID, roomID primitive.ObjectID) error
 }
 
 type MongoHotelStore struct {
@@ -31,14 +36,51 @@ func NewMongoHotelStore(client *mongo.Client) *MongoHotelStore {
 	}
 }
 
-func (s *MongoHotelStore) Update(ctx context.Context, filter Map, update Map) error {
-	_, err := s.coll.UpdateOne(ctx, filter, update)
+func (s *MongoHotelStore) Update(ctx context.Context, id string, params types.UpdateHotelParams) error {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+	update := params.ToBSON()
+	if len(update) == 0 {
+		_, err := s.GetHotelByID(ctx, id)
+		return err
+	}
+	res, err := s.coll.UpdateByID(ctx, oid, bson.M{"$set": update})
 	if err != nil {
 		return err
 	}
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
 	return nil
 }
 
+func (s *MongoHotelStore) Delete(ctx context.Context, id string) error {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+	res, err := s.coll.DeleteOne(ctx, bson.M{"_id": oid})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *MongoHotelStore) AddRoom(ctx context.Context, hotelID, roomID primitive.ObjectID) error {
+	_, err := s.coll.UpdateByID(ctx, hotelID, bson.M{"$push": bson.M{"rooms": roomID}})
+	return err
+}
+
+func (s *MongoHotelStore) RemoveRoom(ctx context.Context, hotelID, roomID primitive.ObjectID) error {
+	_, err := s.coll.UpdateByID(ctx, hotelID, bson.M{"$pull": bson.M{"rooms": roomID}})
+	return err
+}
+
 func (s *MongoHotelStore) Insert(ctx context.Context, hotel *types.Hotel) (*types.Hotel, error) {
 	res, err := s.coll.InsertOne(ctx, hotel)
 	if err != nil {
@@ -49,11 +91,12 @@ func (s *MongoHotelStore) Insert(ctx context.Context, hotel *types.Hotel) (*type
 	return hotel, nil
 }
 
-func (s *MongoHotelStore) GetHotels(ctx context.Context, filter Map, pag *Pagination) ([]*types.Hotel, error) {
+func (s *MongoHotelStore) GetHotels(ctx context.Context, filter HotelFilter, pag *Pagination) ([]*types.Hotel, error) {
 	opts := options.FindOptions{}
+	opts.SetSort(bson.M{"_id": 1})
 	opts.SetSkip((pag.Page - 1) * pag.Limit)
 	opts.SetLimit(pag.Limit)
-	resp, err := s.coll.Find(ctx, filter, &opts)
+	resp, err := s.coll.Find(ctx, filter.ToBSON(), &opts)
 	if err != nil {
 		return nil, err
 	}
@@ -71,7 +114,7 @@ func (s *MongoHotelStore) GetHotelByID(ctx context.Context, id string) (*types.H
 	}
 	var hotel *types.Hotel
 	if err := s.coll.FindOne(ctx, bson.M{"_id": oid}).Decode(&hotel); err != nil {
-		return nil, err
+		return nil, notFound(err)
 	}
 	return hotel, nil
 }
@@ -0,0 +1,276 @@
+package memory
+
+import (
+	"bytes"
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/raphaelmb/go-hotel-reservation/db"
+	"github.com/raphaelmb/go-hotel-reservation/types"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type roomNight struct {
+	roomID primitive.ObjectID
+	night  time.Time
+}
+
+type BookingStore struct {
+	coll *collection
+
+	// mu guards the ledger of reserved room nights
+	mu     sync.Mutex
+	nights map[roomNight]primitive.ObjectID
+}
+
+func NewBookingStore() *BookingStore {
+	return &BookingStore{
+		coll:   &collection{},
+		nights: map[roomNight]primitive.ObjectID{},
+	}
+}
+
+func (s *BookingStore) Drop(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.coll.drop()
+	s.nights = map[roomNight]primitive.ObjectID{}
+	return nil
+}
+
+func (s *BookingStore) UpdateBookingStatus(ctx context.Context, id string, status types.BookingStatus) (*types.Booking, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	booking, err := s.GetBookingByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.updateStatus(booking, status, bson.M{}); err != nil {
+		return nil, err
+	}
+	return booking, nil
+}
+
+func (s *BookingStore) CancelBooking(ctx context.Context, booking *types.Booking, cancellation *types.Cancellation) (*types.Booking, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current, err := s.GetBookingByID(ctx, booking.ID.Hex())
+	if err != nil {
+		return nil, err
+	}
+	if current.Status != booking.Status {
+		return nil, &db.TransitionError{From: current.Status, To: types.BookingCancelled}
+	}
+	if err := s.updateStatus(current, types.BookingCancelled, bson.M{"cancellation": cancellation}); err != nil {
+		return nil, err
+	}
+	current.Cancellation = cancellation
+	return current, nil
+}
+
+func (s *BookingStore) ModifyBooking(ctx context.Context, booking *types.Booking) (*types.Booking, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current, err := s.GetBookingByID(ctx, booking.ID.Hex())
+	if err != nil {
+		return nil, err
+	}
+	if current.Status != booking.Status {
+		return nil, &db.TransitionError{From: current.Status, To: booking.Status}
+	}
+	nights := booking.Nights()
+	for _, night := range nights {
+		if id, taken := s.nights[roomNight{booking.RoomID, night}]; taken && id != booking.ID {
+			return nil, db.ErrRoomNotAvailable
+		}
+	}
+
+	set := bson.M{
+		"roomID":             booking.RoomID,
+		"fromDate":           booking.FromDate,
+		"tillDate":           booking.TillDate,
+		"numPersons":         booking.NumPersons,
+		"price":              booking.Price,
+		"cancellationPolicy": booking.CancellationPolicy,
+		"modifications":      booking.Modifications,
+	}
+	if _, err := s.coll.update(bson.M{"_id": booking.ID}, bson.M{"$set": set}, false); err != nil {
+		return nil, err
+	}
+	s.releaseNights(booking.ID)
+	for _, night := range nights {
+		s.nights[roomNight{booking.RoomID, night}] = booking.ID
+	}
+	return s.GetBookingByID(ctx, booking.ID.Hex())
+}
+
+// updateStatus must be called with mu held.
+func (s *BookingStore) updateStatus(booking *types.Booking, status types.BookingStatus, set bson.M) error {
+	if !booking.Status.CanTransitionTo(status) {
+		return &db.TransitionError{From: booking.Status, To: status}
+	}
+
+	booking.SetStatus(status, time.Now())
+	set["status"] = booking.Status
+	set["statusHistory"] = booking.StatusHistory
+	if _, err := s.coll.update(bson.M{"_id": booking.ID}, bson.M{"$set": set}, false); err != nil {
+		return err
+	}
+	if !status.IsActive() {
+		s.releaseNights(booking.ID)
+	}
+	return nil
+}
+
+func (s *BookingStore) GetBookingByID(ctx context.Context, id string) (*types.Booking, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+	var booking *types.Booking
+	if err := s.coll.findOne(bson.M{"_id": oid}, &booking); err != nil {
+		return nil, err
+	}
+	return booking, nil
+}
+
+func (s *BookingStore) GetBookingByConfirmationCode(ctx context.Context, code string) (*types.Booking, error) {
+	var booking *types.Booking
+	if err := s.coll.findOne(bson.M{"confirmationCode": types.NormalizeConfirmationCode(code)}, &booking); err != nil {
+		return nil, err
+	}
+	return booking, nil
+}
+
+func (s *BookingStore) GetBookings(ctx context.Context, filter db.BookingFilter, pag *db.Pagination) ([]*types.Booking, error) {
+	var bookings []*types.Booking
+	if err := s.coll.find(filter.ToBSON(), 0, 0, &bookings); err != nil {
+		return nil, err
+	}
+
+	sortBookings(bookings, filter)
+	if pag == nil || pag.Limit <= 0 {
+		return bookings, nil
+	}
+	start := skip(pag)
+	if start >= int64(len(bookings)) {
+		return []*types.Booking{}, nil
+	}
+	end := start + pag.Limit
+	if end > int64(len(bookings)) {
+		end = int64(len(bookings))
+	}
+	return bookings[start:end], nil
+}
+
+func sortBookings(bookings []*types.Booking, filter db.BookingFilter) {
+	key := func(b *types.Booking) time.Time {
+		switch filter.SortBy {
+		case db.SortByFromDate:
+			return b.FromDate
+		case db.SortByTillDate:
+			return b.TillDate
+		}
+		return time.Time{}
+	}
+	sort.SliceStable(bookings, func(i, j int) bool {
+		if filter.SortDesc {
+			i, j = j, i
+		}
+		ki, kj := key(bookings[i]), key(bookings[j])
+		if !ki.Equal(kj) {
+			return ki.Before(kj)
+		}
+		return bytes.Compare(bookings[i].ID[:], bookings[j].ID[:]) < 0
+	})
+}
+
+func (s *BookingStore) InsertBooking(ctx context.Context, booking *types.Booking) (*types.Booking, error) {
+	bookings, err := s.InsertBookings(ctx, []*types.Booking{booking})
+	if err != nil {
+		return nil, err
+	}
+	return bookings[0], nil
+}
+
+func (s *BookingStore) InsertBookings(ctx context.Context, bookings []*types.Booking) ([]*types.Booking, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	claimed := map[roomNight]bool{}
+	for _, booking := range bookings {
+		booking.ID = primitive.NewObjectID()
+		if len(booking.Status) == 0 {
+			booking.SetStatus(types.BookingConfirmed, time.Now())
+		}
+		if !booking.Status.IsActive() {
+			continue
+		}
+		for _, night := range booking.Nights() {
+			key := roomNight{booking.RoomID, night}
+			if _, taken := s.nights[key]; taken || claimed[key] {
+				return nil, db.ErrRoomNotAvailable
+			}
+			claimed[key] = true
+		}
+	}
+
+	codes := map[string]bool{}
+	for _, booking := range bookings {
+		code, err := s.newConfirmationCode(codes)
+		if err != nil {
+			return nil, err
+		}
+		booking.ConfirmationCode = code
+	}
+
+	for _, booking := range bookings {
+		if _, err := s.coll.insert(booking); err != nil {
+			return nil, err
+		}
+		if booking.Status.IsActive() {
+			for _, night := range booking.Nights() {
+				s.nights[roomNight{booking.RoomID, night}] = booking.ID
+			}
+		}
+	}
+
+	return bookings, nil
+}
+
+// newConfirmationCode must be called with mu held.
+func (s *BookingStore) newConfirmationCode(drawn map[string]bool) (string, error) {
+	for attempt := 0; attempt < db.ConfirmationCodeAttempts; attempt++ {
+		code, err := types.NewConfirmationCode()
+		if err != nil {
+			return "", err
+		}
+		var existing *types.Booking
+		if drawn[code] || s.coll.findOne(bson.M{"confirmationCode": code}, &existing) == nil {
+			continue
+		}
+		drawn[code] = true
+		return code, nil
+	}
+	return "", db.ErrNoConfirmationCode
+}
+
+func (s *BookingStore) InsertHold(ctx context.Context, booking *types.Booking, expiresAt time.Time) (*types.Booking, error) {
+	booking.SetStatus(types.BookingPending, time.Now())
+	booking.ExpiresAt = expiresAt.UTC()
+	return s.InsertBooking(ctx, booking)
+}
+
+func (s *BookingStore) releaseNights(bookingID primitive.ObjectID) {
+	for night, id := range s.nights {
+		if id == bookingID {
+			delete(s.nights, night)
+		}
+	}
+}
@@ -0,0 +1,467 @@
+package memory
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/raphaelmb/go-hotel-reservation/db"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// collection keeps documents BSON encoded, so reads hand out copies and filters see
+// the types Mongo would. Only the operators the stores use are supported.
+type collection struct {
+	mu   sync.RWMutex
+	docs []bson.Raw
+}
+
+func (c *collection) insert(doc any) (primitive.ObjectID, error) {
+	m, err := toM(doc)
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+	oid, ok := m["_id"].(primitive.ObjectID)
+	if !ok || oid.IsZero() {
+		oid = primitive.NewObjectID()
+		m["_id"] = oid
+	}
+	raw, err := bson.Marshal(m)
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.docs = append(c.docs, raw)
+
+	return oid, nil
+}
+
+func (c *collection) findOne(filter any, result any) error {
+	var results []bson.Raw
+	if err := c.find(filter, 0, 1, &results); err != nil {
+		return err
+	}
+	if len(results) == 0 {
+		return db.ErrNotFound
+	}
+	return bson.Unmarshal(results[0], result)
+}
+
+func (c *collection) find(filter any, skip, limit int64, results any) error {
+	f, err := toM(filter)
+	if err != nil {
+		return err
+	}
+	sliceVal := reflect.ValueOf(results)
+	if sliceVal.Kind() != reflect.Pointer || sliceVal.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("results argument must be a pointer to a slice, got %T", results)
+	}
+	sliceVal = sliceVal.Elem()
+	elemType := sliceVal.Type().Elem()
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var skipped, found int64
+	for _, raw := range c.docs {
+		doc, err := toM(raw)
+		if err != nil {
+			return err
+		}
+		if !matches(doc, f) {
+			continue
+		}
+		if skipped < skip {
+			skipped++
+			continue
+		}
+		if limit > 0 && found >= limit {
+			break
+		}
+		found++
+
+		elem, err := decode(raw, elemType)
+		if err != nil {
+			return err
+		}
+		sliceVal.Set(reflect.Append(sliceVal, elem))
+	}
+	return nil
+}
+
+func (c *collection) update(filter any, update any, many bool) (int64, error) {
+	f, err := toM(filter)
+	if err != nil {
+		return 0, err
+	}
+	u, err := toM(update)
+	if err != nil {
+		return 0, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var matched int64
+	for i, raw := range c.docs {
+		doc, err := toM(raw)
+		if err != nil {
+			return matched, err
+		}
+		if !matches(doc, f) {
+			continue
+		}
+		matched++
+		if err := applyUpdate(doc, u); err != nil {
+			return matched, err
+		}
+		if c.docs[i], err = bson.Marshal(doc); err != nil {
+			return matched, err
+		}
+		if !many {
+			break
+		}
+	}
+	return matched, nil
+}
+
+func (c *collection) delete(filter any, many bool) (int64, error) {
+	f, err := toM(filter)
+	if err != nil {
+		return 0, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var (
+		deleted int64
+		kept    = c.docs[:0]
+	)
+	for _, raw := range c.docs {
+		doc, err := toM(raw)
+		if err != nil {
+			return deleted, err
+		}
+		if (many || deleted == 0) && matches(doc, f) {
+			deleted++
+			continue
+		}
+		kept = append(kept, raw)
+	}
+	c.docs = kept
+	return deleted, nil
+}
+
+func (c *collection) drop() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.docs = nil
+}
+
+func decode(raw bson.Raw, typ reflect.Type) (reflect.Value, error) {
+	if typ.Kind() == reflect.Pointer {
+		v := reflect.New(typ.Elem())
+		return v, bson.Unmarshal(raw, v.Interface())
+	}
+	v := reflect.New(typ)
+	return v.Elem(), bson.Unmarshal(raw, v.Interface())
+}
+
+func toM(v any) (bson.M, error) {
+	m := bson.M{}
+	if v == nil {
+		return m, nil
+	}
+	raw, ok := v.(bson.Raw)
+	if !ok {
+		b, err := bson.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		raw = b
+	}
+	if err := bson.Unmarshal(raw, &m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func matches(doc bson.M, filter bson.M) bool {
+	for key, want := range filter {
+		switch key {
+		case "$and":
+			for _, sub := range asArray(want) {
+				if !matches(doc, asM(sub)) {
+					return false
+				}
+			}
+		case "$or":
+			ok := false
+			for _, sub := range asArray(want) {
+				if matches(doc, asM(sub)) {
+					ok = true
+					break
+				}
+			}
+			if !ok {
+				return false
+			}
+		default:
+			got, exists := doc[key]
+			if ops, isOps := operators(want); isOps {
+				for op, arg := range ops {
+					if !matchOperator(op, got, exists, arg) {
+						return false
+					}
+				}
+			} else if !valueEquals(got, want) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func matchOperator(op string, got any, exists bool, arg any) bool {
+	switch op {
+	case "$eq":
+		return valueEquals(got, arg)
+	case "$ne":
+		return !valueEquals(got, arg)
+	case "$in":
+		for _, v := range asArray(arg) {
+			if valueEquals(got, v) {
+				return true
+			}
+		}
+		return false
+	case "$nin":
+		for _, v := range asArray(arg) {
+			if valueEquals(got, v) {
+				return false
+			}
+		}
+		return true
+	case "$exists":
+		want, _ := arg.(bool)
+		return exists == want
+	case "$not":
+		// like Mongo, documents missing the field match too
+		ops, ok := operators(arg)
+		if !ok {
+			return false
+		}
+		for op, arg := range ops {
+			if !matchOperator(op, got, exists, arg) {
+				return true
+			}
+		}
+		return false
+	case "$gt", "$gte", "$lt", "$lte":
+		if !exists {
+			return false
+		}
+		cmp, ok := compare(got, arg)
+		if !ok {
+			return false
+		}
+		switch op {
+		case "$gt":
+			return cmp > 0
+		case "$gte":
+			return cmp >= 0
+		case "$lt":
+			return cmp < 0
+		default:
+			return cmp <= 0
+		}
+	}
+	return false
+}
+
+// valueEquals matches an array field against a scalar if any element equals it.
+func valueEquals(got, want any) bool {
+	if re, ok := want.(primitive.Regex); ok {
+		return regexMatches(got, re)
+	}
+	if arr, ok := got.(primitive.A); ok {
+		if _, wantArr := want.(primitive.A); !wantArr {
+			for _, v := range arr {
+				if valueEquals(v, want) {
+					return true
+				}
+			}
+			return false
+		}
+	}
+	if cmp, ok := compare(got, want); ok {
+		return cmp == 0
+	}
+	return reflect.DeepEqual(got, want)
+}
+
+func regexMatches(got any, re primitive.Regex) bool {
+	s, ok := got.(string)
+	if !ok {
+		return false
+	}
+	pattern := re.Pattern
+	if strings.Contains(re.Options, "i") {
+		pattern = "(?i)" + pattern
+	}
+	matched, err := regexp.MatchString(pattern, s)
+	return err == nil && matched
+}
+
+func compare(a, b any) (int, bool) {
+	switch x := a.(type) {
+	case nil:
+		return 0, b == nil
+	case string:
+		y, ok := b.(string)
+		return strings.Compare(x, y), ok
+	case bool:
+		y, ok := b.(bool)
+		if !ok {
+			return 0, false
+		}
+		return boolToInt(x) - boolToInt(y), true
+	case primitive.ObjectID:
+		y, ok := b.(primitive.ObjectID)
+		return bytes.Compare(x[:], y[:]), ok
+	case primitive.DateTime:
+		y, ok := b.(primitive.DateTime)
+		if !ok {
+			return 0, false
+		}
+		return compareOrdered(x, y), true
+	}
+	x, ok := toFloat(a)
+	if !ok {
+		return 0, false
+	}
+	y, ok := toFloat(b)
+	if !ok {
+		return 0, false
+	}
+	return compareOrdered(x, y), true
+}
+
+func compareOrdered[T int64 | float64 | primitive.DateTime](a, b T) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func toFloat(v any) (float64, bool) {
+	switch n := v.(type) {
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func applyUpdate(doc bson.M, update bson.M) error {
+	for op, arg := range update {
+		fields := asM(arg)
+		switch op {
+		case "$set":
+			for k, v := range fields {
+				doc[k] = v
+			}
+		case "$unset":
+			for k := range fields {
+				delete(doc, k)
+			}
+		case "$inc":
+			for k, v := range fields {
+				cur, _ := toFloat(doc[k])
+				inc, ok := toFloat(v)
+				if !ok {
+					return fmt.Errorf("cannot $inc %s by non numeric value", k)
+				}
+				switch v.(type) {
+				case int32, int64:
+					doc[k] = int64(cur + inc)
+				default:
+					doc[k] = cur + inc
+				}
+			}
+		case "$push":
+			for k, v := range fields {
+				doc[k] = append(asArray(doc[k]), v)
+			}
+		case "$pull":
+			for k, v := range fields {
+				var kept primitive.A
+				for _, elem := range asArray(doc[k]) {
+					if !valueEquals(elem, v) {
+						kept = append(kept, elem)
+					}
+				}
+				if kept == nil {
+					kept = primitive.A{}
+				}
+				doc[k] = kept
+			}
+		default:
+			return fmt.Errorf("unsupported update operator %s", op)
+		}
+	}
+	return nil
+}
+
+func operators(v any) (bson.M, bool) {
+	m := asM(v)
+	if len(m) == 0 {
+		return nil, false
+	}
+	for k := range m {
+		if !strings.HasPrefix(k, "$") {
+			return nil, false
+		}
+	}
+	return m, true
+}
+
+func asM(v any) bson.M {
+	switch m := v.(type) {
+	case bson.M:
+		return m
+	case bson.D:
+		return m.Map()
+	}
+	return nil
+}
+
+func asArray(v any) primitive.A {
+	switch a := v.(type) {
+	case primitive.A:
+		return a
+	case []any:
+		return a
+	}
+	return nil
+}
@@ -0,0 +1,185 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/raphaelmb/go-hotel-reservation/db"
+	"github.com/raphaelmb/go-hotel-reservation/types"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type testDoc struct {
+	ID    primitive.ObjectID `bson:"_id,omitempty"`
+	Name  string             `bson:"name"`
+	Count int                `bson:"count"`
+	Price float64            `bson:"price"`
+	Tags  []string           `bson:"tags"`
+	At    time.Time          `bson:"at"`
+}
+
+func TestCollectionFind(t *testing.T) {
+	at := time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)
+	coll := &collection{}
+	for _, doc := range []testDoc{
+		{Name: "a", Count: 1, Tags: []string{"x"}, At: at},
+		{Name: "b", Count: 2, Tags: []string{"x", "y"}, At: at.Add(time.Hour)},
+		{Name: "c", Count: 3, At: at.Add(2 * time.Hour)},
+	} {
+		if _, err := coll.insert(doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		filter bson.M
+		want   []string
+	}{
+		{"equality", bson.M{"name": "b"}, []string{"b"}},
+		{"array element equality", bson.M{"tags": "x"}, []string{"a", "b"}},
+		{"$lt", bson.M{"count": bson.M{"$lt": 2}}, []string{"a"}},
+		{"$gte", bson.M{"count": bson.M{"$gte": 2}}, []string{"b", "c"}},
+		{"$lt and $gte", bson.M{"count": bson.M{"$gte": 2, "$lt": 3}}, []string{"b"}},
+		{"$gte on times", bson.M{"at": bson.M{"$gte": at.Add(time.Hour)}}, []string{"b", "c"}},
+		{"$not", bson.M{"count": bson.M{"$not": bson.M{"$gte": 2}}}, []string{"a"}},
+		{"$not on a missing field", bson.M{"missing": bson.M{"$not": bson.M{"$gte": 2}}}, []string{"a", "b", "c"}},
+		{"$in", bson.M{"name": bson.M{"$in": bson.A{"a", "c", "d"}}}, []string{"a", "c"}},
+		{"$in on arrays", bson.M{"tags": bson.M{"$in": bson.A{"y"}}}, []string{"b"}},
+		{"$in nothing", bson.M{"name": bson.M{"$in": bson.A{}}}, nil},
+		{"$or", bson.M{"$or": bson.A{bson.M{"name": "a"}, bson.M{"count": 3}}}, []string{"a", "c"}},
+		{"unsupported operator", bson.M{"count": bson.M{"$mod": bson.A{2, 0}}}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var docs []testDoc
+			if err := coll.find(tt.filter, 0, 0, &docs); err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			for _, doc := range docs {
+				names = append(names, doc.Name)
+			}
+			if len(names) != len(tt.want) {
+				t.Fatalf("expected %v but got %v", tt.want, names)
+			}
+			for i := range names {
+				if names[i] != tt.want[i] {
+					t.Fatalf("expected %v but got %v", tt.want, names)
+				}
+			}
+		})
+	}
+}
+
+func TestCollectionUpdate(t *testing.T) {
+	tests := []struct {
+		name    string
+		update  bson.M
+		want    testDoc
+		wantErr bool
+	}{
+		{"$inc an int", bson.M{"$inc": bson.M{"count": 2}}, testDoc{Name: "a", Count: 3, Price: 1.5}, false},
+		{"$inc by a negative value", bson.M{"$inc": bson.M{"count": -1}}, testDoc{Name: "a", Count: 0, Price: 1.5}, false},
+		{"$inc a float", bson.M{"$inc": bson.M{"price": 0.25}}, testDoc{Name: "a", Count: 1, Price: 1.75}, false},
+		{"$inc a non numeric value", bson.M{"$inc": bson.M{"count": "one"}}, testDoc{}, true},
+		{"$set", bson.M{"$set": bson.M{"name": "b", "count": 5}}, testDoc{Name: "b", Count: 5, Price: 1.5}, false},
+		{"$set and $inc", bson.M{"$set": bson.M{"name": "b"}, "$inc": bson.M{"count": 1}}, testDoc{Name: "b", Count: 2, Price: 1.5}, false},
+		{"unsupported operator", bson.M{"$rename": bson.M{"name": "title"}}, testDoc{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			coll := &collection{}
+			oid, err := coll.insert(testDoc{Name: "a", Count: 1, Price: 1.5})
+			if err != nil {
+				t.Fatal(err)
+			}
+			matched, err := coll.update(bson.M{"_id": oid}, tt.update, false)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if matched != 1 {
+				t.Fatalf("expected 1 matched document but got %d", matched)
+			}
+			var got testDoc
+			if err := coll.findOne(bson.M{"_id": oid}, &got); err != nil {
+				t.Fatal(err)
+			}
+			tt.want.ID = oid
+			if got.ID != tt.want.ID || got.Name != tt.want.Name || got.Count != tt.want.Count || got.Price != tt.want.Price {
+				t.Fatalf("expected %+v but got %+v", tt.want, got)
+			}
+		})
+	}
+
+	t.Run("should only update the first match unless many", func(t *testing.T) {
+		coll := &collection{}
+		for i := 0; i < 3; i++ {
+			if _, err := coll.insert(testDoc{Name: "a"}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for _, many := range []bool{false, true} {
+			if _, err := coll.update(bson.M{"name": "a"}, bson.M{"$inc": bson.M{"count": 1}}, many); err != nil {
+				t.Fatal(err)
+			}
+		}
+		var docs []testDoc
+		if err := coll.find(bson.M{"count": 2}, 0, 0, &docs); err != nil {
+			t.Fatal(err)
+		}
+		if len(docs) != 1 {
+			t.Fatalf("expected a single document updated twice but got %d", len(docs))
+		}
+	})
+}
+
+func TestUniqueFields(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name    string
+		insert  func() error
+		wantErr error
+	}{
+		{"user emails", func() func() error {
+			store := NewUserStore()
+			return func() error {
+				_, err := store.InsertUser(ctx, &types.User{Email: "james@foo.com"})
+				return err
+			}
+		}(), db.ErrEmailExists},
+		{"promo codes", func() func() error {
+			store := NewPromoCodeStore()
+			return func() error {
+				_, err := store.InsertPromoCode(ctx, &types.PromoCode{Code: "SUMMER"})
+				return err
+			}
+		}(), db.ErrPromoCodeExists},
+		{"reservation confirmation codes", func() func() error {
+			store := NewReservationStore()
+			return func() error {
+				_, err := store.InsertReservation(ctx, &types.Reservation{ConfirmationCode: "ABC123"})
+				return err
+			}
+		}(), db.ErrConfirmationCodeExists},
+	}
+	for _, tt := range tests {
+		t.Run("should reject duplicate "+tt.name, func(t *testing.T) {
+			if err := tt.insert(); err != nil {
+				t.Fatal(err)
+			}
+			if err := tt.insert(); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected %v but got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
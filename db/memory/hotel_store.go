@@ -0,0 +1,102 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/raphaelmb/go-hotel-reservation/db"
+	"github.com/raphaelmb/go-hotel-reservation/types"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type HotelStore struct {
+	coll *collection
+}
+
+func NewHotelStore() *HotelStore {
+	return &HotelStore{
+		coll: &collection{},
+	}
+}
+
+func (s *HotelStore) Drop(ctx context.Context) error {
+	s.coll.drop()
+	return nil
+}
+
+func (s *HotelStore) Update(ctx context.Context, id string, params types.UpdateHotelParams) error {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+	matched, err := s.coll.update(bson.M{"_id": oid}, bson.M{"$set": params.ToBSON()}, false)
+	if err != nil {
+		return err
+	}
+	if matched == 0 {
+		return db.ErrNotFound
+	}
+	return nil
+}
+
+func (s *HotelStore) Delete(ctx context.Context, id string) error {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+	deleted, err := s.coll.delete(bson.M{"_id": oid}, false)
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return db.ErrNotFound
+	}
+	return nil
+}
+
+func (s *HotelStore) AddRoom(ctx context.Context, hotelID, roomID primitive.ObjectID) error {
+	_, err := s.coll.update(bson.M{"_id": hotelID}, bson.M{"$push": bson.M{"rooms": roomID}}, false)
+	return err
+}
+
+func (s *HotelStore) RemoveRoom(ctx context.Context, hotelID, roomID primitive.ObjectID) error {
+	_, err := s.coll.update(bson.M{"_id": hotelID}, bson.M{"$pull": bson.M{"rooms": roomID}}, false)
+	return err
+}
+
+func (s *HotelStore) Insert(ctx context.Context, hotel *types.Hotel) (*types.Hotel, error) {
+	oid, err := s.coll.insert(hotel)
+	if err != nil {
+		return nil, err
+	}
+	hotel.ID = oid
+
+	return hotel, nil
+}
+
+func (s *HotelStore) GetHotels(ctx context.Context, filter db.HotelFilter, pag *db.Pagination) ([]*types.Hotel, error) {
+	var hotels []*types.Hotel
+	if err := s.coll.find(filter.ToBSON(), skip(pag), pag.Limit, &hotels); err != nil {
+		return nil, err
+	}
+	return hotels, nil
+}
+
+func (s *HotelStore) GetHotelByID(ctx context.Context, id string) (*types.Hotel, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+	var hotel *types.Hotel
+	if err := s.coll.findOne(bson.M{"_id": oid}, &hotel); err != nil {
+		return nil, err
+	}
+	return hotel, nil
+}
+
+func skip(pag *db.Pagination) int64 {
+	if pag.Page < 1 {
+		return 0
+	}
+	return (pag.Page - 1) * pag.Limit
+}
@@ -0,0 +1,105 @@
+package memory
+
+import (
+	"context"
+	"sync"
+
+	"github.com/raphaelmb/go-hotel-reservation/db"
+	"github.com/raphaelmb/go-hotel-reservation/types"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type PromoCodeStore struct {
+	coll *collection
+
+	// mu serializes the checks of inserts and redemptions
+	mu sync.Mutex
+}
+
+func NewPromoCodeStore() *PromoCodeStore {
+	return &PromoCodeStore{
+		coll: &collection{},
+	}
+}
+
+func (s *PromoCodeStore) Drop(ctx context.Context) error {
+	s.coll.drop()
+	return nil
+}
+
+func (s *PromoCodeStore) InsertPromoCode(ctx context.Context, promo *types.PromoCode) (*types.PromoCode, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.GetPromoCodeByCode(ctx, promo.Code); err == nil {
+		return nil, db.ErrPromoCodeExists
+	}
+	oid, err := s.coll.insert(promo)
+	if err != nil {
+		return nil, err
+	}
+	promo.ID = oid
+	return promo, nil
+}
+
+func (s *PromoCodeStore) GetPromoCodes(ctx context.Context) ([]*types.PromoCode, error) {
+	var promos []*types.PromoCode
+	if err := s.coll.find(bson.M{}, 0, 0, &promos); err != nil {
+		return nil, err
+	}
+	return promos, nil
+}
+
+func (s *PromoCodeStore) GetPromoCodeByCode(ctx context.Context, code string) (*types.PromoCode, error) {
+	var promo *types.PromoCode
+	if err := s.coll.findOne(bson.M{"code": types.NormalizePromoCode(code)}, &promo); err != nil {
+		return nil, err
+	}
+	return promo, nil
+}
+
+func (s *PromoCodeStore) DeletePromoCode(ctx context.Context, code string) error {
+	deleted, err := s.coll.delete(bson.M{"code": types.NormalizePromoCode(code)}, false)
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return db.ErrNotFound
+	}
+	return nil
+}
+
+func (s *PromoCodeStore) RedeemPromoCode(ctx context.Context, code string, userID primitive.ObjectID) error {
+	return s.addUse(ctx, code, userID, 1)
+}
+
+func (s *PromoCodeStore) ReleasePromoCode(ctx context.Context, code string, userID primitive.ObjectID) error {
+	return s.addUse(ctx, code, userID, -1)
+}
+
+func (s *PromoCodeStore) addUse(ctx context.Context, code string, userID primitive.ObjectID, n int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	promo, err := s.GetPromoCodeByCode(ctx, code)
+	if err != nil {
+		return err
+	}
+	uses := promo.Redemptions[userID.Hex()]
+	if n > 0 {
+		if err := db.RedemptionError(promo, uses); err != nil {
+			return err
+		}
+	} else if uses == 0 {
+		return nil
+	}
+
+	if promo.Redemptions == nil {
+		promo.Redemptions = map[string]int{}
+	}
+	promo.Redemptions[userID.Hex()] = uses + n
+	update := bson.M{"$set": bson.M{"uses": promo.Uses + n, "redemptions": promo.Redemptions}}
+	_, err = s.coll.update(bson.M{"_id": promo.ID}, update, false)
+	return err
+}
@@ -0,0 +1,75 @@
+package memory
+
+import (
+	"context"
+	"sync"
+
+	"github.com/raphaelmb/go-hotel-reservation/db"
+	"github.com/raphaelmb/go-hotel-reservation/types"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type ReservationStore struct {
+	coll *collection
+
+	// mu serializes the checks of inserts and status updates
+	mu sync.Mutex
+}
+
+func NewReservationStore() *ReservationStore {
+	return &ReservationStore{
+		coll: &collection{},
+	}
+}
+
+func (s *ReservationStore) Drop(ctx context.Context) error {
+	s.coll.drop()
+	return nil
+}
+
+func (s *ReservationStore) InsertReservation(ctx context.Context, reservation *types.Reservation) (*types.Reservation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var existing *types.Reservation
+	if err := s.coll.findOne(bson.M{"confirmationCode": reservation.ConfirmationCode}, &existing); err == nil {
+		return nil, db.ErrConfirmationCodeExists
+	}
+	oid, err := s.coll.insert(reservation)
+	if err != nil {
+		return nil, err
+	}
+	reservation.ID = oid
+	return reservation, nil
+}
+
+func (s *ReservationStore) GetReservationByID(ctx context.Context, id string) (*types.Reservation, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+	var reservation *types.Reservation
+	if err := s.coll.findOne(bson.M{"_id": oid}, &reservation); err != nil {
+		return nil, err
+	}
+	return reservation, nil
+}
+
+func (s *ReservationStore) UpdateReservationStatus(ctx context.Context, id string, from, to types.ReservationStatus, cancellation *types.Cancellation) (*types.Reservation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	reservation, err := s.GetReservationByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if reservation.Status != from {
+		return nil, db.ErrReservationStatusChanged
+	}
+	update := bson.M{"$set": bson.M{"status": to, "cancellation": cancellation}}
+	if _, err := s.coll.update(bson.M{"_id": reservation.ID}, update, false); err != nil {
+		return nil, err
+	}
+	return s.GetReservationByID(ctx, id)
+}
@@ -0,0 +1,93 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/raphaelmb/go-hotel-reservation/db"
+	"github.com/raphaelmb/go-hotel-reservation/types"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type RoomStore struct {
+	coll *collection
+
+	db.HotelStore
+}
+
+func NewRoomStore(hotelStore db.HotelStore) *RoomStore {
+	return &RoomStore{
+		coll:       &collection{},
+		HotelStore: hotelStore,
+	}
+}
+
+func (s *RoomStore) Drop(ctx context.Context) error {
+	s.coll.drop()
+	return nil
+}
+
+func (s *RoomStore) InsertRoom(ctx context.Context, room *types.Room) (*types.Room, error) {
+	oid, err := s.coll.insert(room)
+	if err != nil {
+		return nil, err
+	}
+	room.ID = oid
+
+	if err := s.HotelStore.AddRoom(ctx, room.HotelID, room.ID); err != nil {
+		return nil, err
+	}
+
+	return room, nil
+}
+
+func (s *RoomStore) UpdateRoom(ctx context.Context, id string, params types.UpdateRoomParams) error {
+	room, err := s.GetRoomByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	update := params.ToBSON()
+	if _, err := s.coll.update(bson.M{"_id": room.ID}, bson.M{"$set": update}, false); err != nil {
+		return err
+	}
+
+	hotelID, moved := update["hotelID"].(primitive.ObjectID)
+	if !moved || hotelID == room.HotelID {
+		return nil
+	}
+	if err := s.HotelStore.RemoveRoom(ctx, room.HotelID, room.ID); err != nil {
+		return err
+	}
+	return s.HotelStore.AddRoom(ctx, hotelID, room.ID)
+}
+
+func (s *RoomStore) DeleteRoom(ctx context.Context, id string) error {
+	room, err := s.GetRoomByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if _, err := s.coll.delete(bson.M{"_id": room.ID}, false); err != nil {
+		return err
+	}
+	return s.HotelStore.RemoveRoom(ctx, room.HotelID, room.ID)
+}
+
+func (s *RoomStore) GetRooms(ctx context.Context, filter db.RoomFilter) ([]*types.Room, error) {
+	var rooms []*types.Room
+	if err := s.coll.find(filter.ToBSON(), 0, 0, &rooms); err != nil {
+		return nil, err
+	}
+	return rooms, nil
+}
+
+func (s *RoomStore) GetRoomByID(ctx context.Context, id string) (*types.Room, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+	var room *types.Room
+	if err := s.coll.findOne(bson.M{"_id": oid}, &room); err != nil {
+		return nil, err
+	}
+	return room, nil
+}
@@ -0,0 +1,18 @@
+// Package memory implements the db stores in memory, to run without a Mongo server.
+package memory
+
+import "github.com/raphaelmb/go-hotel-reservation/db"
+
+func NewStore() *db.Store {
+	hotelStore := NewHotelStore()
+	return &db.Store{
+		User:        NewUserStore(),
+		Hotel:       hotelStore,
+		Room:        NewRoomStore(hotelStore),
+		Booking:     NewBookingStore(),
+		Promo:       NewPromoCodeStore(),
+		Reservation: NewReservationStore(),
+		Waitlist:    NewWaitlistStore(),
+		Token:       NewTokenStore(),
+	}
+}
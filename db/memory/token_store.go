@@ -0,0 +1,77 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/raphaelmb/go-hotel-reservation/db"
+	"github.com/raphaelmb/go-hotel-reservation/types"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type TokenStore struct {
+	coll *collection
+}
+
+func NewTokenStore() *TokenStore {
+	return &TokenStore{
+		coll: &collection{},
+	}
+}
+
+func (s *TokenStore) Drop(ctx context.Context) error {
+	s.coll.drop()
+	return nil
+}
+
+func (s *TokenStore) InsertRefreshToken(ctx context.Context, token *types.RefreshToken) (*types.RefreshToken, error) {
+	oid, err := s.coll.insert(token)
+	if err != nil {
+		return nil, err
+	}
+	token.ID = oid
+	return token, nil
+}
+
+func (s *TokenStore) GetRefreshTokenByHash(ctx context.Context, hash string) (*types.RefreshToken, error) {
+	var token *types.RefreshToken
+	if err := s.coll.findOne(bson.M{"hash": hash}, &token); err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+func (s *TokenStore) UseRefreshToken(ctx context.Context, id primitive.ObjectID, at time.Time) error {
+	filter := bson.M{"_id": id, "usedAt": bson.M{"$exists": false}, "revokedAt": bson.M{"$exists": false}}
+	matched, err := s.coll.update(filter, bson.M{"$set": bson.M{"usedAt": at}}, false)
+	if err != nil {
+		return err
+	}
+	if matched == 0 {
+		return db.ErrRefreshTokenUsed
+	}
+	return nil
+}
+
+func (s *TokenStore) RevokeTokenFamily(ctx context.Context, family primitive.ObjectID, at time.Time) error {
+	return s.revoke(bson.M{"family": family}, at)
+}
+
+func (s *TokenStore) RevokeUserTokens(ctx context.Context, userID primitive.ObjectID, at time.Time) error {
+	return s.revoke(bson.M{"userId": userID}, at)
+}
+
+func (s *TokenStore) revoke(filter bson.M, at time.Time) error {
+	filter["revokedAt"] = bson.M{"$exists": false}
+	_, err := s.coll.update(filter, bson.M{"$set": bson.M{"revokedAt": at}}, true)
+	return err
+}
+
+func (s *TokenStore) IsTokenFamilyRevoked(ctx context.Context, family primitive.ObjectID) (bool, error) {
+	var tokens []*types.RefreshToken
+	if err := s.coll.find(bson.M{"family": family, "revokedAt": bson.M{"$exists": true}}, 0, 1, &tokens); err != nil {
+		return false, err
+	}
+	return len(tokens) > 0, nil
+}
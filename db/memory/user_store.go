@@ -0,0 +1,123 @@
+package memory
+
+import (
+	"context"
+	"sync"
+
+	"github.com/raphaelmb/go-hotel-reservation/db"
+	"github.com/raphaelmb/go-hotel-reservation/types"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type UserStore struct {
+	coll *collection
+
+	mu sync.Mutex
+}
+
+func NewUserStore() *UserStore {
+	return &UserStore{
+		coll: &collection{},
+	}
+}
+
+func (s *UserStore) Drop(ctx context.Context) error {
+	s.coll.drop()
+	return nil
+}
+
+func (s *UserStore) UpdateUser(ctx context.Context, id string, params types.UpdateUserParams) error {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+	update := bson.M{"$set": params.ToBSON()}
+	_, err = s.coll.update(bson.M{"_id": oid}, update, false)
+	return err
+}
+
+func (s *UserStore) UpdateUserPassword(ctx context.Context, id string, encryptedPassword string) error {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+	matched, err := s.coll.update(bson.M{"_id": oid}, bson.M{"$set": bson.M{"encryptedPassword": encryptedPassword}}, false)
+	if err != nil {
+		return err
+	}
+	if matched == 0 {
+		return db.ErrNotFound
+	}
+	return nil
+}
+
+func (s *UserStore) UpdateUserRole(ctx context.Context, id string, role types.Role, hotelIDs []primitive.ObjectID) error {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+	update := bson.M{"$set": bson.M{"role": role, "hotelIDs": hotelIDs, "isAdmin": role == types.RoleAdmin}}
+	matched, err := s.coll.update(bson.M{"_id": oid}, update, false)
+	if err != nil {
+		return err
+	}
+	if matched == 0 {
+		return db.ErrNotFound
+	}
+	return nil
+}
+
+func (s *UserStore) DeleteUser(ctx context.Context, id string) error {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+	_, err = s.coll.delete(bson.M{"_id": oid}, false)
+	return err
+}
+
+func (s *UserStore) InsertUser(ctx context.Context, user *types.User) (*types.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.GetUserByEmail(ctx, user.Email); err == nil {
+		return nil, db.ErrEmailExists
+	}
+	oid, err := s.coll.insert(user)
+	if err != nil {
+		return nil, err
+	}
+	user.ID = oid
+
+	return user, nil
+}
+
+func (s *UserStore) GetUserByID(ctx context.Context, id string) (*types.User, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+
+	var user types.User
+	if err := s.coll.findOne(bson.M{"_id": oid}, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (s *UserStore) GetUsers(ctx context.Context) ([]*types.User, error) {
+	var users []*types.User
+	if err := s.coll.find(bson.M{}, 0, 0, &users); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+func (s *UserStore) GetUserByEmail(ctx context.Context, email string) (*types.User, error) {
+	var user *types.User
+	if err := s.coll.findOne(bson.M{"email": email}, &user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
@@ -0,0 +1,79 @@
+package memory
+
+import (
+	"context"
+	"sync"
+
+	"github.com/raphaelmb/go-hotel-reservation/db"
+	"github.com/raphaelmb/go-hotel-reservation/types"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type WaitlistStore struct {
+	coll *collection
+
+	// mu serializes the checks of status updates
+	mu sync.Mutex
+}
+
+func NewWaitlistStore() *WaitlistStore {
+	return &WaitlistStore{
+		coll: &collection{},
+	}
+}
+
+func (s *WaitlistStore) Drop(ctx context.Context) error {
+	s.coll.drop()
+	return nil
+}
+
+func (s *WaitlistStore) InsertWaitlistEntry(ctx context.Context, entry *types.WaitlistEntry) (*types.WaitlistEntry, error) {
+	oid, err := s.coll.insert(entry)
+	if err != nil {
+		return nil, err
+	}
+	entry.ID = oid
+	return entry, nil
+}
+
+func (s *WaitlistStore) GetWaitlistEntryByID(ctx context.Context, id string) (*types.WaitlistEntry, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+	var entry *types.WaitlistEntry
+	if err := s.coll.findOne(bson.M{"_id": oid}, &entry); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+func (s *WaitlistStore) GetWaitlistEntries(ctx context.Context, filter db.WaitlistFilter) ([]*types.WaitlistEntry, error) {
+	var entries []*types.WaitlistEntry
+	if err := s.coll.find(filter.ToBSON(), 0, 0, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+func (s *WaitlistStore) UpdateWaitlistStatus(ctx context.Context, id string, from, to types.WaitlistStatus, holdID primitive.ObjectID) (*types.WaitlistEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, err := s.GetWaitlistEntryByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if entry.Status != from {
+		return nil, db.ErrWaitlistStatusChanged
+	}
+	set := bson.M{"status": to}
+	if !holdID.IsZero() {
+		set["holdID"] = holdID
+	}
+	if _, err := s.coll.update(bson.M{"_id": entry.ID}, bson.M{"$set": set}, false); err != nil {
+		return nil, err
+	}
+	return s.GetWaitlistEntryByID(ctx, id)
+}
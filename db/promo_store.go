@@ -0,0 +1,154 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"os"
+
+	"github.com/raphaelmb/go-hotel-reservation/types"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrPromoCodeExists    = errors.New("promo code already exists")
+	ErrPromoCodeExhausted = errors.New("promo code has been used up")
+	ErrPromoCodeUserLimit = errors.New("promo code has been used too many times by the user")
+)
+
+type PromoCodeStore interface {
+	InsertPromoCode(context.Context, *types.PromoCode) (*types.PromoCode, error)
+	GetPromoCodes(context.Context) ([]*types.PromoCode, error)
+	GetPromoCodeByCode(context.Context, string) (*types.PromoCode, error)
+	DeletePromoCode(context.Context, string) error
+	// RedeemPromoCode atomically counts a use of the code by the user within its limits.
+	RedeemPromoCode(ctx context.Context, code string, userID primitive.ObjectID) error
+	ReleasePromoCode(ctx context.Context, code string, userID primitive.ObjectID) error
+}
+
+func RedemptionError(promo *types.PromoCode, uses int) error {
+	if promo.MaxUses > 0 && promo.Uses >= promo.MaxUses {
+		return ErrPromoCodeExhausted
+	}
+	if promo.MaxUsesPerUser > 0 && uses >= promo.MaxUsesPerUser {
+		return ErrPromoCodeUserLimit
+	}
+	return nil
+}
+
+type MongoPromoCodeStore struct {
+	client *mongo.Client
+	coll   *mongo.Collection
+
+	indexes indexes
+}
+
+func NewMongoPromoCodeStore(client *mongo.Client) *MongoPromoCodeStore {
+	dbName := os.Getenv(MongoDBNameEnvName)
+	return &MongoPromoCodeStore{
+		client: client,
+		coll:   client.Database(dbName).Collection("promo_codes"),
+	}
+}
+
+func (s *MongoPromoCodeStore) ensureIndexes(ctx context.Context) error {
+	return s.indexes.ensure(ctx, func(ctx context.Context) error {
+		_, err := s.coll.Indexes().CreateOne(ctx, mongo.IndexModel{
+			Keys:    bson.D{{Key: "code", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		})
+		return err
+	})
+}
+
+func (s *MongoPromoCodeStore) InsertPromoCode(ctx context.Context, promo *types.PromoCode) (*types.PromoCode, error) {
+	if err := s.ensureIndexes(ctx); err != nil {
+		return nil, err
+	}
+	res, err := s.coll.InsertOne(ctx, promo)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, ErrPromoCodeExists
+		}
+		return nil, err
+	}
+	promo.ID = res.InsertedID.(primitive.ObjectID)
+	return promo, nil
+}
+
+func (s *MongoPromoCodeStore) GetPromoCodes(ctx context.Context) ([]*types.PromoCode, error) {
+	curr, err := s.coll.Find(ctx, bson.M{}, options.Find().SetSort(bson.M{"code": 1}))
+	if err != nil {
+		return nil, err
+	}
+	var promos []*types.PromoCode
+	if err := curr.All(ctx, &promos); err != nil {
+		return nil, err
+	}
+	return promos, nil
+}
+
+func (s *MongoPromoCodeStore) GetPromoCodeByCode(ctx context.Context, code string) (*types.PromoCode, error) {
+	var promo *types.PromoCode
+	if err := s.coll.FindOne(ctx, bson.M{"code": types.NormalizePromoCode(code)}).Decode(&promo); err != nil {
+		return nil, notFound(err)
+	}
+	return promo, nil
+}
+
+func (s *MongoPromoCodeStore) DeletePromoCode(ctx context.Context, code string) error {
+	res, err := s.coll.DeleteOne(ctx, bson.M{"code": types.NormalizePromoCode(code)})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *MongoPromoCodeStore) RedeemPromoCode(ctx context.Context, code string, userID primitive.ObjectID) error {
+	promo, err := s.GetPromoCodeByCode(ctx, code)
+	if err != nil {
+		return err
+	}
+
+	// the limits in the filter make the increment atomic
+	var (
+		userUses = "redemptions." + userID.Hex()
+		filter   = bson.M{"_id": promo.ID}
+		update   = bson.M{"$inc": bson.M{"uses": 1, userUses: 1}}
+	)
+	if promo.MaxUses > 0 {
+		filter["uses"] = bson.M{"$lt": promo.MaxUses}
+	}
+	if promo.MaxUsesPerUser > 0 {
+		filter[userUses] = bson.M{"$not": bson.M{"$gte": promo.MaxUsesPerUser}}
+	}
+	res, err := s.coll.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount > 0 {
+		return nil
+	}
+
+	if promo, err = s.GetPromoCodeByCode(ctx, code); err != nil {
+		return err
+	}
+	if err := RedemptionError(promo, promo.Redemptions[userID.Hex()]); err != nil {
+		return err
+	}
+	return ErrPromoCodeExhausted
+}
+
+func (s *MongoPromoCodeStore) ReleasePromoCode(ctx context.Context, code string, userID primitive.ObjectID) error {
+	userUses := "redemptions." + userID.Hex()
+	_, err := s.coll.UpdateOne(ctx,
+		bson.M{"code": types.NormalizePromoCode(code), "uses": bson.M{"$gt": 0}, userUses: bson.M{"$gt": 0}},
+		bson.M{"$inc": bson.M{"uses": -1, userUses: -1}},
+	)
+	return err
+}
@@ -0,0 +1,122 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"os"
+
+	"github.com/raphaelmb/go-hotel-reservation/types"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// error codes of mongo dropping an index which, or whose collection, does not exist
+const (
+	indexNotFoundCode     = 27
+	namespaceNotFoundCode = 26
+)
+
+var ErrConfirmationCodeExists = errors.New("confirmation code already exists")
+
+var ErrReservationStatusChanged = errors.New("reservation status changed")
+
+type ReservationStore interface {
+	InsertReservation(context.Context, *types.Reservation) (*types.Reservation, error)
+	GetReservationByID(context.Context, string) (*types.Reservation, error)
+	// UpdateReservationStatus returns ErrReservationStatusChanged unless the reservation
+	// is in the from status.
+	UpdateReservationStatus(ctx context.Context, id string, from, to types.ReservationStatus, cancellation *types.Cancellation) (*types.Reservation, error)
+}
+
+type MongoReservationStore struct {
+	client *mongo.Client
+	coll   *mongo.Collection
+
+	indexes indexes
+}
+
+func NewMongoReservationStore(client *mongo.Client) *MongoReservationStore {
+	dbName := os.Getenv(MongoDBNameEnvName)
+	return &MongoReservationStore{
+		client: client,
+		coll:   client.Database(dbName).Collection("reservations"),
+	}
+}
+
+func (s *MongoReservationStore) ensureIndexes(ctx context.Context) error {
+	return s.indexes.ensure(ctx, func(ctx context.Context) error {
+		_, err := s.coll.Indexes().CreateOne(ctx, mongo.IndexModel{
+			Keys:    bson.D{{Key: "confirmationCode", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		})
+		return err
+	})
+}
+
+func (s *MongoReservationStore) MigrateConfirmationCodes(ctx context.Context) error {
+	filter := bson.M{"confirmationNumber": bson.M{"$exists": true}}
+	if _, err := s.coll.UpdateMany(ctx, filter, bson.M{"$rename": bson.M{"confirmationNumber": "confirmationCode"}}); err != nil {
+		return err
+	}
+	// the unique index of the former name would take every reservation for one without it
+	_, err := s.coll.Indexes().DropOne(ctx, "confirmationNumber_1")
+	var cmdErr mongo.CommandError
+	if errors.As(err, &cmdErr) && (cmdErr.Code == indexNotFoundCode || cmdErr.Code == namespaceNotFoundCode) {
+		return nil
+	}
+	return err
+}
+
+func (s *MongoReservationStore) InsertReservation(ctx context.Context, reservation *types.Reservation) (*types.Reservation, error) {
+	if err := s.ensureIndexes(ctx); err != nil {
+		return nil, err
+	}
+	if reservation.ID.IsZero() {
+		reservation.ID = primitive.NewObjectID()
+	}
+	if _, err := s.coll.InsertOne(ctx, reservation); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, ErrConfirmationCodeExists
+		}
+		return nil, err
+	}
+	return reservation, nil
+}
+
+func (s *MongoReservationStore) GetReservationByID(ctx context.Context, id string) (*types.Reservation, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+	var reservation *types.Reservation
+	if err := s.coll.FindOne(ctx, bson.M{"_id": oid}).Decode(&reservation); err != nil {
+		return nil, notFound(err)
+	}
+	return reservation, nil
+}
+
+func (s *MongoReservationStore) UpdateReservationStatus(ctx context.Context, id string, from, to types.ReservationStatus, cancellation *types.Cancellation) (*types.Reservation, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		update = bson.M{"$set": bson.M{"status": to, "cancellation": cancellation}}
+		opts   = options.FindOneAndUpdate().SetReturnDocument(options.After)
+	)
+	var reservation *types.Reservation
+	err = s.coll.FindOneAndUpdate(ctx, bson.M{"_id": oid, "status": from}, update, opts).Decode(&reservation)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		// tell a missing reservation from one in another status
+		if _, err := s.GetReservationByID(ctx, id); err != nil {
+			return nil, err
+		}
+		return nil, ErrReservationStatusChanged
+	}
+	if err != nil {
+		return nil, err
+	}
+	return reservation, nil
+}
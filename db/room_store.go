@@ -12,7 +12,11 @@ import (
 
 type RoomStore interface {
 	InsertRoom(context.Context, *types.Room) (*types.Room, error)
-	GetRooms(context.Context, bson.M) ([]*types.Room, error)
+	GetRooms(context.Context, RoomFilter) ([]*types.Room, error)
+	GetRoomByID(context.Context, string) (*types.Room, error)
+	// UpdateRoom moves the room between hotels when params.HotelID is set.
+	UpdateRoom(context.Context, string, types.UpdateRoomParams) error
+	DeleteRoom(context.Context, string) error
 }
 
 type MongoRoomStore struct {
@@ -38,17 +42,49 @@ func (s *MongoRoomStore) InsertRoom(ctx context.Context, room *types.Room) (*typ
 	}
 	room.ID = res.InsertedID.(primitive.ObjectID)
 
-	filter := Map{"_id": room.HotelID}
-	update := Map{"$push": bson.M{"rooms": room.ID}}
-	if err := s.HotelStore.Update(ctx, filter, update); err != nil {
+	if err := s.HotelStore.AddRoom(ctx, room.HotelID, room.ID); err != nil {
 		return nil, err
 	}
 
 	return room, nil
 }
 
-func (s *MongoRoomStore) GetRooms(ctx context.Context, filter bson.M) ([]*types.Room, error) {
-	resp, err := s.coll.Find(ctx, filter)
+func (s *MongoRoomStore) UpdateRoom(ctx context.Context, id string, params types.UpdateRoomParams) error {
+	room, err := s.GetRoomByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	update := params.ToBSON()
+	if len(update) == 0 {
+		return nil
+	}
+	if _, err := s.coll.UpdateByID(ctx, room.ID, bson.M{"$set": update}); err != nil {
+		return err
+	}
+
+	hotelID, moved := update["hotelID"].(primitive.ObjectID)
+	if !moved || hotelID == room.HotelID {
+		return nil
+	}
+	if err := s.HotelStore.RemoveRoom(ctx, room.HotelID, room.ID); err != nil {
+		return err
+	}
+	return s.HotelStore.AddRoom(ctx, hotelID, room.ID)
+}
+
+func (s *MongoRoomStore) DeleteRoom(ctx context.Context, id string) error {
+	room, err := s.GetRoomByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if _, err := s.coll.DeleteOne(ctx, bson.M{"_id": room.ID}); err != nil {
+		return err
+	}
+	return s.HotelStore.RemoveRoom(ctx, room.HotelID, room.ID)
+}
+
+func (s *MongoRoomStore) GetRooms(ctx context.Context, filter RoomFilter) ([]*types.Room, error) {
+	resp, err := s.coll.Find(ctx, filter.ToBSON())
 	if err != nil {
 		return nil, err
 	}
@@ -60,3 +96,15 @@ func (s *MongoRoomStore) GetRooms(ctx context.Context, filter bson.M) ([]*types.
 
 	return rooms, nil
 }
+
+func (s *MongoRoomStore) GetRoomByID(ctx context.Context, id string) (*types.Room, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+	var room *types.Room
+	if err := s.coll.FindOne(ctx, bson.M{"_id": oid}).Decode(&room); err != nil {
+		return nil, notFound(err)
+	}
+	return room, nil
+}
@@ -0,0 +1,273 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/raphaelmb/go-hotel-reservation/db"
+	"github.com/raphaelmb/go-hotel-reservation/types"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const bookingColumns = "id, user_id, room_id, hotel_id, num_persons, from_date, till_date, status, status_history, expires_at, price, cancellation_policy, cancellation, modifications, reservation_id, confirmation_code"
+
+type BookingStore struct {
+	conn *sql.DB
+}
+
+func NewBookingStore(conn *sql.DB) *BookingStore {
+	return &BookingStore{
+		conn: conn,
+	}
+}
+
+func (s *BookingStore) Drop(ctx context.Context) error {
+	return dropTables(ctx, s.conn, "room_nights", "bookings")
+}
+
+func (s *BookingStore) UpdateBookingStatus(ctx context.Context, id string, status types.BookingStatus) (*types.Booking, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+	return s.updateStatus(ctx, oid, status, func(*types.Booking) error { return nil })
+}
+
+func (s *BookingStore) CancelBooking(ctx context.Context, booking *types.Booking, cancellation *types.Cancellation) (*types.Booking, error) {
+	return s.updateStatus(ctx, booking.ID, types.BookingCancelled, func(current *types.Booking) error {
+		if current.Status != booking.Status {
+			return &db.TransitionError{From: current.Status, To: types.BookingCancelled}
+		}
+		current.Cancellation = cancellation
+		return nil
+	})
+}
+
+// ModifyBooking leaves the old nights held if a new one is taken.
+func (s *BookingStore) ModifyBooking(ctx context.Context, booking *types.Booking) (*types.Booking, error) {
+	err := withTx(ctx, s.conn, func(tx *sql.Tx) error {
+		// the status condition guards against a concurrent change of status
+		res, err := tx.ExecContext(ctx,
+			`UPDATE bookings SET room_id = $1, from_date = $2, till_date = $3, num_persons = $4, price = $5, cancellation_policy = $6, modifications = $7 WHERE id = $8 AND status = $9`,
+			booking.RoomID.Hex(), booking.FromDate.UTC(), booking.TillDate.UTC(), booking.NumPersons, jsonColumn{booking.Price},
+			jsonColumn{booking.CancellationPolicy}, jsonColumn{booking.Modifications}, booking.ID.Hex(), booking.Status,
+		)
+		if err != nil {
+			return err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			row := tx.QueryRowContext(ctx, `SELECT `+bookingColumns+` FROM bookings WHERE id = $1`, booking.ID.Hex())
+			current, err := scanBooking(row)
+			if err != nil {
+				return err
+			}
+			return &db.TransitionError{From: current.Status, To: booking.Status}
+		}
+
+		if _, err := tx.ExecContext(ctx, `DELETE FROM room_nights WHERE booking_id = $1`, booking.ID.Hex()); err != nil {
+			return err
+		}
+		return reserveNights(ctx, tx, booking)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.GetBookingByID(ctx, booking.ID.Hex())
+}
+
+// update is called on the booking read in the transaction.
+func (s *BookingStore) updateStatus(ctx context.Context, oid primitive.ObjectID, status types.BookingStatus, update func(*types.Booking) error) (*types.Booking, error) {
+	var booking *types.Booking
+	err := withTx(ctx, s.conn, func(tx *sql.Tx) error {
+		var err error
+		row := tx.QueryRowContext(ctx, `SELECT `+bookingColumns+` FROM bookings WHERE id = $1`, oid.Hex())
+		booking, err = scanBooking(row)
+		if err != nil {
+			return err
+		}
+		if !booking.Status.CanTransitionTo(status) {
+			return &db.TransitionError{From: booking.Status, To: status}
+		}
+		if err := update(booking); err != nil {
+			return err
+		}
+
+		previous := booking.Status
+		booking.SetStatus(status, time.Now())
+		// the status condition guards against a concurrent change of status
+		res, err := tx.ExecContext(ctx,
+			`UPDATE bookings SET status = $1, status_history = $2, cancellation = $3 WHERE id = $4 AND status = $5`,
+			booking.Status, jsonColumn{booking.StatusHistory}, jsonColumn{booking.Cancellation}, booking.ID.Hex(), previous,
+		)
+		if err != nil {
+			return err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return &db.TransitionError{From: previous, To: status}
+		}
+
+		if status.IsActive() {
+			return nil
+		}
+		_, err = tx.ExecContext(ctx, `DELETE FROM room_nights WHERE booking_id = $1`, booking.ID.Hex())
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return booking, nil
+}
+
+func (s *BookingStore) GetBookingByID(ctx context.Context, id string) (*types.Booking, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+	row := s.conn.QueryRowContext(ctx, `SELECT `+bookingColumns+` FROM bookings WHERE id = $1`, oid.Hex())
+	return scanBooking(row)
+}
+
+func (s *BookingStore) GetBookingByConfirmationCode(ctx context.Context, code string) (*types.Booking, error) {
+	row := s.conn.QueryRowContext(ctx, `SELECT `+bookingColumns+` FROM bookings WHERE confirmation_code = $1`, types.NormalizeConfirmationCode(code))
+	return scanBooking(row)
+}
+
+func (s *BookingStore) GetBookings(ctx context.Context, filter db.BookingFilter, pag *db.Pagination) ([]*types.Booking, error) {
+	c := bookingConditions(filter)
+	rows, err := s.conn.QueryContext(ctx, `SELECT `+bookingColumns+` FROM bookings`+c.where()+bookingOrder(filter)+limit(pag), c.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var bookings []*types.Booking
+	for rows.Next() {
+		booking, err := scanBooking(rows)
+		if err != nil {
+			return nil, err
+		}
+		bookings = append(bookings, booking)
+	}
+	return bookings, rows.Err()
+}
+
+func (s *BookingStore) InsertBooking(ctx context.Context, booking *types.Booking) (*types.Booking, error) {
+	bookings, err := s.InsertBookings(ctx, []*types.Booking{booking})
+	if err != nil {
+		return nil, err
+	}
+	return bookings[0], nil
+}
+
+func (s *BookingStore) InsertBookings(ctx context.Context, bookings []*types.Booking) ([]*types.Booking, error) {
+	err := withTx(ctx, s.conn, func(tx *sql.Tx) error {
+		for _, booking := range bookings {
+			if err := insertBooking(ctx, tx, booking); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return bookings, nil
+}
+
+func insertBooking(ctx context.Context, tx *sql.Tx, booking *types.Booking) error {
+	booking.ID = primitive.NewObjectID()
+	if len(booking.Status) == 0 {
+		booking.SetStatus(types.BookingConfirmed, time.Now())
+	}
+	inserted := false
+	for attempt := 0; attempt < db.ConfirmationCodeAttempts && !inserted; attempt++ {
+		code, err := types.NewConfirmationCode()
+		if err != nil {
+			return err
+		}
+		booking.ConfirmationCode = code
+		res, err := tx.ExecContext(ctx,
+			`INSERT INTO bookings (`+bookingColumns+`) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16) ON CONFLICT DO NOTHING`,
+			booking.ID.Hex(), booking.UserID.Hex(), booking.RoomID.Hex(), booking.HotelID.Hex(), booking.NumPersons,
+			booking.FromDate.UTC(), booking.TillDate.UTC(), booking.Status, jsonColumn{booking.StatusHistory},
+			nullTime{&booking.ExpiresAt}, jsonColumn{booking.Price}, jsonColumn{booking.CancellationPolicy}, jsonColumn{booking.Cancellation},
+			jsonColumn{booking.Modifications}, booking.ReservationID.Hex(), nullString{&booking.ConfirmationCode},
+		)
+		if err != nil {
+			return err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		inserted = n > 0
+	}
+	if !inserted {
+		return db.ErrNoConfirmationCode
+	}
+	if !booking.Status.IsActive() {
+		return nil
+	}
+	return reserveNights(ctx, tx, booking)
+}
+
+func (s *BookingStore) InsertHold(ctx context.Context, booking *types.Booking, expiresAt time.Time) (*types.Booking, error) {
+	booking.SetStatus(types.BookingPending, time.Now())
+	booking.ExpiresAt = expiresAt.UTC()
+	return s.InsertBooking(ctx, booking)
+}
+
+func reserveNights(ctx context.Context, tx *sql.Tx, booking *types.Booking) error {
+	for _, night := range booking.Nights() {
+		res, err := tx.ExecContext(ctx,
+			`INSERT INTO room_nights (room_id, night, booking_id) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING`,
+			booking.RoomID.Hex(), night, booking.ID.Hex(),
+		)
+		if err != nil {
+			return err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return db.ErrRoomNotAvailable
+		}
+	}
+	return nil
+}
+
+func scanBooking(row scanner) (*types.Booking, error) {
+	var booking types.Booking
+	err := row.Scan(
+		objectID{&booking.ID},
+		objectID{&booking.UserID},
+		objectID{&booking.RoomID},
+		objectID{&booking.HotelID},
+		&booking.NumPersons,
+		&booking.FromDate,
+		&booking.TillDate,
+		&booking.Status,
+		jsonColumn{&booking.StatusHistory},
+		nullTime{&booking.ExpiresAt},
+		jsonColumn{&booking.Price},
+		jsonColumn{&booking.CancellationPolicy},
+		jsonColumn{&booking.Cancellation},
+		jsonColumn{&booking.Modifications},
+		objectID{&booking.ReservationID},
+		nullString{&booking.ConfirmationCode},
+	)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &booking, nil
+}
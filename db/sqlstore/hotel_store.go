@@ -0,0 +1,158 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/raphaelmb/go-hotel-reservation/db"
+	"github.com/raphaelmb/go-hotel-reservation/types"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const hotelColumns = "id, name, location, rating, currency, pricing, charges, cancellation_policy"
+
+type HotelStore struct {
+	conn *sql.DB
+}
+
+func NewHotelStore(conn *sql.DB) *HotelStore {
+	return &HotelStore{
+		conn: conn,
+	}
+}
+
+func (s *HotelStore) Drop(ctx context.Context) error {
+	return dropTables(ctx, s.conn, "rooms", "hotels")
+}
+
+func (s *HotelStore) Update(ctx context.Context, id string, params types.UpdateHotelParams) error {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+	set := &conditions{}
+	if len(params.Name) > 0 {
+		set.add("name = ?", params.Name)
+	}
+	if len(params.Location) > 0 {
+		set.add("location = ?", params.Location)
+	}
+	if params.Rating > 0 {
+		set.add("rating = ?", params.Rating)
+	}
+	if len(params.Currency) > 0 {
+		set.add("currency = ?", params.Currency)
+	}
+	if params.Pricing != nil {
+		set.add("pricing = ?", jsonColumn{params.Pricing})
+	}
+	if params.Charges != nil {
+		set.add("charges = ?", jsonColumn{params.Charges})
+	}
+	if params.CancellationPolicy != nil {
+		set.add("cancellation_policy = ?", jsonColumn{params.CancellationPolicy})
+	}
+	return updateByID(ctx, s.conn, "hotels", oid.Hex(), set)
+}
+
+func (s *HotelStore) Delete(ctx context.Context, id string) error {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+	return deleteByID(ctx, s.conn, "hotels", oid.Hex())
+}
+
+// AddRoom and RemoveRoom are no-ops, the rooms of a hotel are derived from the rooms table.
+func (s *HotelStore) AddRoom(ctx context.Context, hotelID, roomID primitive.ObjectID) error {
+	return nil
+}
+
+func (s *HotelStore) RemoveRoom(ctx context.Context, hotelID, roomID primitive.ObjectID) error {
+	return nil
+}
+
+func (s *HotelStore) Insert(ctx context.Context, hotel *types.Hotel) (*types.Hotel, error) {
+	hotel.ID = primitive.NewObjectID()
+	_, err := s.conn.ExecContext(ctx,
+		`INSERT INTO hotels (`+hotelColumns+`) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		hotel.ID.Hex(), hotel.Name, hotel.Location, hotel.Rating, hotel.BaseCurrency(), jsonColumn{hotel.Pricing}, jsonColumn{hotel.Charges},
+		jsonColumn{hotel.CancellationPolicy},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return hotel, nil
+}
+
+func (s *HotelStore) GetHotels(ctx context.Context, filter db.HotelFilter, pag *db.Pagination) ([]*types.Hotel, error) {
+	c := hotelConditions(filter)
+	query := `SELECT ` + hotelColumns + ` FROM hotels` + c.where() + ` ORDER BY id` + limit(pag)
+	rows, err := s.conn.QueryContext(ctx, query, c.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var hotels []*types.Hotel
+	for rows.Next() {
+		hotel, err := scanHotel(rows)
+		if err != nil {
+			return nil, err
+		}
+		hotels = append(hotels, hotel)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, hotel := range hotels {
+		if err := s.loadRooms(ctx, hotel); err != nil {
+			return nil, err
+		}
+	}
+	return hotels, nil
+}
+
+func (s *HotelStore) GetHotelByID(ctx context.Context, id string) (*types.Hotel, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+	row := s.conn.QueryRowContext(ctx, `SELECT `+hotelColumns+` FROM hotels WHERE id = $1`, oid.Hex())
+	hotel, err := scanHotel(row)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.loadRooms(ctx, hotel); err != nil {
+		return nil, err
+	}
+	return hotel, nil
+}
+
+func (s *HotelStore) loadRooms(ctx context.Context, hotel *types.Hotel) error {
+	rows, err := s.conn.QueryContext(ctx, `SELECT id FROM rooms WHERE hotel_id = $1 ORDER BY id`, hotel.ID.Hex())
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	hotel.Rooms = []primitive.ObjectID{}
+	for rows.Next() {
+		var id primitive.ObjectID
+		if err := rows.Scan(objectID{&id}); err != nil {
+			return err
+		}
+		hotel.Rooms = append(hotel.Rooms, id)
+	}
+	return rows.Err()
+}
+
+func scanHotel(row scanner) (*types.Hotel, error) {
+	var hotel types.Hotel
+	if err := row.Scan(objectID{&hotel.ID}, &hotel.Name, &hotel.Location, &hotel.Rating, &hotel.Currency, jsonColumn{&hotel.Pricing}, jsonColumn{&hotel.Charges}, jsonColumn{&hotel.CancellationPolicy}); err != nil {
+		return nil, notFound(err)
+	}
+	return &hotel, nil
+}
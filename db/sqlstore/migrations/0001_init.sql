@@ -0,0 +1,51 @@
+CREATE TABLE users (
+	id                 TEXT PRIMARY KEY,
+	first_name         TEXT NOT NULL,
+	last_name          TEXT NOT NULL,
+	email              TEXT NOT NULL,
+	encrypted_password TEXT NOT NULL,
+	is_admin           BOOLEAN NOT NULL DEFAULT FALSE
+);
+
+CREATE INDEX users_email_idx ON users (email);
+
+CREATE TABLE hotels (
+	id       TEXT PRIMARY KEY,
+	name     TEXT NOT NULL,
+	location TEXT NOT NULL,
+	rating   INTEGER NOT NULL
+);
+
+CREATE TABLE rooms (
+	id       TEXT PRIMARY KEY,
+	hotel_id TEXT NOT NULL REFERENCES hotels (id),
+	size     TEXT NOT NULL,
+	seaside  BOOLEAN NOT NULL,
+	price    DOUBLE PRECISION NOT NULL
+);
+
+CREATE INDEX rooms_hotel_id_idx ON rooms (hotel_id);
+
+CREATE TABLE bookings (
+	id          TEXT PRIMARY KEY,
+	user_id     TEXT NOT NULL,
+	room_id     TEXT NOT NULL,
+	num_persons INTEGER NOT NULL,
+	from_date   TIMESTAMP NOT NULL,
+	till_date   TIMESTAMP NOT NULL,
+	cancelled   BOOLEAN NOT NULL DEFAULT FALSE
+);
+
+CREATE INDEX bookings_user_id_idx ON bookings (user_id);
+CREATE INDEX bookings_room_id_idx ON bookings (room_id);
+
+-- reservation ledger, the primary key guarantees that a room night can only
+-- be held by a single booking
+CREATE TABLE room_nights (
+	room_id    TEXT NOT NULL,
+	night      TIMESTAMP NOT NULL,
+	booking_id TEXT NOT NULL REFERENCES bookings (id) ON DELETE CASCADE,
+	PRIMARY KEY (room_id, night)
+);
+
+CREATE INDEX room_nights_booking_id_idx ON room_nights (booking_id);
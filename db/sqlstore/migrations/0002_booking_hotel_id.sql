@@ -0,0 +1,3 @@
+ALTER TABLE bookings ADD COLUMN hotel_id TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX bookings_hotel_id_idx ON bookings (hotel_id);
@@ -0,0 +1,5 @@
+-- beds and amenities are stored as JSON arrays
+ALTER TABLE rooms ADD COLUMN type TEXT NOT NULL DEFAULT '';
+ALTER TABLE rooms ADD COLUMN max_occupancy INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE rooms ADD COLUMN beds TEXT NOT NULL DEFAULT '[]';
+ALTER TABLE rooms ADD COLUMN amenities TEXT NOT NULL DEFAULT '[]';
@@ -0,0 +1,10 @@
+-- the cancelled flag is replaced by the booking status, status_history is a
+-- JSON array of the status changes
+ALTER TABLE bookings ADD COLUMN status TEXT NOT NULL DEFAULT 'confirmed';
+ALTER TABLE bookings ADD COLUMN status_history TEXT NOT NULL DEFAULT '[]';
+
+UPDATE bookings SET status = 'cancelled' WHERE cancelled;
+
+ALTER TABLE bookings DROP COLUMN cancelled;
+
+CREATE INDEX bookings_status_idx ON bookings (status);
@@ -0,0 +1,3 @@
+ALTER TABLE bookings ADD COLUMN expires_at TIMESTAMP;
+
+CREATE INDEX bookings_expires_at_idx ON bookings (expires_at);
@@ -0,0 +1,3 @@
+-- pricing rules and price quotes are stored as JSON
+ALTER TABLE hotels ADD COLUMN pricing TEXT NOT NULL DEFAULT '{}';
+ALTER TABLE bookings ADD COLUMN price TEXT;
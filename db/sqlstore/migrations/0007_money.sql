@@ -0,0 +1,10 @@
+-- prices are stored in the minor unit of their currency, prices stored before
+-- were in the default currency
+ALTER TABLE hotels ADD COLUMN currency TEXT NOT NULL DEFAULT 'USD';
+
+ALTER TABLE rooms ADD COLUMN price_amount BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE rooms ADD COLUMN price_currency TEXT NOT NULL DEFAULT 'USD';
+
+UPDATE rooms SET price_amount = CAST(ROUND(price * 100) AS BIGINT);
+
+ALTER TABLE rooms DROP COLUMN price;
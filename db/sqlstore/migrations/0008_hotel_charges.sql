@@ -0,0 +1,2 @@
+-- taxes and fees of a hotel are stored as JSON
+ALTER TABLE hotels ADD COLUMN charges TEXT NOT NULL DEFAULT '[]';
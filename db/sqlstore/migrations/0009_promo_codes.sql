@@ -0,0 +1,23 @@
+CREATE TABLE promo_codes (
+	id                TEXT PRIMARY KEY,
+	code              TEXT NOT NULL UNIQUE,
+	kind              TEXT NOT NULL,
+	percent           DOUBLE PRECISION NOT NULL DEFAULT 0,
+	amount            BIGINT NOT NULL DEFAULT 0,
+	currency          TEXT NOT NULL DEFAULT '',
+	min_nights        INTEGER NOT NULL DEFAULT 0,
+	valid_from        TIMESTAMP,
+	valid_till        TIMESTAMP,
+	max_uses          INTEGER NOT NULL DEFAULT 0,
+	max_uses_per_user INTEGER NOT NULL DEFAULT 0,
+	hotel_ids         TEXT NOT NULL DEFAULT '[]',
+	uses              INTEGER NOT NULL DEFAULT 0
+);
+
+-- uses of every promo code per user, to enforce the per user limit
+CREATE TABLE promo_redemptions (
+	promo_id TEXT NOT NULL REFERENCES promo_codes (id) ON DELETE CASCADE,
+	user_id  TEXT NOT NULL,
+	uses     INTEGER NOT NULL,
+	PRIMARY KEY (promo_id, user_id)
+);
@@ -0,0 +1,5 @@
+-- cancellation policies and the cancellation of bookings are stored as JSON
+ALTER TABLE hotels ADD COLUMN cancellation_policy TEXT NOT NULL DEFAULT '{}';
+ALTER TABLE rooms ADD COLUMN cancellation_policy TEXT NOT NULL DEFAULT '{}';
+ALTER TABLE bookings ADD COLUMN cancellation_policy TEXT NOT NULL DEFAULT '{}';
+ALTER TABLE bookings ADD COLUMN cancellation TEXT;
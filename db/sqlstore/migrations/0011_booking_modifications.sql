@@ -0,0 +1,2 @@
+-- the changes made to bookings are stored as JSON
+ALTER TABLE bookings ADD COLUMN modifications TEXT;
@@ -0,0 +1,15 @@
+CREATE TABLE reservations (
+	id                  TEXT PRIMARY KEY,
+	confirmation_number TEXT NOT NULL UNIQUE,
+	user_id             TEXT NOT NULL,
+	hotel_id            TEXT NOT NULL,
+	from_date           TIMESTAMP NOT NULL,
+	till_date           TIMESTAMP NOT NULL,
+	booking_ids         TEXT NOT NULL DEFAULT '[]',
+	status              TEXT NOT NULL,
+	total               TEXT NOT NULL DEFAULT '{}',
+	cancellation        TEXT
+);
+
+ALTER TABLE bookings ADD COLUMN reservation_id TEXT NOT NULL DEFAULT '';
+CREATE INDEX bookings_reservation_id_idx ON bookings (reservation_id);
@@ -0,0 +1,3 @@
+-- bookings made before confirmation codes have none
+ALTER TABLE bookings ADD COLUMN confirmation_code TEXT;
+CREATE UNIQUE INDEX bookings_confirmation_code_idx ON bookings (confirmation_code);
@@ -0,0 +1,15 @@
+CREATE TABLE waitlist (
+	id          TEXT PRIMARY KEY,
+	user_id     TEXT NOT NULL,
+	hotel_id    TEXT NOT NULL,
+	room_id     TEXT NOT NULL DEFAULT '',
+	room_type   TEXT NOT NULL DEFAULT '',
+	from_date   TIMESTAMP NOT NULL,
+	till_date   TIMESTAMP NOT NULL,
+	num_persons INTEGER NOT NULL,
+	status      TEXT NOT NULL,
+	hold_id     TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX waitlist_hotel_id_idx ON waitlist (hotel_id, status);
+CREATE INDEX waitlist_user_id_idx ON waitlist (user_id);
@@ -0,0 +1,3 @@
+-- registration relies on the index to reject an email taken concurrently
+DROP INDEX users_email_idx;
+CREATE UNIQUE INDEX users_email_idx ON users (email);
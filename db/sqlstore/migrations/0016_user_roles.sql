@@ -0,0 +1,3 @@
+-- users without a role fall back to is_admin
+ALTER TABLE users ADD COLUMN role TEXT NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN hotel_ids TEXT NOT NULL DEFAULT '[]';
@@ -0,0 +1,12 @@
+CREATE TABLE refresh_tokens (
+	id         TEXT PRIMARY KEY,
+	user_id    TEXT NOT NULL,
+	family     TEXT NOT NULL,
+	hash       TEXT NOT NULL UNIQUE,
+	expires_at TIMESTAMP NOT NULL,
+	used_at    TIMESTAMP,
+	revoked_at TIMESTAMP
+);
+
+CREATE INDEX refresh_tokens_family_idx ON refresh_tokens (family);
+CREATE INDEX refresh_tokens_user_id_idx ON refresh_tokens (user_id);
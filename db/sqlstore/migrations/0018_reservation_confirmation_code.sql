@@ -0,0 +1,2 @@
+-- reservations name their code as bookings do
+ALTER TABLE reservations RENAME COLUMN confirmation_number TO confirmation_code;
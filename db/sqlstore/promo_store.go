@@ -0,0 +1,172 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/raphaelmb/go-hotel-reservation/db"
+	"github.com/raphaelmb/go-hotel-reservation/types"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const promoColumns = "id, code, kind, percent, amount, currency, min_nights, valid_from, valid_till, max_uses, max_uses_per_user, hotel_ids, uses"
+
+type PromoCodeStore struct {
+	conn *sql.DB
+}
+
+func NewPromoCodeStore(conn *sql.DB) *PromoCodeStore {
+	return &PromoCodeStore{
+		conn: conn,
+	}
+}
+
+func (s *PromoCodeStore) Drop(ctx context.Context) error {
+	return dropTables(ctx, s.conn, "promo_redemptions", "promo_codes")
+}
+
+func (s *PromoCodeStore) InsertPromoCode(ctx context.Context, promo *types.PromoCode) (*types.PromoCode, error) {
+	promo.ID = primitive.NewObjectID()
+	res, err := s.conn.ExecContext(ctx,
+		`INSERT INTO promo_codes (`+promoColumns+`) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13) ON CONFLICT DO NOTHING`,
+		promo.ID.Hex(), promo.Code, promo.Kind, promo.Percent, promo.Amount.Amount, promo.Amount.Currency, promo.MinNights,
+		nullTime{&promo.ValidFrom}, nullTime{&promo.ValidTill}, promo.MaxUses, promo.MaxUsesPerUser, jsonColumn{promo.HotelIDs}, promo.Uses,
+	)
+	if err != nil {
+		return nil, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		return nil, db.ErrPromoCodeExists
+	}
+	return promo, nil
+}
+
+func (s *PromoCodeStore) GetPromoCodes(ctx context.Context) ([]*types.PromoCode, error) {
+	rows, err := s.conn.QueryContext(ctx, `SELECT `+promoColumns+` FROM promo_codes ORDER BY code`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var promos []*types.PromoCode
+	for rows.Next() {
+		promo, err := scanPromoCode(rows)
+		if err != nil {
+			return nil, err
+		}
+		promos = append(promos, promo)
+	}
+	return promos, rows.Err()
+}
+
+func (s *PromoCodeStore) GetPromoCodeByCode(ctx context.Context, code string) (*types.PromoCode, error) {
+	row := s.conn.QueryRowContext(ctx, `SELECT `+promoColumns+` FROM promo_codes WHERE code = $1`, types.NormalizePromoCode(code))
+	return scanPromoCode(row)
+}
+
+func (s *PromoCodeStore) DeletePromoCode(ctx context.Context, code string) error {
+	res, err := s.conn.ExecContext(ctx, `DELETE FROM promo_codes WHERE code = $1`, types.NormalizePromoCode(code))
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return db.ErrNotFound
+	}
+	return nil
+}
+
+// RedeemPromoCode checks the limits in the updates themselves.
+func (s *PromoCodeStore) RedeemPromoCode(ctx context.Context, code string, userID primitive.ObjectID) error {
+	return withTx(ctx, s.conn, func(tx *sql.Tx) error {
+		var (
+			id             string
+			maxUsesPerUser int
+		)
+		err := tx.QueryRowContext(ctx,
+			`SELECT id, max_uses_per_user FROM promo_codes WHERE code = $1`, types.NormalizePromoCode(code),
+		).Scan(&id, &maxUsesPerUser)
+		if err != nil {
+			return notFound(err)
+		}
+
+		res, err := tx.ExecContext(ctx,
+			`UPDATE promo_codes SET uses = uses + 1 WHERE id = $1 AND (max_uses = 0 OR uses < max_uses)`, id,
+		)
+		if err != nil {
+			return err
+		}
+		if n, err := res.RowsAffected(); err != nil {
+			return err
+		} else if n == 0 {
+			return db.ErrPromoCodeExhausted
+		}
+
+		res, err = tx.ExecContext(ctx,
+			`INSERT INTO promo_redemptions (promo_id, user_id, uses) VALUES ($1, $2, 1)
+			ON CONFLICT (promo_id, user_id) DO UPDATE SET uses = promo_redemptions.uses + 1
+			WHERE $3 = 0 OR promo_redemptions.uses < $3`,
+			id, userID.Hex(), maxUsesPerUser,
+		)
+		if err != nil {
+			return err
+		}
+		if n, err := res.RowsAffected(); err != nil {
+			return err
+		} else if n == 0 {
+			return db.ErrPromoCodeUserLimit
+		}
+		return nil
+	})
+}
+
+func (s *PromoCodeStore) ReleasePromoCode(ctx context.Context, code string, userID primitive.ObjectID) error {
+	return withTx(ctx, s.conn, func(tx *sql.Tx) error {
+		var id string
+		err := tx.QueryRowContext(ctx, `SELECT id FROM promo_codes WHERE code = $1`, types.NormalizePromoCode(code)).Scan(&id)
+		if err != nil {
+			return notFound(err)
+		}
+		res, err := tx.ExecContext(ctx,
+			`UPDATE promo_redemptions SET uses = uses - 1 WHERE promo_id = $1 AND user_id = $2 AND uses > 0`, id, userID.Hex(),
+		)
+		if err != nil {
+			return err
+		}
+		if n, err := res.RowsAffected(); err != nil || n == 0 {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `UPDATE promo_codes SET uses = uses - 1 WHERE id = $1 AND uses > 0`, id)
+		return err
+	})
+}
+
+func scanPromoCode(row scanner) (*types.PromoCode, error) {
+	var promo types.PromoCode
+	err := row.Scan(
+		objectID{&promo.ID},
+		&promo.Code,
+		&promo.Kind,
+		&promo.Percent,
+		&promo.Amount.Amount,
+		&promo.Amount.Currency,
+		&promo.MinNights,
+		nullTime{&promo.ValidFrom},
+		nullTime{&promo.ValidTill},
+		&promo.MaxUses,
+		&promo.MaxUsesPerUser,
+		jsonColumn{&promo.HotelIDs},
+		&promo.Uses,
+	)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &promo, nil
+}
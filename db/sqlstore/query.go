@@ -0,0 +1,212 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/raphaelmb/go-hotel-reservation/db"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// conditions numbers its placeholders after any args already collected.
+type conditions struct {
+	conds []string
+	args  []any
+}
+
+func (c *conditions) add(cond string, args ...any) {
+	for _, arg := range args {
+		c.args = append(c.args, arg)
+		cond = strings.Replace(cond, "?", fmt.Sprintf("$%d", len(c.args)), 1)
+	}
+	c.conds = append(c.conds, cond)
+}
+
+// in matches no row with an empty list.
+func (c *conditions) in(column string, values []any) {
+	if len(values) == 0 {
+		c.conds = append(c.conds, "1 = 0")
+		return
+	}
+	placeholders := make([]string, len(values))
+	for i := range values {
+		placeholders[i] = "?"
+	}
+	c.add(column+" IN ("+strings.Join(placeholders, ", ")+")", values...)
+}
+
+func (c *conditions) where() string {
+	if len(c.conds) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(c.conds, " AND ")
+}
+
+func bookingConditions(filter db.BookingFilter) *conditions {
+	c := &conditions{}
+	if !filter.UserID.IsZero() {
+		c.add("user_id = ?", filter.UserID.Hex())
+	}
+	if !filter.RoomID.IsZero() {
+		c.add("room_id = ?", filter.RoomID.Hex())
+	} else if filter.RoomIDs != nil {
+		c.in("room_id", hexIDs(filter.RoomIDs))
+	}
+	if !filter.HotelID.IsZero() {
+		c.add("hotel_id = ?", filter.HotelID.Hex())
+	} else if filter.HotelIDs != nil {
+		c.in("hotel_id", hexIDs(filter.HotelIDs))
+	}
+	if !filter.ReservationID.IsZero() {
+		c.add("reservation_id = ?", filter.ReservationID.Hex())
+	}
+	if filter.Overlaps != nil {
+		if !filter.Overlaps.Till.IsZero() {
+			c.add("from_date < ?", filter.Overlaps.Till.UTC())
+		}
+		c.add("till_date > ?", filter.Overlaps.From.UTC())
+	}
+	if len(filter.Statuses) > 0 {
+		statuses := make([]any, len(filter.Statuses))
+		for i, status := range filter.Statuses {
+			statuses[i] = string(status)
+		}
+		c.in("status", statuses)
+	}
+	if !filter.ExpiredBy.IsZero() {
+		c.add("expires_at <= ?", filter.ExpiredBy.UTC())
+	}
+	if !filter.EndedBy.IsZero() {
+		c.add("till_date <= ?", filter.EndedBy.UTC())
+	}
+	return c
+}
+
+// bookingOrder relies on object ids starting with their creation time.
+func bookingOrder(filter db.BookingFilter) string {
+	dir := " ASC"
+	if filter.SortDesc {
+		dir = " DESC"
+	}
+	switch filter.SortBy {
+	case db.SortByFromDate:
+		return " ORDER BY from_date" + dir + ", id" + dir
+	case db.SortByTillDate:
+		return " ORDER BY till_date" + dir + ", id" + dir
+	}
+	return " ORDER BY id" + dir
+}
+
+func hotelConditions(filter db.HotelFilter) *conditions {
+	c := &conditions{}
+	if filter.Rating > 0 {
+		c.add("rating = ?", filter.Rating)
+	} else if filter.MinRating > 0 {
+		c.add("rating >= ?", filter.MinRating)
+	}
+	if len(filter.Location) > 0 {
+		c.add("LOWER(location) = LOWER(?)", filter.Location)
+	}
+	return c
+}
+
+func roomConditions(filter db.RoomFilter) *conditions {
+	c := &conditions{}
+	if !filter.HotelID.IsZero() {
+		c.add("hotel_id = ?", filter.HotelID.Hex())
+	} else if filter.HotelIDs != nil {
+		c.in("hotel_id", hexIDs(filter.HotelIDs))
+	}
+	if filter.Seaside != nil {
+		c.add("seaside = ?", *filter.Seaside)
+	}
+	return c
+}
+
+func waitlistConditions(filter db.WaitlistFilter) *conditions {
+	c := &conditions{}
+	if !filter.UserID.IsZero() {
+		c.add("user_id = ?", filter.UserID.Hex())
+	}
+	if !filter.HotelID.IsZero() {
+		c.add("hotel_id = ?", filter.HotelID.Hex())
+	}
+	if len(filter.Status) > 0 {
+		c.add("status = ?", filter.Status)
+	}
+	if filter.Overlaps != nil {
+		if !filter.Overlaps.Till.IsZero() {
+			c.add("from_date < ?", filter.Overlaps.Till.UTC())
+		}
+		c.add("till_date > ?", filter.Overlaps.From.UTC())
+	}
+	return c
+}
+
+func limit(pag *db.Pagination) string {
+	if pag == nil || pag.Limit <= 0 {
+		return ""
+	}
+	offset := int64(0)
+	if pag.Page > 1 {
+		offset = (pag.Page - 1) * pag.Limit
+	}
+	return fmt.Sprintf(" LIMIT %d OFFSET %d", pag.Limit, offset)
+}
+
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func updateByID(ctx context.Context, conn querier, table, id string, set *conditions) error {
+	if len(set.conds) == 0 {
+		var n int
+		if err := conn.QueryRowContext(ctx, `SELECT COUNT(*) FROM `+table+` WHERE id = $1`, id).Scan(&n); err != nil {
+			return err
+		}
+		if n == 0 {
+			return db.ErrNotFound
+		}
+		return nil
+	}
+
+	query := fmt.Sprintf("UPDATE %s SET %s WHERE id = $%d", table, strings.Join(set.conds, ", "), len(set.args)+1)
+	res, err := conn.ExecContext(ctx, query, append(set.args, id)...)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return db.ErrNotFound
+	}
+	return nil
+}
+
+func deleteByID(ctx context.Context, conn querier, table, id string) error {
+	res, err := conn.ExecContext(ctx, `DELETE FROM `+table+` WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return db.ErrNotFound
+	}
+	return nil
+}
+
+func hexIDs(ids []primitive.ObjectID) []any {
+	hexes := make([]any, len(ids))
+	for i, id := range ids {
+		hexes[i] = id.Hex()
+	}
+	return hexes
+}
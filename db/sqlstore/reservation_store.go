@@ -0,0 +1,104 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/raphaelmb/go-hotel-reservation/db"
+	"github.com/raphaelmb/go-hotel-reservation/types"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const reservationColumns = "id, confirmation_code, user_id, hotel_id, from_date, till_date, booking_ids, status, total, cancellation"
+
+type ReservationStore struct {
+	conn *sql.DB
+}
+
+func NewReservationStore(conn *sql.DB) *ReservationStore {
+	return &ReservationStore{
+		conn: conn,
+	}
+}
+
+func (s *ReservationStore) Drop(ctx context.Context) error {
+	return dropTables(ctx, s.conn, "reservations")
+}
+
+func (s *ReservationStore) InsertReservation(ctx context.Context, reservation *types.Reservation) (*types.Reservation, error) {
+	if reservation.ID.IsZero() {
+		reservation.ID = primitive.NewObjectID()
+	}
+	res, err := s.conn.ExecContext(ctx,
+		`INSERT INTO reservations (`+reservationColumns+`) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) ON CONFLICT DO NOTHING`,
+		reservation.ID.Hex(), reservation.ConfirmationCode, reservation.UserID.Hex(), reservation.HotelID.Hex(),
+		reservation.FromDate.UTC(), reservation.TillDate.UTC(), jsonColumn{reservation.BookingIDs}, reservation.Status,
+		jsonColumn{reservation.Total}, jsonColumn{reservation.Cancellation},
+	)
+	if err != nil {
+		return nil, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		return nil, db.ErrConfirmationCodeExists
+	}
+	return reservation, nil
+}
+
+func (s *ReservationStore) GetReservationByID(ctx context.Context, id string) (*types.Reservation, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+	row := s.conn.QueryRowContext(ctx, `SELECT `+reservationColumns+` FROM reservations WHERE id = $1`, oid.Hex())
+	return scanReservation(row)
+}
+
+func (s *ReservationStore) UpdateReservationStatus(ctx context.Context, id string, from, to types.ReservationStatus, cancellation *types.Cancellation) (*types.Reservation, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+	res, err := s.conn.ExecContext(ctx,
+		`UPDATE reservations SET status = $1, cancellation = $2 WHERE id = $3 AND status = $4`,
+		to, jsonColumn{cancellation}, oid.Hex(), from,
+	)
+	if err != nil {
+		return nil, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		// tell a missing reservation from one in another status
+		if _, err := s.GetReservationByID(ctx, id); err != nil {
+			return nil, err
+		}
+		return nil, db.ErrReservationStatusChanged
+	}
+	return s.GetReservationByID(ctx, id)
+}
+
+func scanReservation(row scanner) (*types.Reservation, error) {
+	var reservation types.Reservation
+	err := row.Scan(
+		objectID{&reservation.ID},
+		&reservation.ConfirmationCode,
+		objectID{&reservation.UserID},
+		objectID{&reservation.HotelID},
+		&reservation.FromDate,
+		&reservation.TillDate,
+		jsonColumn{&reservation.BookingIDs},
+		&reservation.Status,
+		jsonColumn{&reservation.Total},
+		jsonColumn{&reservation.Cancellation},
+	)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &reservation, nil
+}
@@ -0,0 +1,134 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/raphaelmb/go-hotel-reservation/db"
+	"github.com/raphaelmb/go-hotel-reservation/types"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const roomColumns = "id, type, size, max_occupancy, beds, amenities, seaside, price_amount, price_currency, hotel_id, cancellation_policy"
+
+type RoomStore struct {
+	conn *sql.DB
+}
+
+func NewRoomStore(conn *sql.DB) *RoomStore {
+	return &RoomStore{
+		conn: conn,
+	}
+}
+
+func (s *RoomStore) Drop(ctx context.Context) error {
+	return dropTables(ctx, s.conn, "rooms")
+}
+
+func (s *RoomStore) InsertRoom(ctx context.Context, room *types.Room) (*types.Room, error) {
+	room.ID = primitive.NewObjectID()
+	_, err := s.conn.ExecContext(ctx,
+		`INSERT INTO rooms (`+roomColumns+`) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`,
+		room.ID.Hex(), room.Type, room.Size, room.MaxOccupancy, jsonColumn{room.Beds}, jsonColumn{room.Amenities},
+		room.Seaside, room.Price.Amount, room.Price.Currency, room.HotelID.Hex(), jsonColumn{room.CancellationPolicy},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return room, nil
+}
+
+func (s *RoomStore) UpdateRoom(ctx context.Context, id string, params types.UpdateRoomParams) error {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+	set := &conditions{}
+	if len(params.Type) > 0 {
+		set.add("type = ?", params.Type)
+	}
+	if len(params.Size) > 0 {
+		set.add("size = ?", params.Size)
+	}
+	if params.MaxOccupancy > 0 {
+		set.add("max_occupancy = ?", params.MaxOccupancy)
+	}
+	if params.Beds != nil {
+		set.add("beds = ?", jsonColumn{params.Beds})
+	}
+	if params.Amenities != nil {
+		set.add("amenities = ?", jsonColumn{params.Amenities})
+	}
+	if params.Seaside != nil {
+		set.add("seaside = ?", *params.Seaside)
+	}
+	if params.Price.Amount > 0 {
+		set.add("price_amount = ?", params.Price.Amount)
+		set.add("price_currency = ?", params.Price.Currency)
+	}
+	if hotelID, err := primitive.ObjectIDFromHex(params.HotelID); err == nil {
+		set.add("hotel_id = ?", hotelID.Hex())
+	}
+	if params.CancellationPolicy != nil {
+		set.add("cancellation_policy = ?", jsonColumn{params.CancellationPolicy})
+	}
+	return updateByID(ctx, s.conn, "rooms", oid.Hex(), set)
+}
+
+func (s *RoomStore) DeleteRoom(ctx context.Context, id string) error {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+	return deleteByID(ctx, s.conn, "rooms", oid.Hex())
+}
+
+func (s *RoomStore) GetRooms(ctx context.Context, filter db.RoomFilter) ([]*types.Room, error) {
+	c := roomConditions(filter)
+	rows, err := s.conn.QueryContext(ctx, `SELECT `+roomColumns+` FROM rooms`+c.where()+` ORDER BY id`, c.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rooms []*types.Room
+	for rows.Next() {
+		room, err := scanRoom(rows)
+		if err != nil {
+			return nil, err
+		}
+		rooms = append(rooms, room)
+	}
+	return rooms, rows.Err()
+}
+
+func (s *RoomStore) GetRoomByID(ctx context.Context, id string) (*types.Room, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+	row := s.conn.QueryRowContext(ctx, `SELECT `+roomColumns+` FROM rooms WHERE id = $1`, oid.Hex())
+	return scanRoom(row)
+}
+
+func scanRoom(row scanner) (*types.Room, error) {
+	var room types.Room
+	err := row.Scan(
+		objectID{&room.ID},
+		&room.Type,
+		&room.Size,
+		&room.MaxOccupancy,
+		jsonColumn{&room.Beds},
+		jsonColumn{&room.Amenities},
+		&room.Seaside,
+		&room.Price.Amount,
+		&room.Price.Currency,
+		objectID{&room.HotelID},
+		jsonColumn{&room.CancellationPolicy},
+	)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &room, nil
+}
@@ -0,0 +1,223 @@
+// Package sqlstore implements the db stores with SQL understood by SQLite and Postgres.
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/raphaelmb/go-hotel-reservation/db"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	_ "modernc.org/sqlite"
+)
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+func Open(ctx context.Context, dsn string) (*sql.DB, error) {
+	// SQLite time format, so times compare as text in date order
+	sep := "?"
+	if strings.Contains(dsn, "?") {
+		sep = "&"
+	}
+	conn, err := sql.Open("sqlite", dsn+sep+"_time_format=sqlite")
+	if err != nil {
+		return nil, err
+	}
+	// SQLite has a single writer, and every connection to :memory: its own database
+	conn.SetMaxOpenConns(1)
+
+	if _, err := conn.ExecContext(ctx, "PRAGMA foreign_keys = ON"); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if err := Migrate(ctx, conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+func Migrate(ctx context.Context, conn *sql.DB) error {
+	if _, err := conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (version TEXT PRIMARY KEY)`); err != nil {
+		return err
+	}
+
+	files, err := fs.Glob(migrations, "migrations/*.sql")
+	if err != nil {
+		return err
+	}
+	sort.Strings(files)
+
+	for _, file := range files {
+		var applied int
+		if err := conn.QueryRowContext(ctx, `SELECT COUNT(*) FROM schema_migrations WHERE version = $1`, file).Scan(&applied); err != nil {
+			return err
+		}
+		if applied > 0 {
+			continue
+		}
+
+		stmts, err := migrations.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		err = withTx(ctx, conn, func(tx *sql.Tx) error {
+			if _, err := tx.ExecContext(ctx, string(stmts)); err != nil {
+				return err
+			}
+			_, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version) VALUES ($1)`, file)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("migration %s: %w", file, err)
+		}
+	}
+	return nil
+}
+
+func NewStore(conn *sql.DB) *db.Store {
+	return &db.Store{
+		User:        NewUserStore(conn),
+		Hotel:       NewHotelStore(conn),
+		Room:        NewRoomStore(conn),
+		Booking:     NewBookingStore(conn),
+		Promo:       NewPromoCodeStore(conn),
+		Reservation: NewReservationStore(conn),
+		Waitlist:    NewWaitlistStore(conn),
+		Token:       NewTokenStore(conn),
+	}
+}
+
+func withTx(ctx context.Context, conn *sql.DB, fn func(*sql.Tx) error) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func dropTables(ctx context.Context, conn *sql.DB, tables ...string) error {
+	return withTx(ctx, conn, func(tx *sql.Tx) error {
+		for _, table := range tables {
+			if _, err := tx.ExecContext(ctx, "DELETE FROM "+table); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func notFound(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return db.ErrNotFound
+	}
+	return err
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+type objectID struct {
+	dst *primitive.ObjectID
+}
+
+func (id objectID) Scan(src any) error {
+	var hex string
+	switch v := src.(type) {
+	case string:
+		hex = v
+	case []byte:
+		hex = string(v)
+	default:
+		return fmt.Errorf("cannot scan %T into an object id", src)
+	}
+	if hex == "" || hex == primitive.NilObjectID.Hex() {
+		*id.dst = primitive.NilObjectID
+		return nil
+	}
+	oid, err := primitive.ObjectIDFromHex(hex)
+	if err != nil {
+		return err
+	}
+	*id.dst = oid
+	return nil
+}
+
+type jsonColumn struct {
+	v any
+}
+
+func (c jsonColumn) Value() (driver.Value, error) {
+	b, err := json.Marshal(c.v)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+func (c jsonColumn) Scan(src any) error {
+	switch v := src.(type) {
+	case string:
+		return json.Unmarshal([]byte(v), c.v)
+	case []byte:
+		return json.Unmarshal(v, c.v)
+	case nil:
+		return nil
+	}
+	return fmt.Errorf("cannot scan %T into %T", src, c.v)
+}
+
+type nullTime struct {
+	t *time.Time
+}
+
+func (n nullTime) Value() (driver.Value, error) {
+	if n.t.IsZero() {
+		return nil, nil
+	}
+	return n.t.UTC(), nil
+}
+
+func (n nullTime) Scan(src any) error {
+	var v sql.NullTime
+	if err := v.Scan(src); err != nil {
+		return err
+	}
+	*n.t = v.Time
+	return nil
+}
+
+// nullString stores an empty string as NULL, so unique columns can stay unset.
+type nullString struct {
+	s *string
+}
+
+func (n nullString) Value() (driver.Value, error) {
+	if len(*n.s) == 0 {
+		return nil, nil
+	}
+	return *n.s, nil
+}
+
+func (n nullString) Scan(src any) error {
+	var v sql.NullString
+	if err := v.Scan(src); err != nil {
+		return err
+	}
+	*n.s = v.String
+	return nil
+}
@@ -0,0 +1,105 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/raphaelmb/go-hotel-reservation/db"
+	"github.com/raphaelmb/go-hotel-reservation/types"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const refreshTokenColumns = "id, user_id, family, hash, expires_at, used_at, revoked_at"
+
+type TokenStore struct {
+	conn *sql.DB
+}
+
+func NewTokenStore(conn *sql.DB) *TokenStore {
+	return &TokenStore{
+		conn: conn,
+	}
+}
+
+func (s *TokenStore) Drop(ctx context.Context) error {
+	return dropTables(ctx, s.conn, "refresh_tokens")
+}
+
+func (s *TokenStore) InsertRefreshToken(ctx context.Context, token *types.RefreshToken) (*types.RefreshToken, error) {
+	if token.ID.IsZero() {
+		token.ID = primitive.NewObjectID()
+	}
+	_, err := s.conn.ExecContext(ctx,
+		`INSERT INTO refresh_tokens (`+refreshTokenColumns+`) VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		token.ID.Hex(), token.UserID.Hex(), token.Family.Hex(), token.Hash, token.ExpiresAt.UTC(),
+		nullTime{&token.UsedAt}, nullTime{&token.RevokedAt},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+func (s *TokenStore) GetRefreshTokenByHash(ctx context.Context, hash string) (*types.RefreshToken, error) {
+	row := s.conn.QueryRowContext(ctx, `SELECT `+refreshTokenColumns+` FROM refresh_tokens WHERE hash = $1`, hash)
+	var token types.RefreshToken
+	err := row.Scan(
+		objectID{&token.ID},
+		objectID{&token.UserID},
+		objectID{&token.Family},
+		&token.Hash,
+		&token.ExpiresAt,
+		nullTime{&token.UsedAt},
+		nullTime{&token.RevokedAt},
+	)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &token, nil
+}
+
+func (s *TokenStore) UseRefreshToken(ctx context.Context, id primitive.ObjectID, at time.Time) error {
+	res, err := s.conn.ExecContext(ctx,
+		`UPDATE refresh_tokens SET used_at = $1 WHERE id = $2 AND used_at IS NULL AND revoked_at IS NULL`,
+		at.UTC(), id.Hex(),
+	)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return db.ErrRefreshTokenUsed
+	}
+	return nil
+}
+
+func (s *TokenStore) RevokeTokenFamily(ctx context.Context, family primitive.ObjectID, at time.Time) error {
+	_, err := s.conn.ExecContext(ctx,
+		`UPDATE refresh_tokens SET revoked_at = $1 WHERE family = $2 AND revoked_at IS NULL`,
+		at.UTC(), family.Hex(),
+	)
+	return err
+}
+
+func (s *TokenStore) RevokeUserTokens(ctx context.Context, userID primitive.ObjectID, at time.Time) error {
+	_, err := s.conn.ExecContext(ctx,
+		`UPDATE refresh_tokens SET revoked_at = $1 WHERE user_id = $2 AND revoked_at IS NULL`,
+		at.UTC(), userID.Hex(),
+	)
+	return err
+}
+
+func (s *TokenStore) IsTokenFamilyRevoked(ctx context.Context, family primitive.ObjectID) (bool, error) {
+	var n int
+	err := s.conn.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM refresh_tokens WHERE family = $1 AND revoked_at IS NOT NULL`, family.Hex(),
+	).Scan(&n)
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
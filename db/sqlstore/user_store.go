@@ -0,0 +1,144 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/raphaelmb/go-hotel-reservation/db"
+	"github.com/raphaelmb/go-hotel-reservation/types"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const userColumns = "id, first_name, last_name, email, encrypted_password, is_admin, role, hotel_ids"
+
+type UserStore struct {
+	conn *sql.DB
+}
+
+func NewUserStore(conn *sql.DB) *UserStore {
+	return &UserStore{
+		conn: conn,
+	}
+}
+
+func (s *UserStore) Drop(ctx context.Context) error {
+	return dropTables(ctx, s.conn, "users")
+}
+
+func (s *UserStore) UpdateUser(ctx context.Context, id string, params types.UpdateUserParams) error {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+
+	set := &conditions{}
+	if len(params.FirstName) > 0 {
+		set.add("first_name = ?", params.FirstName)
+	}
+	if len(params.LastName) > 0 {
+		set.add("last_name = ?", params.LastName)
+	}
+	return updateByID(ctx, s.conn, "users", oid.Hex(), set)
+}
+
+func (s *UserStore) UpdateUserPassword(ctx context.Context, id string, encryptedPassword string) error {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+	set := &conditions{}
+	set.add("encrypted_password = ?", encryptedPassword)
+	return updateByID(ctx, s.conn, "users", oid.Hex(), set)
+}
+
+func (s *UserStore) UpdateUserRole(ctx context.Context, id string, role types.Role, hotelIDs []primitive.ObjectID) error {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+	set := &conditions{}
+	set.add("role = ?", role)
+	set.add("hotel_ids = ?", jsonColumn{hotelIDs})
+	set.add("is_admin = ?", role == types.RoleAdmin)
+	return updateByID(ctx, s.conn, "users", oid.Hex(), set)
+}
+
+func (s *UserStore) DeleteUser(ctx context.Context, id string) error {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+	_, err = s.conn.ExecContext(ctx, `DELETE FROM users WHERE id = $1`, oid.Hex())
+	return err
+}
+
+func (s *UserStore) InsertUser(ctx context.Context, user *types.User) (*types.User, error) {
+	user.ID = primitive.NewObjectID()
+	res, err := s.conn.ExecContext(ctx,
+		`INSERT INTO users (`+userColumns+`) VALUES ($1, $2, $3, $4, $5, $6, $7, $8) ON CONFLICT DO NOTHING`,
+		user.ID.Hex(), user.FirstName, user.LastName, user.Email, user.EncryptedPassword, user.IsAdmin,
+		user.Role, jsonColumn{user.HotelIDs},
+	)
+	if err != nil {
+		return nil, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		return nil, db.ErrEmailExists
+	}
+
+	return user, nil
+}
+
+func (s *UserStore) GetUserByID(ctx context.Context, id string) (*types.User, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+	row := s.conn.QueryRowContext(ctx, `SELECT `+userColumns+` FROM users WHERE id = $1`, oid.Hex())
+	return scanUser(row)
+}
+
+func (s *UserStore) GetUsers(ctx context.Context) ([]*types.User, error) {
+	rows, err := s.conn.QueryContext(ctx, `SELECT `+userColumns+` FROM users ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []*types.User
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
+
+func (s *UserStore) GetUserByEmail(ctx context.Context, email string) (*types.User, error) {
+	row := s.conn.QueryRowContext(ctx, `SELECT `+userColumns+` FROM users WHERE email = $1`, email)
+	return scanUser(row)
+}
+
+func scanUser(row scanner) (*types.User, error) {
+	var user types.User
+	err := row.Scan(
+		objectID{&user.ID},
+		&user.FirstName,
+		&user.LastName,
+		&user.Email,
+		&user.EncryptedPassword,
+		&user.IsAdmin,
+		&user.Role,
+		jsonColumn{&user.HotelIDs},
+	)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &user, nil
+}
@@ -0,0 +1,121 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/raphaelmb/go-hotel-reservation/db"
+	"github.com/raphaelmb/go-hotel-reservation/types"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const waitlistColumns = "id, user_id, hotel_id, room_id, room_type, from_date, till_date, num_persons, status, hold_id"
+
+type WaitlistStore struct {
+	conn *sql.DB
+}
+
+func NewWaitlistStore(conn *sql.DB) *WaitlistStore {
+	return &WaitlistStore{
+		conn: conn,
+	}
+}
+
+func (s *WaitlistStore) Drop(ctx context.Context) error {
+	return dropTables(ctx, s.conn, "waitlist")
+}
+
+func (s *WaitlistStore) InsertWaitlistEntry(ctx context.Context, entry *types.WaitlistEntry) (*types.WaitlistEntry, error) {
+	if entry.ID.IsZero() {
+		entry.ID = primitive.NewObjectID()
+	}
+	_, err := s.conn.ExecContext(ctx,
+		`INSERT INTO waitlist (`+waitlistColumns+`) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
+		entry.ID.Hex(), entry.UserID.Hex(), entry.HotelID.Hex(), entry.RoomID.Hex(), entry.RoomType,
+		entry.FromDate.UTC(), entry.TillDate.UTC(), entry.NumPersons, entry.Status, entry.HoldID.Hex(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+func (s *WaitlistStore) GetWaitlistEntryByID(ctx context.Context, id string) (*types.WaitlistEntry, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+	row := s.conn.QueryRowContext(ctx, `SELECT `+waitlistColumns+` FROM waitlist WHERE id = $1`, oid.Hex())
+	return scanWaitlistEntry(row)
+}
+
+// GetWaitlistEntries relies on object ids starting with their creation time.
+func (s *WaitlistStore) GetWaitlistEntries(ctx context.Context, filter db.WaitlistFilter) ([]*types.WaitlistEntry, error) {
+	c := waitlistConditions(filter)
+	rows, err := s.conn.QueryContext(ctx, `SELECT `+waitlistColumns+` FROM waitlist`+c.where()+` ORDER BY id`, c.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []*types.WaitlistEntry
+	for rows.Next() {
+		entry, err := scanWaitlistEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+func (s *WaitlistStore) UpdateWaitlistStatus(ctx context.Context, id string, from, to types.WaitlistStatus, holdID primitive.ObjectID) (*types.WaitlistEntry, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+	var hold string
+	if !holdID.IsZero() {
+		hold = holdID.Hex()
+	}
+	// an empty hold leaves the one recorded alone
+	res, err := s.conn.ExecContext(ctx,
+		`UPDATE waitlist SET status = $1, hold_id = COALESCE(NULLIF($2, ''), hold_id) WHERE id = $3 AND status = $4`,
+		to, hold, oid.Hex(), from,
+	)
+	if err != nil {
+		return nil, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		// tell a missing entry from one in another status
+		if _, err := s.GetWaitlistEntryByID(ctx, id); err != nil {
+			return nil, err
+		}
+		return nil, db.ErrWaitlistStatusChanged
+	}
+	return s.GetWaitlistEntryByID(ctx, id)
+}
+
+func scanWaitlistEntry(row scanner) (*types.WaitlistEntry, error) {
+	var entry types.WaitlistEntry
+	err := row.Scan(
+		objectID{&entry.ID},
+		objectID{&entry.UserID},
+		objectID{&entry.HotelID},
+		objectID{&entry.RoomID},
+		&entry.RoomType,
+		&entry.FromDate,
+		&entry.TillDate,
+		&entry.NumPersons,
+		&entry.Status,
+		objectID{&entry.HoldID},
+	)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &entry, nil
+}
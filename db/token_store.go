@@ -0,0 +1,106 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"os"
+	"time"
+
+	"github.com/raphaelmb/go-hotel-reservation/types"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var ErrRefreshTokenUsed = errors.New("refresh token already used")
+
+type TokenStore interface {
+	InsertRefreshToken(context.Context, *types.RefreshToken) (*types.RefreshToken, error)
+	GetRefreshTokenByHash(context.Context, string) (*types.RefreshToken, error)
+	// UseRefreshToken returns ErrRefreshTokenUsed if the token was used or revoked before.
+	UseRefreshToken(ctx context.Context, id primitive.ObjectID, at time.Time) error
+	RevokeTokenFamily(ctx context.Context, family primitive.ObjectID, at time.Time) error
+	RevokeUserTokens(ctx context.Context, userID primitive.ObjectID, at time.Time) error
+	IsTokenFamilyRevoked(ctx context.Context, family primitive.ObjectID) (bool, error)
+}
+
+type MongoTokenStore struct {
+	client *mongo.Client
+	coll   *mongo.Collection
+
+	indexes indexes
+}
+
+func NewMongoTokenStore(client *mongo.Client) *MongoTokenStore {
+	dbName := os.Getenv(MongoDBNameEnvName)
+	return &MongoTokenStore{
+		client: client,
+		coll:   client.Database(dbName).Collection("refreshTokens"),
+	}
+}
+
+func (s *MongoTokenStore) ensureIndexes(ctx context.Context) error {
+	return s.indexes.ensure(ctx, func(ctx context.Context) error {
+		_, err := s.coll.Indexes().CreateMany(ctx, []mongo.IndexModel{
+			{Keys: bson.D{{Key: "hash", Value: 1}}, Options: options.Index().SetUnique(true)},
+			{Keys: bson.D{{Key: "family", Value: 1}}},
+			{Keys: bson.D{{Key: "userId", Value: 1}}},
+		})
+		return err
+	})
+}
+
+func (s *MongoTokenStore) InsertRefreshToken(ctx context.Context, token *types.RefreshToken) (*types.RefreshToken, error) {
+	if err := s.ensureIndexes(ctx); err != nil {
+		return nil, err
+	}
+	res, err := s.coll.InsertOne(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	token.ID = res.InsertedID.(primitive.ObjectID)
+	return token, nil
+}
+
+func (s *MongoTokenStore) GetRefreshTokenByHash(ctx context.Context, hash string) (*types.RefreshToken, error) {
+	var token *types.RefreshToken
+	if err := s.coll.FindOne(ctx, bson.M{"hash": hash}).Decode(&token); err != nil {
+		return nil, notFound(err)
+	}
+	return token, nil
+}
+
+func (s *MongoTokenStore) UseRefreshToken(ctx context.Context, id primitive.ObjectID, at time.Time) error {
+	filter := bson.M{"_id": id, "usedAt": bson.M{"$exists": false}, "revokedAt": bson.M{"$exists": false}}
+	res, err := s.coll.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"usedAt": at}})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrRefreshTokenUsed
+	}
+	return nil
+}
+
+func (s *MongoTokenStore) RevokeTokenFamily(ctx context.Context, family primitive.ObjectID, at time.Time) error {
+	return s.revoke(ctx, bson.M{"family": family}, at)
+}
+
+func (s *MongoTokenStore) RevokeUserTokens(ctx context.Context, userID primitive.ObjectID, at time.Time) error {
+	return s.revoke(ctx, bson.M{"userId": userID}, at)
+}
+
+func (s *MongoTokenStore) revoke(ctx context.Context, filter bson.M, at time.Time) error {
+	filter["revokedAt"] = bson.M{"$exists": false}
+	_, err := s.coll.UpdateMany(ctx, filter, bson.M{"$set": bson.M{"revokedAt": at}})
+	return err
+}
+
+func (s *MongoTokenStore) IsTokenFamilyRevoked(ctx context.Context, family primitive.ObjectID) (bool, error) {
+	n, err := s.coll.CountDocuments(ctx, bson.M{"family": family, "revokedAt": bson.M{"$exists": true}})
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
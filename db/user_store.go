@@ -2,6 +2,7 @@ package db
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 
@@ -9,11 +10,12 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 const userColl = "users"
 
-type Map map[string]any
+var ErrEmailExists = errors.New("email already exists")
 
 type Dropper interface {
 	Drop(context.Context) error
@@ -24,8 +26,13 @@ type UserStore interface {
 
 	GetUserByID(context.Context, string) (*types.User, error)
 	GetUsers(context.Context) ([]*types.User, error)
+	// InsertUser returns ErrEmailExists if another user has the email.
 	InsertUser(context.Context, *types.User) (*types.User, error)
-	UpdateUser(ctx context.Context, filter Map, params types.UpdateUserParams) error
+	UpdateUser(context.Context, string, types.UpdateUserParams) error
+	UpdateUserPassword(ctx context.Context, id string, encryptedPassword string) error
+	// UpdateUserRole gives the user a role, along with the hotels it applies
+	// to for staff, keeping the admin flag in line with it.
+	UpdateUserRole(ctx context.Context, id string, role types.Role, hotelIDs []primitive.ObjectID) error
 	DeleteUser(context.Context, string) error
 	GetUserByEmail(context.Context, string) (*types.User, error)
 }
@@ -33,6 +40,8 @@ type UserStore interface {
 type MongoUserStore struct {
 	client *mongo.Client
 	coll   *mongo.Collection
+
+	indexes indexes
 }
 
 func (s *MongoUserStore) Drop(ctx context.Context) error {
@@ -51,14 +60,23 @@ func NewMongoUserStore(client *mongo.Client) *MongoUserStore {
 	}
 }
 
-func (s *MongoUserStore) UpdateUser(ctx context.Context, filter Map, params types.UpdateUserParams) error {
-	oid, err := primitive.ObjectIDFromHex(filter["_id"].(string))
+func (s *MongoUserStore) ensureIndexes(ctx context.Context) error {
+	return s.indexes.ensure(ctx, func(ctx context.Context) error {
+		_, err := s.coll.Indexes().CreateOne(ctx, mongo.IndexModel{
+			Keys:    bson.D{{Key: "email", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		})
+		return err
+	})
+}
+
+func (s *MongoUserStore) UpdateUser(ctx context.Context, id string, params types.UpdateUserParams) error {
+	oid, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
 		return err
 	}
-	filter["_id"] = oid
 	update := bson.M{"$set": params.ToBSON()}
-	_, err = s.coll.UpdateOne(ctx, filter, update)
+	_, err = s.coll.UpdateByID(ctx, oid, update)
 	if err != nil {
 		return err
 	}
@@ -66,6 +84,37 @@ func (s *MongoUserStore) UpdateUser(ctx context.Context, filter Map, params type
 	return nil
 }
 
+func (s *MongoUserStore) UpdateUserPassword(ctx context.Context, id string, encryptedPassword string) error {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+	res, err := s.coll.UpdateByID(ctx, oid, bson.M{"$set": bson.M{"encryptedPassword": encryptedPassword}})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *MongoUserStore) UpdateUserRole(ctx context.Context, id string, role types.Role, hotelIDs []primitive.ObjectID) error {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+	update := bson.M{"$set": bson.M{"role": role, "hotelIDs": hotelIDs, "isAdmin": role == types.RoleAdmin}}
+	res, err := s.coll.UpdateByID(ctx, oid, update)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
 func (s *MongoUserStore) DeleteUser(ctx context.Context, id string) error {
 	oid, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
@@ -81,8 +130,14 @@ func (s *MongoUserStore) DeleteUser(ctx context.Context, id string) error {
 }
 
 func (s *MongoUserStore) InsertUser(ctx context.Context, user *types.User) (*types.User, error) {
+	if err := s.ensureIndexes(ctx); err != nil {
+		return nil, err
+	}
 	res, err := s.coll.InsertOne(ctx, user)
 	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, ErrEmailExists
+		}
 		return nil, err
 	}
 	user.ID = res.InsertedID.(primitive.ObjectID)
@@ -98,7 +153,7 @@ func (s *MongoUserStore) GetUserByID(ctx context.Context, id string) (*types.Use
 
 	var user types.User
 	if err := s.coll.FindOne(ctx, bson.M{"_id": oid}).Decode(&user); err != nil {
-		return nil, err
+		return nil, notFound(err)
 	}
 	return &user, nil
 }
@@ -120,7 +175,7 @@ func (s *MongoUserStore) GetUsers(ctx context.Context) ([]*types.User, error) {
 func (s *MongoUserStore) GetUserByEmail(ctx context.Context, email string) (*types.User, error) {
 	var user *types.User
 	if err := s.coll.FindOne(ctx, bson.M{"email": email}).Decode(&user); err != nil {
-		return nil, err
+		return nil, notFound(err)
 	}
 	return user, nil
 }
@@ -0,0 +1,99 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"os"
+
+	"github.com/raphaelmb/go-hotel-reservation/types"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var ErrWaitlistStatusChanged = errors.New("waitlist entry status changed")
+
+type WaitlistStore interface {
+	InsertWaitlistEntry(context.Context, *types.WaitlistEntry) (*types.WaitlistEntry, error)
+	GetWaitlistEntryByID(context.Context, string) (*types.WaitlistEntry, error)
+	// GetWaitlistEntries returns the entries in the order they were made.
+	GetWaitlistEntries(context.Context, WaitlistFilter) ([]*types.WaitlistEntry, error)
+	// UpdateWaitlistStatus records the hold unless holdID is zero. It returns
+	// ErrWaitlistStatusChanged unless the entry is in the from status.
+	UpdateWaitlistStatus(ctx context.Context, id string, from, to types.WaitlistStatus, holdID primitive.ObjectID) (*types.WaitlistEntry, error)
+}
+
+type MongoWaitlistStore struct {
+	client *mongo.Client
+	coll   *mongo.Collection
+}
+
+func NewMongoWaitlistStore(client *mongo.Client) *MongoWaitlistStore {
+	dbName := os.Getenv(MongoDBNameEnvName)
+	return &MongoWaitlistStore{
+		client: client,
+		coll:   client.Database(dbName).Collection("waitlist"),
+	}
+}
+
+func (s *MongoWaitlistStore) InsertWaitlistEntry(ctx context.Context, entry *types.WaitlistEntry) (*types.WaitlistEntry, error) {
+	res, err := s.coll.InsertOne(ctx, entry)
+	if err != nil {
+		return nil, err
+	}
+	entry.ID = res.InsertedID.(primitive.ObjectID)
+	return entry, nil
+}
+
+func (s *MongoWaitlistStore) GetWaitlistEntryByID(ctx context.Context, id string) (*types.WaitlistEntry, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+	var entry *types.WaitlistEntry
+	if err := s.coll.FindOne(ctx, bson.M{"_id": oid}).Decode(&entry); err != nil {
+		return nil, notFound(err)
+	}
+	return entry, nil
+}
+
+func (s *MongoWaitlistStore) GetWaitlistEntries(ctx context.Context, filter WaitlistFilter) ([]*types.WaitlistEntry, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}})
+	curr, err := s.coll.Find(ctx, filter.ToBSON(), opts)
+	if err != nil {
+		return nil, err
+	}
+	var entries []*types.WaitlistEntry
+	if err := curr.All(ctx, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+func (s *MongoWaitlistStore) UpdateWaitlistStatus(ctx context.Context, id string, from, to types.WaitlistStatus, holdID primitive.ObjectID) (*types.WaitlistEntry, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+	set := bson.M{"status": to}
+	if !holdID.IsZero() {
+		set["holdID"] = holdID
+	}
+	var (
+		entry *types.WaitlistEntry
+		opts  = options.FindOneAndUpdate().SetReturnDocument(options.After)
+	)
+	err = s.coll.FindOneAndUpdate(ctx, bson.M{"_id": oid, "status": from}, bson.M{"$set": set}, opts).Decode(&entry)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		// tell a missing entry from one in another status
+		if _, err := s.GetWaitlistEntryByID(ctx, id); err != nil {
+			return nil, err
+		}
+		return nil, ErrWaitlistStatusChanged
+	}
+	if err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
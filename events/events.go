@@ -0,0 +1,68 @@
+// Package events carries what happens in the API to whoever notifies guests.
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type Type string
+
+const (
+	// WaitlistOffered events carry a WaitlistOffer.
+	WaitlistOffered Type = "waitlist.offered"
+)
+
+type Event struct {
+	Type   Type               `json:"type"`
+	At     time.Time          `json:"at"`
+	UserID primitive.ObjectID `json:"userId"`
+	Data   any                `json:"data"`
+}
+
+type WaitlistOffer struct {
+	EntryID   primitive.ObjectID `json:"entryId"`
+	HoldID    primitive.ObjectID `json:"holdId"`
+	RoomID    primitive.ObjectID `json:"roomId"`
+	FromDate  time.Time          `json:"fromDate"`
+	TillDate  time.Time          `json:"tillDate"`
+	ExpiresAt time.Time          `json:"expiresAt"`
+}
+
+type Publisher interface {
+	Publish(context.Context, Event) error
+}
+
+type LogPublisher struct{}
+
+func (LogPublisher) Publish(ctx context.Context, event Event) error {
+	b, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	log.Printf("event: %s", b)
+	return nil
+}
+
+type Recorder struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+func (r *Recorder) Publish(ctx context.Context, event Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+	return nil
+}
+
+func (r *Recorder) Events() []Event {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Event(nil), r.events...)
+}
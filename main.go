@@ -2,13 +2,21 @@ package main
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
 	"os"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/joho/godotenv"
 	"github.com/raphaelmb/go-hotel-reservation/api"
 	"github.com/raphaelmb/go-hotel-reservation/db"
+	"github.com/raphaelmb/go-hotel-reservation/db/memory"
+	"github.com/raphaelmb/go-hotel-reservation/db/sqlstore"
+	"github.com/raphaelmb/go-hotel-reservation/events"
+	"github.com/raphaelmb/go-hotel-reservation/policy"
+	"github.com/raphaelmb/go-hotel-reservation/types"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
@@ -18,48 +26,63 @@ var config = fiber.Config{
 }
 
 func main() {
-	mongoEndpoint := os.Getenv("MONGO_DB_URL")
-	client, err := mongo.Connect(context.TODO(), options.Client().ApplyURI(mongoEndpoint))
+	store, err := newStore(os.Getenv(db.BackendEnvName))
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	if err := client.Ping(context.Background(), nil); err != nil {
+	var rates *types.ExchangeRates
+	if path := os.Getenv("EXCHANGE_RATES_FILE"); len(path) > 0 {
+		if rates, err = types.LoadExchangeRates(path); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	tokens, err := api.NewTokenIssuerFromEnv()
+	if err != nil {
 		log.Fatal(err)
 	}
 
+	cookies := api.NewSessionCookiesFromEnv()
+
+	// events are only logged until guests are notified of them
+	var publisher events.Publisher = events.LogPublisher{}
+
+	go db.ReapHolds(context.Background(), store, time.Minute, func(ctx context.Context, expired []*types.Booking, now time.Time) error {
+		return api.OfferExpiredHolds(ctx, store, publisher, expired, now)
+	})
+
 	var (
-		hotelStore   = db.NewMongoHotelStore(client)
-		roomStore    = db.NewMongoRoomStore(client, hotelStore)
-		userStore    = db.NewMongoUserStore(client)
-		bookingStore = db.NewMongoBookingStore(client)
-		store        = &db.Store{
-			Hotel:   hotelStore,
-			Room:    roomStore,
-			User:    userStore,
-			Booking: bookingStore,
-		}
-		userHandler    = api.NewUserHandler(userStore)
-		hotelHandler   = api.NewHotelHandler(store)
-		roomHandler    = api.NewRoomHandler(store)
-		authHandler    = api.NewAuthHandler(userStore)
-		bookingHandler = api.NewBookingHandler(store)
-		app            = fiber.New(config)
-		auth           = app.Group("/api")
-		apiv1          = app.Group("/api/v1", api.JWTAuthentication(userStore))
-		admin          = apiv1.Group("/admin", api.AdminAuth)
+		userStore          = store.User
+		userHandler        = api.NewUserHandler(userStore, store.Token, tokens, cookies)
+		hotelHandler       = api.NewHotelHandler(store)
+		roomHandler        = api.NewRoomHandler(store)
+		authHandler        = api.NewAuthHandler(userStore, store.Token, tokens, cookies)
+		bookingHandler     = api.NewBookingHandler(store, publisher)
+		availHandler       = api.NewAvailabilityHandler(store)
+		promoHandler       = api.NewPromoHandler(store)
+		reservationHandler = api.NewReservationHandler(store, publisher)
+		waitlistHandler    = api.NewWaitlistHandler(store)
+		app                = fiber.New(config)
+		auth               = app.Group("/api")
+		apiv1              = app.Group("/api/v1", api.JWTAuthentication(userStore, store.Token, tokens, cookies), api.CurrencyConversion(rates))
+		admin              = apiv1.Group("/admin")
 	)
 
+	app.Get("/.well-known/jwks.json", tokens.HandleJWKS)
+
 	// auth
 	auth.Post("/auth", authHandler.HandleAuthenticate)
+	auth.Post("/auth/refresh", authHandler.HandleRefresh)
+	auth.Post("/auth/logout", authHandler.HandleLogout)
+	auth.Post("/booking/lookup", bookingHandler.HandleLookupBooking)
+	auth.Post("/register", userHandler.HandleRegister)
 
 	// versioned api routes
 	// user
-	apiv1.Post("/user", userHandler.HandlePostUser)
-	apiv1.Get("/user", userHandler.HandleGetUsers)
-	apiv1.Get("/user/:id", userHandler.HandleGetUser)
-	apiv1.Delete("/user/:id", userHandler.HandleDeleteUser)
-	apiv1.Put("/user/:id", userHandler.HandleUpdateUser)
+	apiv1.Get("/me", userHandler.HandleGetMe)
+	apiv1.Put("/me", userHandler.HandleUpdateMe)
+	apiv1.Put("/me/password", userHandler.HandleChangePassword)
 
 	// hotel
 	apiv1.Get("/hotel", hotelHandler.HandleGetHotels)
@@ -69,20 +92,111 @@ func main() {
 	// rooms
 	apiv1.Get("/room", roomHandler.HandleGetRooms)
 	apiv1.Post("/room/:id/book", roomHandler.HandleBookRoom)
+	apiv1.Post("/room/:id/hold", roomHandler.HandleHoldRoom)
+	apiv1.Post("/room/:id/quote", roomHandler.HandleQuoteRoom)
+
+	// availability
+	apiv1.Get("/availability", availHandler.HandleGetAvailability)
 
 	// bookings
+	apiv1.Get("/booking", bookingHandler.HandleGetUserBookings)
 	apiv1.Get("/booking/:id", bookingHandler.HandleGetBooking)
-	apiv1.Get("/booking/:id/cancel", bookingHandler.HandleCancelBooking)
+	apiv1.Patch("/booking/:id", bookingHandler.HandleModifyBooking)
+	apiv1.Post("/booking/:id/cancel", bookingHandler.HandleCancelBooking)
+	apiv1.Get("/booking/:id/cancel", bookingHandler.HandleDeprecatedCancelBooking)
+	apiv1.Get("/booking/:id/cancel-preview", bookingHandler.HandleCancelPreview)
+	apiv1.Post("/booking/:id/confirm", bookingHandler.HandleConfirmBooking)
+
+	// reservations
+	apiv1.Post("/reservation", reservationHandler.HandlePostReservation)
+	apiv1.Get("/reservation/:id", reservationHandler.HandleGetReservation)
+	apiv1.Post("/reservation/:id/cancel", reservationHandler.HandleCancelReservation)
+
+	// waitlist
+	apiv1.Post("/waitlist", waitlistHandler.HandlePostWaitlistEntry)
+	apiv1.Get("/waitlist", waitlistHandler.HandleGetWaitlistEntries)
+	apiv1.Delete("/waitlist/:id", waitlistHandler.HandleDeleteWaitlistEntry)
 
 	// admin handlers
-	admin.Get("/booking", bookingHandler.HandleGetBookings)
+	admin.Post("/user", api.Require(policy.ManageUsers), userHandler.HandlePostUser)
+	admin.Get("/user", api.Require(policy.ManageUsers), userHandler.HandleGetUsers)
+	admin.Get("/user/:id", api.Require(policy.ManageUsers), userHandler.HandleGetUser)
+	admin.Delete("/user/:id", api.Require(policy.ManageUsers), userHandler.HandleDeleteUser)
+	admin.Put("/user/:id", api.Require(policy.ManageUsers), userHandler.HandleUpdateUser)
+	admin.Put("/user/:id/role", api.Require(policy.ManageUsers), userHandler.HandleUpdateUserRole)
+	admin.Get("/booking", api.Require(policy.ViewBookings), bookingHandler.HandleGetBookings)
+	admin.Post("/booking/:id/cancel", api.Require(policy.ManageBookings), bookingHandler.HandleAdminCancelBooking)
+	admin.Post("/booking/:id/check-in", api.Require(policy.ManageBookings), bookingHandler.HandleCheckIn)
+	admin.Post("/booking/:id/check-out", api.Require(policy.ManageBookings), bookingHandler.HandleCheckOut)
+	admin.Post("/booking/:id/no-show", api.Require(policy.ManageBookings), bookingHandler.HandleNoShow)
+	admin.Post("/hotel", api.Require(policy.ManageHotels), hotelHandler.HandlePostHotel)
+	admin.Put("/hotel/:id", api.Require(policy.ManageHotels), hotelHandler.HandlePutHotel)
+	admin.Patch("/hotel/:id", api.Require(policy.ManageHotels), hotelHandler.HandlePatchHotel)
+	admin.Delete("/hotel/:id", api.Require(policy.ManageHotels), hotelHandler.HandleDeleteHotel)
+	admin.Post("/room", api.Require(policy.ManageHotels), roomHandler.HandlePostRoom)
+	admin.Put("/room/:id", api.Require(policy.ManageHotels), roomHandler.HandlePutRoom)
+	admin.Patch("/room/:id", api.Require(policy.ManageHotels), roomHandler.HandlePatchRoom)
+	admin.Delete("/room/:id", api.Require(policy.ManageHotels), roomHandler.HandleDeleteRoom)
+	admin.Post("/promo", api.Require(policy.ManagePromos), promoHandler.HandlePostPromoCode)
+	admin.Get("/promo", api.Require(policy.ManagePromos), promoHandler.HandleGetPromoCodes)
+	admin.Get("/promo/:code", api.Require(policy.ManagePromos), promoHandler.HandleGetPromoCode)
+	admin.Delete("/promo/:code", api.Require(policy.ManagePromos), promoHandler.HandleDeletePromoCode)
 
 	listenAddr := os.Getenv("HTTP_LISTEN_ADDRESS")
 	app.Listen(listenAddr)
 }
 
+func newStore(backend string) (*db.Store, error) {
+	switch backend {
+	case db.BackendMemory:
+		return memory.NewStore(), nil
+	case db.BackendSQLite:
+		conn, err := sqlstore.Open(context.Background(), os.Getenv("SQLITE_DB_URL"))
+		if err != nil {
+			return nil, err
+		}
+		return sqlstore.NewStore(conn), nil
+	case "", db.BackendMongo:
+	default:
+		return nil, fmt.Errorf("unknown %s %q", db.BackendEnvName, backend)
+	}
+
+	mongoEndpoint := os.Getenv("MONGO_DB_URL")
+	client, err := mongo.Connect(context.TODO(), options.Client().ApplyURI(mongoEndpoint))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := client.Ping(context.Background(), nil); err != nil {
+		return nil, err
+	}
+
+	bookingStore := db.NewMongoBookingStore(client)
+	if err := bookingStore.MigrateStatus(context.Background()); err != nil {
+		return nil, err
+	}
+
+	reservationStore := db.NewMongoReservationStore(client)
+	if err := reservationStore.MigrateConfirmationCodes(context.Background()); err != nil {
+		return nil, err
+	}
+
+	hotelStore := db.NewMongoHotelStore(client)
+	return &db.Store{
+		Hotel:       hotelStore,
+		Room:        db.NewMongoRoomStore(client, hotelStore),
+		User:        db.NewMongoUserStore(client),
+		Booking:     bookingStore,
+		Promo:       db.NewMongoPromoCodeStore(client),
+		Reservation: reservationStore,
+		Waitlist:    db.NewMongoWaitlistStore(client),
+		Token:       db.NewMongoTokenStore(client),
+	}, nil
+}
+
 func init() {
-	if err := godotenv.Load(); err != nil {
+	// the environment may be set without a .env file
+	if err := godotenv.Load(); err != nil && !errors.Is(err, os.ErrNotExist) {
 		log.Fatal(err)
 	}
 }
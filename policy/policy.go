@@ -0,0 +1,75 @@
+// Package policy decides what users may do from their role and assigned hotels.
+package policy
+
+import (
+	"github.com/raphaelmb/go-hotel-reservation/types"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type Permission string
+
+const (
+	ViewBookings   Permission = "bookings:view"
+	ManageBookings Permission = "bookings:manage"
+	ManageHotels   Permission = "hotels:manage"
+	ManagePromos   Permission = "promos:manage"
+	ManageUsers    Permission = "users:manage"
+)
+
+var grants = map[types.Role][]Permission{
+	types.RoleStaff:   {ViewBookings, ManageBookings},
+	types.RoleFinance: {ViewBookings, ManagePromos},
+	types.RoleAdmin:   {ViewBookings, ManageBookings, ManageHotels, ManagePromos, ManageUsers},
+}
+
+var hotelScoped = map[types.Role]bool{
+	types.RoleStaff: true,
+}
+
+// Allows holds for one hotel at least if the role is scoped to hotels.
+func Allows(user *types.User, perm Permission) bool {
+	role := user.EffectiveRole()
+	if !granted(role, perm) {
+		return false
+	}
+	return !hotelScoped[role] || len(user.HotelIDs) > 0
+}
+
+func AllowsHotel(user *types.User, perm Permission, hotelID primitive.ObjectID) bool {
+	hotelIDs, scoped := Hotels(user, perm)
+	if !scoped {
+		return true
+	}
+	for _, id := range hotelIDs {
+		if id == hotelID {
+			return true
+		}
+	}
+	return false
+}
+
+// Hotels returns scoped false if the permission applies to every hotel, and true with
+// no hotels if the user does not hold it.
+func Hotels(user *types.User, perm Permission) (hotelIDs []primitive.ObjectID, scoped bool) {
+	role := user.EffectiveRole()
+	if !granted(role, perm) {
+		return []primitive.ObjectID{}, true
+	}
+	if !hotelScoped[role] {
+		return nil, false
+	}
+	return append([]primitive.ObjectID{}, user.HotelIDs...), true
+}
+
+func CanViewBooking(user *types.User, booking *types.Booking) bool {
+	return booking.UserID == user.ID || AllowsHotel(user, ViewBookings, booking.HotelID)
+}
+
+func granted(role types.Role, perm Permission) bool {
+	for _, p := range grants[role] {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
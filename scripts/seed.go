@@ -12,6 +12,7 @@ import (
 	"github.com/raphaelmb/go-hotel-reservation/api"
 	"github.com/raphaelmb/go-hotel-reservation/db"
 	"github.com/raphaelmb/go-hotel-reservation/db/fixtures"
+	"github.com/raphaelmb/go-hotel-reservation/types"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
@@ -33,18 +34,27 @@ func main() {
 		log.Fatal(err)
 	}
 
+	tokens, err := api.NewTokenIssuerFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	hotelStore := db.NewMongoHotelStore(client)
 	store := &db.Store{
-		User:    db.NewMongoUserStore(client),
-		Booking: db.NewMongoBookingStore(client),
-		Room:    db.NewMongoRoomStore(client, hotelStore),
-		Hotel:   hotelStore,
+		User:        db.NewMongoUserStore(client),
+		Booking:     db.NewMongoBookingStore(client),
+		Room:        db.NewMongoRoomStore(client, hotelStore),
+		Hotel:       hotelStore,
+		Promo:       db.NewMongoPromoCodeStore(client),
+		Reservation: db.NewMongoReservationStore(client),
+		Waitlist:    db.NewMongoWaitlistStore(client),
+		Token:       db.NewMongoTokenStore(client),
 	}
 
 	user := fixtures.AddUser(store, "james", "foo", false)
-	fmt.Println("james token ->", api.CreateTokenFromUser(user))
+	printToken("james", store.Token, tokens, user)
 	admin := fixtures.AddUser(store, "admin", "admin", true)
-	fmt.Println("admin token ->", api.CreateTokenFromUser(admin))
+	printToken("admin", store.Token, tokens, admin)
 	hotel := fixtures.AddHotel(store, "hotel name", "Brazil", 5, nil)
 	room := fixtures.AddRoom(store, "large", true, 299.99, hotel.ID)
 	booking := fixtures.AddBooking(store, user.ID, room.ID, time.Now(), time.Now().AddDate(0, 0, 5))
@@ -54,3 +64,11 @@ func main() {
 		fixtures.AddHotel(store, fmt.Sprintf("Hotel-%d", i), fmt.Sprintf("Localtion-%d", i), rand.Intn(5)+1, nil)
 	}
 }
+
+func printToken(name string, tokenStore db.TokenStore, tokens *api.TokenIssuer, user *types.User) {
+	resp, err := api.StartSession(context.Background(), tokenStore, tokens, user)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(name, "token ->", resp.Token)
+}
@@ -0,0 +1,210 @@
+// Package signing holds the keys of access tokens, told apart by their kid so
+// that retired keys keep verifying the tokens they signed.
+package signing
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	HMACKeyID = "default"
+
+	minRSABits = 2048
+)
+
+var ErrUnknownKey = errors.New("unknown signing key")
+
+type Key struct {
+	ID     string
+	Method jwt.SigningMethod
+	// private is nil for keys which only verify
+	private any
+	public  any
+}
+
+type KeySet struct {
+	keys    map[string]*Key
+	signing *Key
+}
+
+func NewHMACKeySet(secret []byte) (*KeySet, error) {
+	if len(secret) == 0 {
+		return nil, errors.New("empty hmac secret")
+	}
+	key := &Key{ID: HMACKeyID, Method: jwt.SigningMethodHS256, private: secret, public: secret}
+	return &KeySet{keys: map[string]*Key{key.ID: key}, signing: key}, nil
+}
+
+// LoadKeySet loads the PEM files of dir, each named after the id of its key.
+// signingID may be empty if a single key can sign.
+func LoadKeySet(dir, signingID string) (*KeySet, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+	set := &KeySet{keys: make(map[string]*Key)}
+	var private []*Key
+	for _, path := range paths {
+		key, err := loadKey(path)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		set.keys[key.ID] = key
+		if key.private != nil {
+			private = append(private, key)
+		}
+	}
+
+	switch {
+	case len(signingID) > 0:
+		key, ok := set.keys[signingID]
+		if !ok {
+			return nil, fmt.Errorf("signing key %q not found in %s", signingID, dir)
+		}
+		if key.private == nil {
+			return nil, fmt.Errorf("signing key %q is a public key", signingID)
+		}
+		set.signing = key
+	case len(private) == 1:
+		set.signing = private[0]
+	case len(private) == 0:
+		return nil, fmt.Errorf("no private key found in %s", dir)
+	default:
+		return nil, fmt.Errorf("several private keys found in %s, the id of the signing key is required", dir)
+	}
+	return set, nil
+}
+
+func loadKey(path string) (*Key, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	var parsed any
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		parsed, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	key := &Key{ID: strings.TrimSuffix(filepath.Base(path), ".pem")}
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		key.Method, key.private, key.public = jwt.SigningMethodRS256, k, &k.PublicKey
+	case *rsa.PublicKey:
+		key.Method, key.public = jwt.SigningMethodRS256, k
+	case ed25519.PrivateKey:
+		key.Method, key.private, key.public = jwt.SigningMethodEdDSA, k, k.Public()
+	case ed25519.PublicKey:
+		key.Method, key.public = jwt.SigningMethodEdDSA, k
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", parsed)
+	}
+	if pub, ok := key.public.(*rsa.PublicKey); ok && pub.N.BitLen() < minRSABits {
+		return nil, fmt.Errorf("rsa key of %d bits, at least %d are required", pub.N.BitLen(), minRSABits)
+	}
+	return key, nil
+}
+
+func (s *KeySet) SigningKeyID() string {
+	return s.signing.ID
+}
+
+func (s *KeySet) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(s.signing.Method, claims)
+	token.Header["kid"] = s.signing.ID
+	return token.SignedString(s.signing.private)
+}
+
+// Keyfunc requires the algorithm of the key, so a public key cannot be used as
+// an HMAC secret.
+func (s *KeySet) Keyfunc(token *jwt.Token) (any, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok := s.keys[kid]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("key %q does not verify %s tokens", kid, token.Method.Alg())
+	}
+	return key.public, nil
+}
+
+func (s *KeySet) Methods() []string {
+	seen := make(map[string]bool)
+	var methods []string
+	for _, key := range s.keys {
+		if alg := key.Method.Alg(); !seen[alg] {
+			seen[alg] = true
+			methods = append(methods, alg)
+		}
+	}
+	sort.Strings(methods)
+	return methods
+}
+
+type JWK struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS never publishes HMAC secrets.
+func (s *KeySet) JWKS() JWKS {
+	jwks := JWKS{Keys: []JWK{}}
+	for _, key := range s.keys {
+		jwk := JWK{Use: "sig", Alg: key.Method.Alg(), Kid: key.ID}
+		switch pub := key.public.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		default:
+			continue
+		}
+		jwks.Keys = append(jwks.Keys, jwk)
+	}
+	sort.Slice(jwks.Keys, func(i, j int) bool { return jwks.Keys[i].Kid < jwks.Keys[j].Kid })
+	return jwks
+}
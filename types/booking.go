@@ -7,11 +7,126 @@ import (
 )
 
 type Booking struct {
-	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
-	UserID     primitive.ObjectID `bson:"userId,omitempty" json:"userId,omitempty"`
-	RoomID     primitive.ObjectID `bson:"roomID" json:"roomID,omitempty"`
-	NumPersons int                `bson:"numPersons,omitempty" json:"numPersons,omitempty"`
-	FromDate   time.Time          `bson:"fromDate,omitempty" json:"fromDate,omitempty"`
-	TillDate   time.Time          `bson:"tillDate,omitempty" json:"tillDate,omitempty"`
-	Cancelled  bool               `bson:"cancelled" json:"cancelled"`
+	ID               primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	ConfirmationCode string             `bson:"confirmationCode,omitempty" json:"confirmationCode,omitempty"`
+	UserID           primitive.ObjectID `bson:"userId,omitempty" json:"userId,omitempty"`
+	RoomID           primitive.ObjectID `bson:"roomID" json:"roomID,omitempty"`
+	HotelID          primitive.ObjectID `bson:"hotelID,omitempty" json:"hotelID,omitempty"`
+	ReservationID    primitive.ObjectID `bson:"reservationID,omitempty" json:"reservationID,omitempty"`
+	NumPersons       int                `bson:"numPersons,omitempty" json:"numPersons,omitempty"`
+	FromDate         time.Time          `bson:"fromDate,omitempty" json:"fromDate,omitempty"`
+	TillDate         time.Time          `bson:"tillDate,omitempty" json:"tillDate,omitempty"`
+	Status           BookingStatus      `bson:"status" json:"status"`
+	Price            *PriceQuote        `bson:"price,omitempty" json:"price,omitempty"`
+	// CancellationPolicy is the policy of the room when it was booked.
+	CancellationPolicy CancellationPolicy    `bson:"cancellationPolicy,omitempty" json:"cancellationPolicy"`
+	Cancellation       *Cancellation         `bson:"cancellation,omitempty" json:"cancellation,omitempty"`
+	Modifications      []BookingModification `bson:"modifications,omitempty" json:"modifications,omitempty"`
+	// ExpiresAt is set on holds, which expire unless confirmed in time.
+	ExpiresAt     time.Time             `bson:"expiresAt,omitempty" json:"expiresAt,omitempty"`
+	StatusHistory []BookingStatusChange `bson:"statusHistory,omitempty" json:"statusHistory"`
+}
+
+type BookingModification struct {
+	At         time.Time          `bson:"at" json:"at"`
+	RoomID     primitive.ObjectID `bson:"roomID" json:"roomID"`
+	FromDate   time.Time          `bson:"fromDate" json:"fromDate"`
+	TillDate   time.Time          `bson:"tillDate" json:"tillDate"`
+	NumPersons int                `bson:"numPersons" json:"numPersons"`
+	Total      Money              `bson:"total" json:"total"`
+	Fee        Money              `bson:"fee" json:"fee"`
+}
+
+type BookingStatus string
+
+const (
+	BookingPending    BookingStatus = "pending"
+	BookingConfirmed  BookingStatus = "confirmed"
+	BookingCheckedIn  BookingStatus = "checked_in"
+	BookingCheckedOut BookingStatus = "checked_out"
+	BookingCancelled  BookingStatus = "cancelled"
+	BookingNoShow     BookingStatus = "no_show"
+	BookingExpired    BookingStatus = "expired"
+)
+
+// bookingTransitions leaves out final statuses.
+var bookingTransitions = map[BookingStatus][]BookingStatus{
+	BookingPending:   {BookingConfirmed, BookingCancelled, BookingExpired},
+	BookingConfirmed: {BookingCheckedIn, BookingCancelled, BookingNoShow},
+	BookingCheckedIn: {BookingCheckedOut},
+}
+
+func (s BookingStatus) CanTransitionTo(to BookingStatus) bool {
+	for _, next := range bookingTransitions[s] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+func (s BookingStatus) IsActive() bool {
+	return s == BookingPending || s == BookingConfirmed || s == BookingCheckedIn
+}
+
+func ActiveBookingStatuses() []BookingStatus {
+	return []BookingStatus{BookingPending, BookingConfirmed, BookingCheckedIn}
+}
+
+func BookingStatusesBefore(to BookingStatus) []BookingStatus {
+	var from []BookingStatus
+	for status := range bookingTransitions {
+		if status.CanTransitionTo(to) {
+			from = append(from, status)
+		}
+	}
+	return from
+}
+
+type BookingStatusChange struct {
+	Status BookingStatus `bson:"status" json:"status"`
+	At     time.Time     `bson:"at" json:"at"`
+}
+
+// SetStatus does not check whether the transition is allowed.
+func (b *Booking) SetStatus(status BookingStatus, at time.Time) {
+	b.Status = status
+	b.StatusHistory = append(b.StatusHistory, BookingStatusChange{Status: status, At: at.UTC()})
+}
+
+func (b *Booking) IsExpired(now time.Time) bool {
+	return b.Status == BookingPending && !b.ExpiresAt.IsZero() && !now.Before(b.ExpiresAt)
+}
+
+func (b *Booking) StatusChangedAt(status BookingStatus) (time.Time, bool) {
+	for i := len(b.StatusHistory) - 1; i >= 0; i-- {
+		if b.StatusHistory[i].Status == status {
+			return b.StatusHistory[i].At, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// Nights returns the UTC calendar nights of the stay, at least one.
+func (b *Booking) Nights() []time.Time {
+	return Nights(b.FromDate, b.TillDate)
+}
+
+func Nights(from, till time.Time) []time.Time {
+	start := truncateDay(from)
+	end := truncateDay(till)
+	if !end.After(start) {
+		end = start.AddDate(0, 0, 1)
+	}
+
+	var nights []time.Time
+	for night := start; night.Before(end); night = night.AddDate(0, 0, 1) {
+		nights = append(nights, night)
+	}
+	return nights
+}
+
+func truncateDay(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
 }
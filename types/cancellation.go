@@ -0,0 +1,108 @@
+package types
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// The zero CancellationPolicy lets bookings be cancelled for free until the day of arrival.
+type CancellationPolicy struct {
+	NonRefundable bool `bson:"nonRefundable,omitempty" json:"nonRefundable,omitempty"`
+	// FreeDays is how many days before arrival cancellations are free.
+	FreeDays       int     `bson:"freeDays,omitempty" json:"freeDays,omitempty"`
+	PenaltyPercent float64 `bson:"penaltyPercent,omitempty" json:"penaltyPercent,omitempty"`
+	// ChangeFeePercent applies once a booking can no longer be cancelled for free.
+	ChangeFeePercent float64 `bson:"changeFeePercent,omitempty" json:"changeFeePercent,omitempty"`
+}
+
+func (p CancellationPolicy) IsZero() bool {
+	return p == CancellationPolicy{}
+}
+
+func (p CancellationPolicy) Validate() map[string]string {
+	errors := make(map[string]string)
+	if p.FreeDays < 0 {
+		errors["cancellationPolicy.freeDays"] = "free days cannot be negative"
+	}
+	if p.PenaltyPercent < 0 || p.PenaltyPercent > 100 {
+		errors["cancellationPolicy.penaltyPercent"] = "penalty percent should be between 0 and 100"
+	}
+	if p.ChangeFeePercent < 0 || p.ChangeFeePercent > 100 {
+		errors["cancellationPolicy.changeFeePercent"] = "change fee percent should be between 0 and 100"
+	}
+	if p.NonRefundable && (p.FreeDays > 0 || p.PenaltyPercent > 0) {
+		errors["cancellationPolicy"] = "non refundable policies cannot have free days or a penalty"
+	}
+	return errors
+}
+
+func validateCancellationPolicy(errors map[string]string, policy *CancellationPolicy) {
+	if policy == nil {
+		return
+	}
+	for k, v := range policy.Validate() {
+		errors[k] = v
+	}
+}
+
+func (p CancellationPolicy) FreeUntil(arrival time.Time) (time.Time, bool) {
+	if p.NonRefundable {
+		return time.Time{}, false
+	}
+	return truncateDay(arrival).AddDate(0, 0, -p.FreeDays), true
+}
+
+func (p CancellationPolicy) Cancel(price Money, arrival, at time.Time) *Cancellation {
+	cancellation := &Cancellation{
+		At:      at.UTC(),
+		Penalty: Money{Currency: price.Currency},
+	}
+	freeUntil, refundable := p.FreeUntil(arrival)
+	switch {
+	case !refundable:
+		cancellation.Penalty = price
+	case !at.Before(freeUntil):
+		cancellation.Penalty = price.Percent(p.PenaltyPercent)
+	}
+	cancellation.Refund = price.Sub(cancellation.Penalty)
+	return cancellation
+}
+
+func (p CancellationPolicy) ChangeFee(price Money, arrival, at time.Time) Money {
+	if freeUntil, refundable := p.FreeUntil(arrival); refundable && at.Before(freeUntil) {
+		return Money{Currency: price.Currency}
+	}
+	return price.Percent(p.ChangeFeePercent)
+}
+
+type Cancellation struct {
+	At      time.Time          `bson:"at" json:"at"`
+	By      primitive.ObjectID `bson:"by,omitempty" json:"by,omitempty"`
+	Reason  string             `bson:"reason,omitempty" json:"reason,omitempty"`
+	Penalty Money              `bson:"penalty" json:"penalty"`
+	Refund  Money              `bson:"refund" json:"refund"`
+}
+
+// CancellationAt refunds nothing for holds, which have not been paid.
+func (b *Booking) CancellationAt(at time.Time) *Cancellation {
+	var price Money
+	if b.Price != nil {
+		price = b.Price.Total
+	}
+	if b.Status == BookingPending {
+		return &Cancellation{At: at.UTC(), Penalty: Money{Currency: price.Currency}, Refund: Money{Currency: price.Currency}}
+	}
+	return b.CancellationPolicy.Cancel(price, b.FromDate, at)
+}
+
+func (b *Booking) ChangeFeeAt(at time.Time) Money {
+	var price Money
+	if b.Price != nil {
+		price = b.Price.Total
+	}
+	if b.Status == BookingPending {
+		return Money{Currency: price.Currency}
+	}
+	return b.CancellationPolicy.ChangeFee(price, b.FromDate, at)
+}
@@ -1,6 +1,18 @@
 package types
 
-import "go.mongodb.org/mongo-driver/bson/primitive"
+import (
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	minHotelNameLen = 2
+	minRating       = 1
+	maxRating       = 5
+)
 
 type Hotel struct {
 	ID       primitive.ObjectID   `bson:"_id,omitempty" json:"id,omitempty"`
@@ -8,12 +20,147 @@ type Hotel struct {
 	Location string               `bson:"location" json:"location"`
 	Rooms    []primitive.ObjectID `bson:"rooms" json:"rooms"`
 	Rating   int                  `bson:"rating" json:"rating"`
+	Currency Currency             `bson:"currency,omitempty" json:"currency"`
+	Pricing  PricingRules         `bson:"pricing" json:"pricing"`
+	Charges  []ChargeRule         `bson:"charges,omitempty" json:"charges,omitempty"`
+	// CancellationPolicy applies to the rooms without a policy of their own.
+	CancellationPolicy CancellationPolicy `bson:"cancellationPolicy,omitempty" json:"cancellationPolicy"`
+}
+
+func (h *Hotel) BaseCurrency() Currency {
+	if len(h.Currency) == 0 {
+		return DefaultCurrency
+	}
+	return h.Currency
+}
+
+func (h *Hotel) Quote(room *Room, from, till time.Time, persons int, promo *PromoCode) *PriceQuote {
+	quote := h.Pricing.Quote(room, from, till)
+	if promo != nil {
+		quote.applyPromo(promo)
+	}
+	quote.addCharges(h.Charges, persons)
+	return quote
+}
+
+func (h *Hotel) CancellationPolicyFor(room *Room) CancellationPolicy {
+	if !room.CancellationPolicy.IsZero() {
+		return room.CancellationPolicy
+	}
+	return h.CancellationPolicy
+}
+
+type CreateHotelParams struct {
+	Name               string              `json:"name"`
+	Location           string              `json:"location"`
+	Rating             int                 `json:"rating"`
+	Currency           Currency            `json:"currency"`
+	Pricing            *PricingRules       `json:"pricing"`
+	Charges            []ChargeRule        `json:"charges"`
+	CancellationPolicy *CancellationPolicy `json:"cancellationPolicy"`
+}
+
+func (params CreateHotelParams) Validate() map[string]string {
+	errors := make(map[string]string)
+	if len(params.Name) < minHotelNameLen {
+		errors["name"] = fmt.Sprintf("name length should be at least %d characters long", minHotelNameLen)
+	}
+	if len(params.Location) == 0 {
+		errors["location"] = "location is required"
+	}
+	if params.Rating < minRating || params.Rating > maxRating {
+		errors["rating"] = fmt.Sprintf("rating should be between %d and %d", minRating, maxRating)
+	}
+	if len(params.Currency) > 0 && !params.Currency.IsValid() {
+		errors["currency"] = fmt.Sprintf("currency %q is invalid", params.Currency)
+	}
+	if params.Pricing != nil {
+		for k, v := range params.Pricing.Validate() {
+			errors[k] = v
+		}
+	}
+	validateCharges(errors, params.Charges)
+	validateCancellationPolicy(errors, params.CancellationPolicy)
+
+	return errors
+}
+
+func NewHotelFromParams(params CreateHotelParams) *Hotel {
+	hotel := &Hotel{
+		Name:     params.Name,
+		Location: params.Location,
+		Rating:   params.Rating,
+		Currency: params.Currency,
+		Rooms:    []primitive.ObjectID{},
+	}
+	if len(hotel.Currency) == 0 {
+		hotel.Currency = DefaultCurrency
+	}
+	if params.Pricing != nil {
+		hotel.Pricing = *params.Pricing
+	}
+	hotel.Charges = params.Charges
+	if params.CancellationPolicy != nil {
+		hotel.CancellationPolicy = *params.CancellationPolicy
+	}
+	return hotel
+}
+
+// UpdateHotelParams leaves nil and zero fields untouched.
+type UpdateHotelParams struct {
+	Name               string              `json:"name"`
+	Location           string              `json:"location"`
+	Rating             int                 `json:"rating"`
+	Currency           Currency            `json:"currency"`
+	Pricing            *PricingRules       `json:"pricing"`
+	Charges            []ChargeRule        `json:"charges"`
+	CancellationPolicy *CancellationPolicy `json:"cancellationPolicy"`
+}
+
+func (params UpdateHotelParams) Validate() map[string]string {
+	errors := make(map[string]string)
+	if len(params.Name) > 0 && len(params.Name) < minHotelNameLen {
+		errors["name"] = fmt.Sprintf("name length should be at least %d characters long", minHotelNameLen)
+	}
+	if params.Rating != 0 && (params.Rating < minRating || params.Rating > maxRating) {
+		errors["rating"] = fmt.Sprintf("rating should be between %d and %d", minRating, maxRating)
+	}
+	if len(params.Currency) > 0 && !params.Currency.IsValid() {
+		errors["currency"] = fmt.Sprintf("currency %q is invalid", params.Currency)
+	}
+	if params.Pricing != nil {
+		for k, v := range params.Pricing.Validate() {
+			errors[k] = v
+		}
+	}
+	validateCharges(errors, params.Charges)
+	validateCancellationPolicy(errors, params.CancellationPolicy)
+
+	return errors
 }
 
-type Room struct {
-	ID      primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
-	Size    string             `bson:"size" json:"size"`
-	Seaside bool               `bson:"seaside" json:"seaside"`
-	Price   float64            `bson:"price" json:"price"`
-	HotelID primitive.ObjectID `bson:"hotelID" json:"hotelID"`
+func (p UpdateHotelParams) ToBSON() bson.M {
+	m := bson.M{}
+	if len(p.Name) > 0 {
+		m["name"] = p.Name
+	}
+	if len(p.Location) > 0 {
+		m["location"] = p.Location
+	}
+	if p.Rating > 0 {
+		m["rating"] = p.Rating
+	}
+	if len(p.Currency) > 0 {
+		m["currency"] = p.Currency
+	}
+	if p.Pricing != nil {
+		m["pricing"] = *p.Pricing
+	}
+	if p.Charges != nil {
+		m["charges"] = p.Charges
+	}
+	if p.CancellationPolicy != nil {
+		m["cancellationPolicy"] = *p.CancellationPolicy
+	}
+	return m
 }
@@ -0,0 +1,231 @@
+package types
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+)
+
+const DefaultCurrency Currency = "USD"
+
+type Currency string
+
+var currencyPattern = regexp.MustCompile(`^[A-Z]{3}$`)
+
+func (c Currency) IsValid() bool {
+	return currencyPattern.MatchString(string(c))
+}
+
+// Money without a currency keeps 3 decimals until WithCurrency gives it one.
+func (c Currency) minorDigits() int {
+	switch c {
+	case "JPY", "KRW", "CLP", "ISK", "VND":
+		return 0
+	case "BHD", "JOD", "KWD", "OMR", "TND", "":
+		return 3
+	}
+	return 2
+}
+
+func (c Currency) minorUnits() float64 {
+	return math.Pow10(c.minorDigits())
+}
+
+// Money is an amount in the minor unit of its currency, written in JSON as
+// {"amount": "299.99", "currency": "USD"}.
+type Money struct {
+	Amount   int64    `bson:"amount" json:"amount"`
+	Currency Currency `bson:"currency" json:"currency"`
+}
+
+func NewMoney(amount float64, currency Currency) Money {
+	return Money{
+		Amount:   int64(math.Round(amount * currency.minorUnits())),
+		Currency: currency,
+	}
+}
+
+func ParseMoney(amount string, currency Currency) (Money, error) {
+	whole, frac, _ := strings.Cut(strings.TrimSpace(amount), ".")
+	digits := currency.minorDigits()
+	if len(frac) > digits {
+		return Money{}, fmt.Errorf("amount %s has more than %d decimals", amount, digits)
+	}
+	negative := strings.HasPrefix(whole, "-")
+	units, err := strconv.ParseInt(strings.TrimPrefix(whole, "-")+frac+strings.Repeat("0", digits-len(frac)), 10, 64)
+	if err != nil {
+		return Money{}, fmt.Errorf("invalid amount %s", amount)
+	}
+	if negative {
+		units = -units
+	}
+	return Money{Amount: units, Currency: currency}, nil
+}
+
+// WithCurrency gives money parsed without a currency the currency.
+func (m Money) WithCurrency(currency Currency) (Money, error) {
+	if len(m.Currency) > 0 {
+		return m, nil
+	}
+	return ParseMoney(strings.TrimSuffix(strings.TrimRight(m.Decimal(), "0"), "."), currency)
+}
+
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+func (m Money) Add(o Money) Money {
+	return Money{Amount: m.Amount + o.Amount, Currency: m.Currency}
+}
+
+func (m Money) Sub(o Money) Money {
+	return Money{Amount: m.Amount - o.Amount, Currency: m.Currency}
+}
+
+func (m Money) Mul(factor float64) Money {
+	return Money{Amount: int64(math.Round(float64(m.Amount) * factor)), Currency: m.Currency}
+}
+
+func (m Money) Percent(percent float64) Money {
+	return m.Mul(percent / 100)
+}
+
+func (m Money) Decimal() string {
+	digits := m.Currency.minorDigits()
+	if digits == 0 {
+		return strconv.FormatInt(m.Amount, 10)
+	}
+	sign, amount := "", m.Amount
+	if amount < 0 {
+		sign, amount = "-", -amount
+	}
+	pow := int64(m.Currency.minorUnits())
+	return fmt.Sprintf("%s%d.%0*d", sign, amount/pow, digits, amount%pow)
+}
+
+func (m Money) String() string {
+	return m.Decimal() + " " + string(m.Currency)
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Amount   string   `json:"amount"`
+		Currency Currency `json:"currency"`
+	}{m.Decimal(), m.Currency})
+}
+
+// UnmarshalJSON also accepts the bare numbers prices were before they had a currency.
+func (m *Money) UnmarshalJSON(b []byte) error {
+	var number float64
+	if err := json.Unmarshal(b, &number); err == nil {
+		*m = NewMoney(number, "")
+		return nil
+	}
+
+	var v struct {
+		Amount   json.Number `json:"amount"`
+		Currency Currency    `json:"currency"`
+	}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	money, err := ParseMoney(v.Amount.String(), v.Currency)
+	if err != nil {
+		return err
+	}
+	*m = money
+	return nil
+}
+
+// UnmarshalBSONValue reads prices stored as plain numbers in the default currency.
+func (m *Money) UnmarshalBSONValue(t bsontype.Type, data []byte) error {
+	raw := bson.RawValue{Type: t, Value: data}
+	switch t {
+	case bsontype.Double:
+		*m = NewMoney(raw.Double(), DefaultCurrency)
+		return nil
+	case bsontype.Int32:
+		*m = NewMoney(float64(raw.Int32()), DefaultCurrency)
+		return nil
+	case bsontype.Int64:
+		*m = NewMoney(float64(raw.Int64()), DefaultCurrency)
+		return nil
+	case bsontype.Null:
+		*m = Money{}
+		return nil
+	}
+
+	// decode through a distinct type to not recurse into this method
+	type money Money
+	var v money
+	if err := raw.Unmarshal(&v); err != nil {
+		return err
+	}
+	*m = Money(v)
+	return nil
+}
+
+// Rates are the units of each currency worth one unit of the base currency.
+type ExchangeRates struct {
+	Base  Currency             `json:"base"`
+	Rates map[Currency]float64 `json:"rates"`
+}
+
+func LoadExchangeRates(path string) (*ExchangeRates, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var rates ExchangeRates
+	if err := json.Unmarshal(b, &rates); err != nil {
+		return nil, fmt.Errorf("invalid exchange rates file %s: %w", path, err)
+	}
+	if !rates.Base.IsValid() {
+		return nil, fmt.Errorf("invalid exchange rates file %s: base currency %q is invalid", path, rates.Base)
+	}
+	for currency, rate := range rates.Rates {
+		if !currency.IsValid() || rate <= 0 {
+			return nil, fmt.Errorf("invalid exchange rates file %s: invalid rate %v for %q", path, rate, currency)
+		}
+	}
+	return &rates, nil
+}
+
+func (r *ExchangeRates) rate(currency Currency) (float64, bool) {
+	if r == nil {
+		return 0, false
+	}
+	if currency == r.Base {
+		return 1, true
+	}
+	rate, ok := r.Rates[currency]
+	return rate, ok
+}
+
+func (r *ExchangeRates) Supports(currency Currency) bool {
+	_, ok := r.rate(currency)
+	return ok
+}
+
+func (r *ExchangeRates) Convert(m Money, to Currency) (Money, error) {
+	if m.Currency == to {
+		return m, nil
+	}
+	from, ok := r.rate(m.Currency)
+	if !ok {
+		return Money{}, fmt.Errorf("no exchange rate for %s", m.Currency)
+	}
+	rate, ok := r.rate(to)
+	if !ok {
+		return Money{}, fmt.Errorf("no exchange rate for %s", to)
+	}
+	major := float64(m.Amount) / m.Currency.minorUnits()
+	return NewMoney(major/from*rate, to), nil
+}
@@ -0,0 +1,222 @@
+package types
+
+import (
+	"fmt"
+	"time"
+)
+
+type PricingRules struct {
+	Seasons           []Season       `bson:"seasons,omitempty" json:"seasons,omitempty"`
+	WeekendMultiplier float64        `bson:"weekendMultiplier,omitempty" json:"weekendMultiplier,omitempty"`
+	StayDiscounts     []StayDiscount `bson:"stayDiscounts,omitempty" json:"stayDiscounts,omitempty"`
+}
+
+// When seasons overlap, the first one listed wins.
+type Season struct {
+	Name       string    `bson:"name" json:"name"`
+	From       time.Time `bson:"from" json:"from"`
+	Till       time.Time `bson:"till" json:"till"`
+	Multiplier float64   `bson:"multiplier" json:"multiplier"`
+}
+
+func (s Season) includes(night time.Time) bool {
+	return !night.Before(truncateDay(s.From)) && night.Before(truncateDay(s.Till))
+}
+
+type StayDiscount struct {
+	MinNights int     `bson:"minNights" json:"minNights"`
+	Percent   float64 `bson:"percent" json:"percent"`
+}
+
+func (r PricingRules) Validate() map[string]string {
+	errors := make(map[string]string)
+	for i, season := range r.Seasons {
+		key := fmt.Sprintf("pricing.seasons[%d]", i)
+		if !truncateDay(season.Till).After(truncateDay(season.From)) {
+			errors[key] = "till should be after from"
+		} else if season.Multiplier <= 0 {
+			errors[key] = "multiplier should be greater than 0"
+		}
+	}
+	if r.WeekendMultiplier < 0 {
+		errors["pricing.weekendMultiplier"] = "weekend multiplier should be greater than 0"
+	}
+	for i, discount := range r.StayDiscounts {
+		key := fmt.Sprintf("pricing.stayDiscounts[%d]", i)
+		if discount.MinNights < 1 {
+			errors[key] = "min nights should be at least 1"
+		} else if discount.Percent <= 0 || discount.Percent >= 100 {
+			errors[key] = "percent should be between 0 and 100"
+		}
+	}
+	return errors
+}
+
+type NightPrice struct {
+	Night    time.Time `bson:"night" json:"night"`
+	BaseRate Money     `bson:"baseRate" json:"baseRate"`
+	Season   string    `bson:"season,omitempty" json:"season,omitempty"`
+	Weekend  bool      `bson:"weekend,omitempty" json:"weekend,omitempty"`
+	Price    Money     `bson:"price" json:"price"`
+}
+
+type PriceQuote struct {
+	Nights          []NightPrice   `bson:"nights" json:"nights"`
+	Subtotal        Money          `bson:"subtotal" json:"subtotal"`
+	DiscountPercent float64        `bson:"discountPercent,omitempty" json:"discountPercent,omitempty"`
+	Discount        Money          `bson:"discount" json:"discount"`
+	Promo           *PromoDiscount `bson:"promo,omitempty" json:"promo,omitempty"`
+	// Charges are the taxes and fees on top of the discounted price.
+	Charges []Charge `bson:"charges,omitempty" json:"charges,omitempty"`
+	Total   Money    `bson:"total" json:"total"`
+}
+
+func (q *PriceQuote) Convert(rates *ExchangeRates, to Currency) (*PriceQuote, error) {
+	var err error
+	convert := func(m Money) Money {
+		if err != nil {
+			return m
+		}
+		m, err = rates.Convert(m, to)
+		return m
+	}
+
+	converted := *q
+	converted.Nights = make([]NightPrice, len(q.Nights))
+	for i, night := range q.Nights {
+		night.BaseRate = convert(night.BaseRate)
+		night.Price = convert(night.Price)
+		converted.Nights[i] = night
+	}
+	converted.Subtotal = convert(q.Subtotal)
+	converted.Discount = convert(q.Discount)
+	if q.Promo != nil {
+		converted.Promo = &PromoDiscount{Code: q.Promo.Code, Discount: convert(q.Promo.Discount)}
+	}
+	converted.Charges = make([]Charge, len(q.Charges))
+	for i, charge := range q.Charges {
+		charge.Amount = convert(charge.Amount)
+		converted.Charges[i] = charge
+	}
+	converted.Total = convert(q.Total)
+	if err != nil {
+		return nil, err
+	}
+	return &converted, nil
+}
+
+func (r PricingRules) Quote(room *Room, from, till time.Time) *PriceQuote {
+	quote := &PriceQuote{
+		Subtotal: Money{Currency: room.Price.Currency},
+	}
+	for _, night := range Nights(from, till) {
+		price := NightPrice{
+			Night:    night,
+			BaseRate: room.Price,
+		}
+		multiplier := 1.0
+		for _, season := range r.Seasons {
+			if season.includes(night) {
+				price.Season = season.Name
+				multiplier *= season.Multiplier
+				break
+			}
+		}
+		if isWeekendNight(night) && r.WeekendMultiplier > 0 {
+			price.Weekend = true
+			multiplier *= r.WeekendMultiplier
+		}
+		price.Price = room.Price.Mul(multiplier)
+
+		quote.Nights = append(quote.Nights, price)
+		quote.Subtotal = quote.Subtotal.Add(price.Price)
+	}
+
+	// the discount of the longest stay the booking qualifies for applies
+	minNights := 0
+	for _, discount := range r.StayDiscounts {
+		if len(quote.Nights) >= discount.MinNights && discount.MinNights > minNights {
+			minNights = discount.MinNights
+			quote.DiscountPercent = discount.Percent
+		}
+	}
+	quote.Discount = quote.Subtotal.Percent(quote.DiscountPercent)
+	quote.Total = quote.Subtotal.Sub(quote.Discount)
+	return quote
+}
+
+func isWeekendNight(night time.Time) bool {
+	return night.Weekday() == time.Friday || night.Weekday() == time.Saturday
+}
+
+type ChargeKind string
+
+const (
+	ChargePercent        ChargeKind = "percent"
+	ChargePerPersonNight ChargeKind = "per_person_night"
+	ChargePerNight       ChargeKind = "per_night"
+	ChargePerStay        ChargeKind = "per_stay"
+)
+
+// Percent applies to percentage charges, Amount to the others.
+type ChargeRule struct {
+	Name    string     `bson:"name" json:"name"`
+	Kind    ChargeKind `bson:"kind" json:"kind"`
+	Percent float64    `bson:"percent,omitempty" json:"percent,omitempty"`
+	Amount  Money      `bson:"amount,omitempty" json:"amount,omitempty"`
+}
+
+func (r ChargeRule) validate() string {
+	if len(r.Name) == 0 {
+		return "name is required"
+	}
+	switch r.Kind {
+	case ChargePercent:
+		if r.Percent <= 0 || r.Percent > 100 {
+			return "percent should be between 0 and 100"
+		}
+	case ChargePerPersonNight, ChargePerNight, ChargePerStay:
+		if r.Amount.Amount <= 0 {
+			return "amount should be greater than 0"
+		}
+		if len(r.Amount.Currency) > 0 && !r.Amount.Currency.IsValid() {
+			return fmt.Sprintf("currency %q is invalid", r.Amount.Currency)
+		}
+	default:
+		return fmt.Sprintf("charge kind %q is invalid", r.Kind)
+	}
+	return ""
+}
+
+func validateCharges(errors map[string]string, charges []ChargeRule) {
+	for i, charge := range charges {
+		if msg := charge.validate(); len(msg) > 0 {
+			errors[fmt.Sprintf("charges[%d]", i)] = msg
+		}
+	}
+}
+
+type Charge struct {
+	Name   string     `bson:"name" json:"name"`
+	Kind   ChargeKind `bson:"kind" json:"kind"`
+	Amount Money      `bson:"amount" json:"amount"`
+}
+
+func (q *PriceQuote) addCharges(rules []ChargeRule, persons int) {
+	base := q.Total
+	for _, rule := range rules {
+		var amount Money
+		switch rule.Kind {
+		case ChargePercent:
+			amount = base.Percent(rule.Percent)
+		case ChargePerPersonNight:
+			amount = rule.Amount.Mul(float64(persons * len(q.Nights)))
+		case ChargePerNight:
+			amount = rule.Amount.Mul(float64(len(q.Nights)))
+		case ChargePerStay:
+			amount = rule.Amount
+		}
+		q.Charges = append(q.Charges, Charge{Name: rule.Name, Kind: rule.Kind, Amount: amount})
+		q.Total = q.Total.Add(amount)
+	}
+}
@@ -0,0 +1,172 @@
+package types
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type PromoKind string
+
+const (
+	PromoPercent PromoKind = "percent"
+	// PromoFixed amounts are in the currency of the hotel.
+	PromoFixed PromoKind = "fixed"
+)
+
+var promoCodePattern = regexp.MustCompile(`^[A-Z0-9_-]{3,32}$`)
+
+func NormalizePromoCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// Zero limits and an empty validity window or hotel list do not restrict a PromoCode.
+type PromoCode struct {
+	ID             primitive.ObjectID   `bson:"_id,omitempty" json:"id,omitempty"`
+	Code           string               `bson:"code" json:"code"`
+	Kind           PromoKind            `bson:"kind" json:"kind"`
+	Percent        float64              `bson:"percent,omitempty" json:"percent,omitempty"`
+	Amount         Money                `bson:"amount,omitempty" json:"amount,omitempty"`
+	MinNights      int                  `bson:"minNights,omitempty" json:"minNights,omitempty"`
+	ValidFrom      time.Time            `bson:"validFrom,omitempty" json:"validFrom,omitempty"`
+	ValidTill      time.Time            `bson:"validTill,omitempty" json:"validTill,omitempty"`
+	MaxUses        int                  `bson:"maxUses,omitempty" json:"maxUses,omitempty"`
+	MaxUsesPerUser int                  `bson:"maxUsesPerUser,omitempty" json:"maxUsesPerUser,omitempty"`
+	HotelIDs       []primitive.ObjectID `bson:"hotelIDs,omitempty" json:"hotelIDs,omitempty"`
+	Uses           int                  `bson:"uses" json:"uses"`
+	// Redemptions counts uses per user id, for the stores keeping them in the document.
+	Redemptions map[string]int `bson:"redemptions,omitempty" json:"-"`
+}
+
+func (p *PromoCode) Applies(hotel *Hotel, nights int, at time.Time) error {
+	if !p.ValidFrom.IsZero() && at.Before(p.ValidFrom) {
+		return fmt.Errorf("promo code %s is not valid before %s", p.Code, p.ValidFrom.Format(time.DateOnly))
+	}
+	if !p.ValidTill.IsZero() && !at.Before(p.ValidTill) {
+		return fmt.Errorf("promo code %s expired on %s", p.Code, p.ValidTill.Format(time.DateOnly))
+	}
+	if nights < p.MinNights {
+		return fmt.Errorf("promo code %s requires a stay of at least %d nights", p.Code, p.MinNights)
+	}
+	if len(p.HotelIDs) > 0 && !containsID(p.HotelIDs, hotel.ID) {
+		return fmt.Errorf("promo code %s is not valid at hotel %s", p.Code, hotel.ID.Hex())
+	}
+	if p.Kind == PromoFixed && p.Amount.Currency != hotel.BaseCurrency() {
+		return fmt.Errorf("promo code %s is in %s, not in the currency of hotel %s", p.Code, p.Amount.Currency, hotel.ID.Hex())
+	}
+	return nil
+}
+
+type PromoDiscount struct {
+	Code     string `bson:"code" json:"code"`
+	Discount Money  `bson:"discount" json:"discount"`
+}
+
+func (q *PriceQuote) applyPromo(promo *PromoCode) {
+	var discount Money
+	switch promo.Kind {
+	case PromoPercent:
+		discount = q.Total.Percent(promo.Percent)
+	case PromoFixed:
+		discount = promo.Amount
+	}
+	if discount.Amount > q.Total.Amount {
+		discount.Amount = q.Total.Amount
+	}
+	discount.Currency = q.Total.Currency
+	q.Promo = &PromoDiscount{Code: promo.Code, Discount: discount}
+	q.Total = q.Total.Sub(discount)
+}
+
+type CreatePromoCodeParams struct {
+	Code           string    `json:"code"`
+	Kind           PromoKind `json:"kind"`
+	Percent        float64   `json:"percent"`
+	Amount         Money     `json:"amount"`
+	MinNights      int       `json:"minNights"`
+	ValidFrom      time.Time `json:"validFrom"`
+	ValidTill      time.Time `json:"validTill"`
+	MaxUses        int       `json:"maxUses"`
+	MaxUsesPerUser int       `json:"maxUsesPerUser"`
+	HotelIDs       []string  `json:"hotelIDs"`
+}
+
+func (params CreatePromoCodeParams) Validate() map[string]string {
+	errors := make(map[string]string)
+	if !promoCodePattern.MatchString(NormalizePromoCode(params.Code)) {
+		errors["code"] = "code should be 3 to 32 letters, digits, dashes or underscores"
+	}
+	switch params.Kind {
+	case PromoPercent:
+		if params.Percent <= 0 || params.Percent > 100 {
+			errors["percent"] = "percent should be between 0 and 100"
+		}
+	case PromoFixed:
+		if params.Amount.Amount <= 0 {
+			errors["amount"] = "amount should be greater than 0"
+		} else if !params.Amount.Currency.IsValid() {
+			errors["amount"] = fmt.Sprintf("currency %q is invalid", params.Amount.Currency)
+		}
+	default:
+		errors["kind"] = fmt.Sprintf("promo kind %q is invalid", params.Kind)
+	}
+	if !params.ValidFrom.IsZero() && !params.ValidTill.IsZero() && !params.ValidTill.After(params.ValidFrom) {
+		errors["validTill"] = "validTill should be after validFrom"
+	}
+	if params.MinNights < 0 {
+		errors["minNights"] = "min nights cannot be negative"
+	}
+	if params.MaxUses < 0 {
+		errors["maxUses"] = "max uses cannot be negative"
+	}
+	if params.MaxUsesPerUser < 0 {
+		errors["maxUsesPerUser"] = "max uses per user cannot be negative"
+	}
+	for i, id := range params.HotelIDs {
+		if _, err := primitive.ObjectIDFromHex(id); err != nil {
+			errors[fmt.Sprintf("hotelIDs[%d]", i)] = fmt.Sprintf("hotel id %s is invalid", id)
+		}
+	}
+	return errors
+}
+
+func NewPromoCodeFromParams(params CreatePromoCodeParams) (*PromoCode, error) {
+	hotelIDs := make([]primitive.ObjectID, len(params.HotelIDs))
+	for i, id := range params.HotelIDs {
+		oid, err := primitive.ObjectIDFromHex(id)
+		if err != nil {
+			return nil, err
+		}
+		hotelIDs[i] = oid
+	}
+
+	promo := &PromoCode{
+		Code:           NormalizePromoCode(params.Code),
+		Kind:           params.Kind,
+		MinNights:      params.MinNights,
+		ValidFrom:      params.ValidFrom.UTC(),
+		ValidTill:      params.ValidTill.UTC(),
+		MaxUses:        params.MaxUses,
+		MaxUsesPerUser: params.MaxUsesPerUser,
+		HotelIDs:       hotelIDs,
+	}
+	switch promo.Kind {
+	case PromoPercent:
+		promo.Percent = params.Percent
+	case PromoFixed:
+		promo.Amount = params.Amount
+	}
+	return promo, nil
+}
+
+func containsID(ids []primitive.ObjectID, id primitive.ObjectID) bool {
+	for _, oid := range ids {
+		if oid == id {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,91 @@
+package types
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// confirmationAlphabet leaves out characters mistaken for one another, such as O and 0.
+const confirmationAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+const confirmationLength = 8
+
+func NewConfirmationCode() (string, error) {
+	b := make([]byte, confirmationLength)
+	max := big.NewInt(int64(len(confirmationAlphabet)))
+	for i := range b {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		b[i] = confirmationAlphabet[n.Int64()]
+	}
+	return string(b), nil
+}
+
+func NormalizeConfirmationCode(code string) string {
+	return strings.ToUpper(strings.NewReplacer(" ", "", "-", "").Replace(code))
+}
+
+type ReservationStatus string
+
+const (
+	ReservationConfirmed ReservationStatus = "confirmed"
+	ReservationCancelled ReservationStatus = "cancelled"
+	// cancelling again cancels the rest of the bookings
+	ReservationPartiallyCancelled ReservationStatus = "partially_cancelled"
+)
+
+type Reservation struct {
+	ID               primitive.ObjectID   `bson:"_id,omitempty" json:"id,omitempty"`
+	ConfirmationCode string               `bson:"confirmationCode" json:"confirmationCode"`
+	UserID           primitive.ObjectID   `bson:"userId" json:"userId"`
+	HotelID          primitive.ObjectID   `bson:"hotelID" json:"hotelID"`
+	FromDate         time.Time            `bson:"fromDate" json:"fromDate"`
+	TillDate         time.Time            `bson:"tillDate" json:"tillDate"`
+	BookingIDs       []primitive.ObjectID `bson:"bookingIDs" json:"bookingIDs"`
+	Status           ReservationStatus    `bson:"status" json:"status"`
+	Total            Money                `bson:"total" json:"total"`
+	Cancellation     *Cancellation        `bson:"cancellation,omitempty" json:"cancellation,omitempty"`
+}
+
+type ReservationRoomParams struct {
+	RoomID     string `json:"roomID"`
+	NumPersons int    `json:"numPersons"`
+}
+
+type CreateReservationParams struct {
+	FromDate time.Time               `json:"fromDate"`
+	TillDate time.Time               `json:"tillDate"`
+	Rooms    []ReservationRoomParams `json:"rooms"`
+}
+
+const maxReservationRooms = 10
+
+func (params CreateReservationParams) Validate() map[string]string {
+	errors := make(map[string]string)
+	if len(params.Rooms) == 0 {
+		errors["rooms"] = "at least one room should be given"
+	}
+	if len(params.Rooms) > maxReservationRooms {
+		errors["rooms"] = fmt.Sprintf("at most %d rooms can be reserved together", maxReservationRooms)
+	}
+	seen := map[string]bool{}
+	for i, room := range params.Rooms {
+		if _, err := primitive.ObjectIDFromHex(room.RoomID); err != nil {
+			errors[fmt.Sprintf("rooms[%d].roomID", i)] = fmt.Sprintf("room id %s is invalid", room.RoomID)
+		} else if seen[room.RoomID] {
+			errors[fmt.Sprintf("rooms[%d].roomID", i)] = fmt.Sprintf("room %s is given more than once", room.RoomID)
+		}
+		seen[room.RoomID] = true
+		if room.NumPersons < 1 {
+			errors[fmt.Sprintf("rooms[%d].numPersons", i)] = "numPersons should be at least 1"
+		}
+	}
+	return errors
+}
@@ -0,0 +1,221 @@
+package types
+
+import (
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	defaultOccupancy = 2
+	maxOccupancy     = 20
+)
+
+type RoomType string
+
+const (
+	RoomTypeSingle RoomType = "single"
+	RoomTypeDouble RoomType = "double"
+	RoomTypeTwin   RoomType = "twin"
+	RoomTypeFamily RoomType = "family"
+	RoomTypeSuite  RoomType = "suite"
+)
+
+var roomTypeOccupancy = map[RoomType]int{
+	RoomTypeSingle: 1,
+	RoomTypeDouble: 2,
+	RoomTypeTwin:   2,
+	RoomTypeFamily: 4,
+	RoomTypeSuite:  4,
+}
+
+func (t RoomType) IsValid() bool {
+	_, ok := roomTypeOccupancy[t]
+	return ok
+}
+
+type BedType string
+
+const (
+	BedTypeSingle BedType = "single"
+	BedTypeDouble BedType = "double"
+	BedTypeQueen  BedType = "queen"
+	BedTypeKing   BedType = "king"
+	BedTypeSofa   BedType = "sofa"
+)
+
+func (t BedType) IsValid() bool {
+	switch t {
+	case BedTypeSingle, BedTypeDouble, BedTypeQueen, BedTypeKing, BedTypeSofa:
+		return true
+	}
+	return false
+}
+
+type Bed struct {
+	Type  BedType `bson:"type" json:"type"`
+	Count int     `bson:"count" json:"count"`
+}
+
+type Room struct {
+	ID                 primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	Type               RoomType           `bson:"type,omitempty" json:"type,omitempty"`
+	Size               string             `bson:"size" json:"size"`
+	MaxOccupancy       int                `bson:"maxOccupancy,omitempty" json:"maxOccupancy,omitempty"`
+	Beds               []Bed              `bson:"beds,omitempty" json:"beds,omitempty"`
+	Amenities          []string           `bson:"amenities,omitempty" json:"amenities,omitempty"`
+	Seaside            bool               `bson:"seaside" json:"seaside"`
+	Price              Money              `bson:"price" json:"price"`
+	HotelID            primitive.ObjectID `bson:"hotelID" json:"hotelID"`
+	CancellationPolicy CancellationPolicy `bson:"cancellationPolicy,omitempty" json:"cancellationPolicy"`
+}
+
+func (r *Room) Capacity() int {
+	if r.MaxOccupancy > 0 {
+		return r.MaxOccupancy
+	}
+	if occupancy, ok := roomTypeOccupancy[r.Type]; ok {
+		return occupancy
+	}
+	return defaultOccupancy
+}
+
+type CreateRoomParams struct {
+	Type               RoomType            `json:"type"`
+	Size               string              `json:"size"`
+	MaxOccupancy       int                 `json:"maxOccupancy"`
+	Beds               []Bed               `json:"beds"`
+	Amenities          []string            `json:"amenities"`
+	Seaside            bool                `json:"seaside"`
+	Price              Money               `json:"price"`
+	HotelID            string              `json:"hotelID"`
+	CancellationPolicy *CancellationPolicy `json:"cancellationPolicy"`
+}
+
+func (params CreateRoomParams) Validate() map[string]string {
+	errors := make(map[string]string)
+	if !params.Type.IsValid() {
+		errors["type"] = fmt.Sprintf("room type %q is invalid", params.Type)
+	}
+	if len(params.Size) == 0 {
+		errors["size"] = "size is required"
+	}
+	if params.Price.Amount <= 0 {
+		errors["price"] = "price should be greater than 0"
+	}
+	validateCurrency(errors, params.Price.Currency)
+	if _, err := primitive.ObjectIDFromHex(params.HotelID); err != nil {
+		errors["hotelID"] = fmt.Sprintf("hotel id %s is invalid", params.HotelID)
+	}
+	validateOccupancy(errors, params.MaxOccupancy, params.Beds)
+	validateCancellationPolicy(errors, params.CancellationPolicy)
+
+	return errors
+}
+
+func NewRoomFromParams(params CreateRoomParams) (*Room, error) {
+	hotelID, err := primitive.ObjectIDFromHex(params.HotelID)
+	if err != nil {
+		return nil, err
+	}
+
+	room := &Room{
+		Type:         params.Type,
+		Size:         params.Size,
+		MaxOccupancy: params.MaxOccupancy,
+		Beds:         params.Beds,
+		Amenities:    params.Amenities,
+		Seaside:      params.Seaside,
+		Price:        params.Price,
+		HotelID:      hotelID,
+	}
+	if params.CancellationPolicy != nil {
+		room.CancellationPolicy = *params.CancellationPolicy
+	}
+	return room, nil
+}
+
+// UpdateRoomParams leaves nil and zero fields untouched. Setting HotelID moves the room.
+type UpdateRoomParams struct {
+	Type               RoomType            `json:"type"`
+	Size               string              `json:"size"`
+	MaxOccupancy       int                 `json:"maxOccupancy"`
+	Beds               []Bed               `json:"beds"`
+	Amenities          []string            `json:"amenities"`
+	Seaside            *bool               `json:"seaside"`
+	Price              Money               `json:"price"`
+	HotelID            string              `json:"hotelID"`
+	CancellationPolicy *CancellationPolicy `json:"cancellationPolicy"`
+}
+
+func (params UpdateRoomParams) Validate() map[string]string {
+	errors := make(map[string]string)
+	if len(params.Type) > 0 && !params.Type.IsValid() {
+		errors["type"] = fmt.Sprintf("room type %q is invalid", params.Type)
+	}
+	if params.Price.Amount < 0 {
+		errors["price"] = "price should be greater than 0"
+	}
+	validateCurrency(errors, params.Price.Currency)
+	if len(params.HotelID) > 0 {
+		if _, err := primitive.ObjectIDFromHex(params.HotelID); err != nil {
+			errors["hotelID"] = fmt.Sprintf("hotel id %s is invalid", params.HotelID)
+		}
+	}
+	validateOccupancy(errors, params.MaxOccupancy, params.Beds)
+	validateCancellationPolicy(errors, params.CancellationPolicy)
+
+	return errors
+}
+
+func (p UpdateRoomParams) ToBSON() bson.M {
+	m := bson.M{}
+	if len(p.Type) > 0 {
+		m["type"] = p.Type
+	}
+	if len(p.Size) > 0 {
+		m["size"] = p.Size
+	}
+	if p.MaxOccupancy > 0 {
+		m["maxOccupancy"] = p.MaxOccupancy
+	}
+	if p.Beds != nil {
+		m["beds"] = p.Beds
+	}
+	if p.Amenities != nil {
+		m["amenities"] = p.Amenities
+	}
+	if p.Seaside != nil {
+		m["seaside"] = *p.Seaside
+	}
+	if p.Price.Amount > 0 {
+		m["price"] = p.Price
+	}
+	if oid, err := primitive.ObjectIDFromHex(p.HotelID); err == nil {
+		m["hotelID"] = oid
+	}
+	if p.CancellationPolicy != nil {
+		m["cancellationPolicy"] = *p.CancellationPolicy
+	}
+	return m
+}
+
+func validateOccupancy(errors map[string]string, occupancy int, beds []Bed) {
+	if occupancy < 0 || occupancy > maxOccupancy {
+		errors["maxOccupancy"] = fmt.Sprintf("max occupancy should be between 1 and %d", maxOccupancy)
+	}
+	for _, bed := range beds {
+		if !bed.Type.IsValid() {
+			errors["beds"] = fmt.Sprintf("bed type %q is invalid", bed.Type)
+		} else if bed.Count < 1 {
+			errors["beds"] = "bed count should be at least 1"
+		}
+	}
+}
+
+func validateCurrency(errors map[string]string, currency Currency) {
+	if len(currency) > 0 && !currency.IsValid() {
+		errors["price"] = fmt.Sprintf("currency %q is invalid", currency)
+	}
+}
@@ -0,0 +1,38 @@
+package types
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const refreshTokenBytes = 32
+
+// RefreshToken is traded for the next of its family, the session, which is revoked as a whole.
+type RefreshToken struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	UserID    primitive.ObjectID `bson:"userId" json:"userId"`
+	Family    primitive.ObjectID `bson:"family" json:"family"`
+	Hash      string             `bson:"hash" json:"-"`
+	ExpiresAt time.Time          `bson:"expiresAt" json:"expiresAt"`
+	UsedAt    time.Time          `bson:"usedAt,omitempty" json:"usedAt,omitempty"`
+	RevokedAt time.Time          `bson:"revokedAt,omitempty" json:"revokedAt,omitempty"`
+}
+
+func NewRefreshToken() (token, hash string, err error) {
+	b := make([]byte, refreshTokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	token = base64.RawURLEncoding.EncodeToString(b)
+	return token, HashRefreshToken(token), nil
+}
+
+func HashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
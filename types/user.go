@@ -21,6 +21,17 @@ type UpdateUserParams struct {
 	LastName  string `json:"lastName"`
 }
 
+func (p UpdateUserParams) Validate() map[string]string {
+	errors := make(map[string]string)
+	if len(p.FirstName) > 0 && len(p.FirstName) < minFirstNameLen {
+		errors["firstName"] = fmt.Sprintf("first name length should be at least %d characters long", minFirstNameLen)
+	}
+	if len(p.LastName) > 0 && len(p.LastName) < minLastNameLen {
+		errors["lastName"] = fmt.Sprintf("last name length should be at least %d characters long", minLastNameLen)
+	}
+	return errors
+}
+
 func (p UpdateUserParams) ToBSON() bson.M {
 	m := bson.M{}
 	if len(p.FirstName) > 0 {
@@ -68,17 +79,95 @@ func isEmailValid(e string) bool {
 	return emailRegex.MatchString(e)
 }
 
+type Role string
+
+const (
+	RoleGuest   Role = "guest"
+	RoleStaff   Role = "staff"
+	RoleFinance Role = "finance"
+	RoleAdmin   Role = "admin"
+)
+
+func (r Role) IsValid() bool {
+	switch r {
+	case RoleGuest, RoleStaff, RoleFinance, RoleAdmin:
+		return true
+	}
+	return false
+}
+
 type User struct {
-	ID                primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
-	FirstName         string             `bson:"firstName" json:"firstName"`
-	LastName          string             `bson:"lastName" json:"lastName"`
-	Email             string             `bson:"email" json:"email"`
-	EncryptedPassword string             `bson:"encryptedPassword" json:"-"`
-	IsAdmin           bool               `bson:"isAdmin" json:"isAdmin"`
+	ID                primitive.ObjectID   `bson:"_id,omitempty" json:"id,omitempty"`
+	FirstName         string               `bson:"firstName" json:"firstName"`
+	LastName          string               `bson:"lastName" json:"lastName"`
+	Email             string               `bson:"email" json:"email"`
+	EncryptedPassword string               `bson:"encryptedPassword" json:"-"`
+	IsAdmin           bool                 `bson:"isAdmin" json:"isAdmin"`
+	Role              Role                 `bson:"role,omitempty" json:"role,omitempty"`
+	HotelIDs          []primitive.ObjectID `bson:"hotelIDs,omitempty" json:"hotelIDs,omitempty"`
+}
+
+// EffectiveRole falls back to the admin flag for users made before roles existed.
+func (u *User) EffectiveRole() Role {
+	if len(u.Role) > 0 {
+		return u.Role
+	}
+	if u.IsAdmin {
+		return RoleAdmin
+	}
+	return RoleGuest
+}
+
+type UpdateRoleParams struct {
+	Role     Role     `json:"role"`
+	HotelIDs []string `json:"hotelIDs"`
+}
+
+func (p UpdateRoleParams) Validate() map[string]string {
+	errors := make(map[string]string)
+	if !p.Role.IsValid() {
+		errors["role"] = fmt.Sprintf("role %q is invalid", p.Role)
+	}
+	if p.Role == RoleStaff && len(p.HotelIDs) == 0 {
+		errors["hotelIDs"] = "staff should be assigned to at least one hotel"
+	}
+	if p.Role != RoleStaff && len(p.HotelIDs) > 0 {
+		errors["hotelIDs"] = "only staff are assigned to hotels"
+	}
+	for i, id := range p.HotelIDs {
+		if _, err := primitive.ObjectIDFromHex(id); err != nil {
+			errors[fmt.Sprintf("hotelIDs[%d]", i)] = fmt.Sprintf("hotel id %s is invalid", id)
+		}
+	}
+	return errors
+}
+
+func EncryptPassword(pw string) (string, error) {
+	encpw, err := bcrypt.GenerateFromPassword([]byte(pw), bcryptCost)
+	if err != nil {
+		return "", err
+	}
+	return string(encpw), nil
+}
+
+type ChangePasswordParams struct {
+	CurrentPassword string `json:"currentPassword"`
+	NewPassword     string `json:"newPassword"`
+}
+
+func (p ChangePasswordParams) Validate() map[string]string {
+	errors := make(map[string]string)
+	if len(p.CurrentPassword) == 0 {
+		errors["currentPassword"] = "currentPassword is required"
+	}
+	if len(p.NewPassword) < minPasswordLen {
+		errors["newPassword"] = fmt.Sprintf("password length should be at least %d characters long", minPasswordLen)
+	}
+	return errors
 }
 
 func NewUserFromParams(params CreateUserParams) (*User, error) {
-	encpw, err := bcrypt.GenerateFromPassword([]byte(params.Password), bcryptCost)
+	encpw, err := EncryptPassword(params.Password)
 	if err != nil {
 		return nil, err
 	}
@@ -87,7 +176,7 @@ func NewUserFromParams(params CreateUserParams) (*User, error) {
 		FirstName:         params.FirstName,
 		LastName:          params.LastName,
 		Email:             params.Email,
-		EncryptedPassword: string(encpw),
+		EncryptedPassword: encpw,
 		IsAdmin:           params.isAdmin,
 	}, nil
 }
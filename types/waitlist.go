@@ -0,0 +1,70 @@
+package types
+
+import (
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type WaitlistStatus string
+
+const (
+	WaitlistWaiting   WaitlistStatus = "waiting"
+	WaitlistOffered   WaitlistStatus = "offered"
+	WaitlistExpired   WaitlistStatus = "expired"
+	WaitlistWithdrawn WaitlistStatus = "withdrawn"
+)
+
+// A WaitlistEntry without a RoomID waits for any room of its type in the hotel.
+type WaitlistEntry struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	UserID     primitive.ObjectID `bson:"userId" json:"userId"`
+	HotelID    primitive.ObjectID `bson:"hotelID" json:"hotelID"`
+	RoomID     primitive.ObjectID `bson:"roomID,omitempty" json:"roomID,omitempty"`
+	RoomType   RoomType           `bson:"roomType,omitempty" json:"roomType,omitempty"`
+	FromDate   time.Time          `bson:"fromDate" json:"fromDate"`
+	TillDate   time.Time          `bson:"tillDate" json:"tillDate"`
+	NumPersons int                `bson:"numPersons" json:"numPersons"`
+	Status     WaitlistStatus     `bson:"status" json:"status"`
+	HoldID     primitive.ObjectID `bson:"holdID,omitempty" json:"holdID,omitempty"`
+}
+
+func (e *WaitlistEntry) Wants(room *Room) bool {
+	if !e.RoomID.IsZero() {
+		return e.RoomID == room.ID
+	}
+	return e.HotelID == room.HotelID && e.RoomType == room.Type && e.NumPersons <= room.Capacity()
+}
+
+type CreateWaitlistEntryParams struct {
+	RoomID     string    `json:"roomID"`
+	HotelID    string    `json:"hotelID"`
+	RoomType   RoomType  `json:"roomType"`
+	FromDate   time.Time `json:"fromDate"`
+	TillDate   time.Time `json:"tillDate"`
+	NumPersons int       `json:"numPersons"`
+}
+
+func (params CreateWaitlistEntryParams) Validate() map[string]string {
+	errors := make(map[string]string)
+	switch {
+	case len(params.RoomID) > 0:
+		if _, err := primitive.ObjectIDFromHex(params.RoomID); err != nil {
+			errors["roomID"] = fmt.Sprintf("room id %s is invalid", params.RoomID)
+		}
+		if len(params.HotelID) > 0 || len(params.RoomType) > 0 {
+			errors["roomID"] = "either roomID or hotelID and roomType should be given"
+		}
+	case len(params.HotelID) > 0 || len(params.RoomType) > 0:
+		if _, err := primitive.ObjectIDFromHex(params.HotelID); err != nil {
+			errors["hotelID"] = fmt.Sprintf("hotel id %s is invalid", params.HotelID)
+		}
+		if !params.RoomType.IsValid() {
+			errors["roomType"] = fmt.Sprintf("room type %q is invalid", params.RoomType)
+		}
+	default:
+		errors["roomID"] = "either roomID or hotelID and roomType should be given"
+	}
+	return errors
+}